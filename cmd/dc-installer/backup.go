@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+
+	"github.com/dragonchain/dragonchain-installer/internal/backup"
+	"github.com/dragonchain/dragonchain-installer/internal/configuration"
+	"github.com/dragonchain/dragonchain-installer/internal/dragonchain"
+	"github.com/dragonchain/dragonchain-installer/internal/interrupt"
+	"github.com/dragonchain/dragonchain-installer/internal/minikube"
+)
+
+func backupCommand(ctx context.Context, args []string) {
+	flags := flag.NewFlagSet("backup", flag.ExitOnError)
+	output := flags.String("o", "dragonchain-backup.enc", "Path of the encrypted backup file to write")
+	flags.Parse(args)
+	interrupt.SetStep("backing up chain")
+	config, err := configuration.LoadExistingConfiguration()
+	if err != nil {
+		fatalLog(err)
+	}
+	minikube.SetKubeContext(config.UseVM)
+	fmt.Println("Getting chain details from the running cluster")
+	pubID, err := dragonchain.GetDragonchainPublicID(ctx, config)
+	if err != nil {
+		fatalLog(err)
+	}
+	bundle, err := backup.CreateBundle(ctx, config, pubID)
+	if err != nil {
+		fatalLog(err)
+	}
+	passphrase, err := configuration.PromptForNewPassphrase(ctx)
+	if err != nil {
+		fatalLog(err)
+	}
+	if err := backup.WriteBundle(*output, bundle, passphrase); err != nil {
+		fatalLog(err)
+	}
+	fmt.Print("Backup of chain " + pubID + " written to " + *output + "\nKeep this file and its passphrase safe; anyone with both can take over this chain's identity\n")
+}
+
+func restoreCommand(ctx context.Context, args []string) {
+	flags := flag.NewFlagSet("restore", flag.ExitOnError)
+	input := flags.String("i", "dragonchain-backup.enc", "Path of the encrypted backup file to restore from")
+	flags.Parse(args)
+	interrupt.SetStep("restoring backup")
+	passphrase, err := configuration.PromptForPassphrase(ctx, "Enter the backup's passphrase: ")
+	if err != nil {
+		fatalLog(err)
+	}
+	bundle, err := backup.ReadBundle(*input, passphrase)
+	if err != nil {
+		fatalLog(err)
+	}
+	if _, err := configuration.LoadExistingConfiguration(); err == nil {
+		confirmed, err := configuration.PromptForConfirmation(ctx, "Existing installation config will be replaced by the one from the backup. Continue?")
+		if err != nil {
+			fatalLog(err)
+		}
+		if !confirmed {
+			fatalLog("Restore cancelled")
+		}
+	}
+	config, err := backup.Restore(ctx, bundle)
+	if err != nil {
+		fatalLog(err)
+	}
+	fmt.Print("Restored configuration for chain " + bundle.PublicID + ". Installing chain with restored keys\n\n")
+	installer(ctx, installOptions{config: config})
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+
+	"github.com/dragonchain/dragonchain-installer/internal/configuration"
+)
+
+const credentialsUsage = `Usage: dc-installer credentials get [-json] [chain id]`
+
+func credentialsCommand(ctx context.Context, args []string) {
+	interactive = false
+	if len(args) < 1 || args[0] != "get" {
+		fatalLog(credentialsUsage)
+	}
+	flags := flag.NewFlagSet("credentials get", flag.ExitOnError)
+	asJSON := flags.Bool("json", false, "Print all of the chain's credentials (dragonchain_id, auth_key_id, auth_key, endpoint) as json instead of just the auth key")
+	flags.Parse(args[1:])
+	pubID := flags.Arg(0)
+	if pubID == "" {
+		var err error
+		pubID, err = configuration.GetDefaultDragonchainID()
+		if err != nil {
+			fatalLog(err)
+		}
+		if pubID == "" {
+			fatalLog("No chain id given and no default chain is set in the credentials file")
+		}
+	}
+	credentials, err := configuration.GetDragonchainCredentials(ctx, pubID)
+	if err != nil {
+		fatalLog(err)
+	}
+	if credentials["auth_key"] == "" {
+		fatalLog("No credentials found for chain " + pubID)
+	}
+	if !*asJSON {
+		fmt.Println(credentials["auth_key"])
+		return
+	}
+	credentials["dragonchain_id"] = pubID
+	output, err := json.Marshal(credentials)
+	if err != nil {
+		fatalLog(err)
+	}
+	fmt.Println(string(output))
+}
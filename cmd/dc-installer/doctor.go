@@ -0,0 +1,27 @@
+package main
+
+import (
+	"context"
+	"flag"
+
+	"github.com/dragonchain/dragonchain-installer/internal/configuration"
+	"github.com/dragonchain/dragonchain-installer/internal/doctor"
+	"github.com/dragonchain/dragonchain-installer/internal/interrupt"
+	"github.com/dragonchain/dragonchain-installer/internal/minikube"
+)
+
+func doctorCommand(ctx context.Context, args []string) {
+	flags := flag.NewFlagSet("doctor", flag.ExitOnError)
+	fix := flags.Bool("fix", false, "Repair the problems found which have a known repair")
+	flags.Parse(args)
+	config, err := configuration.LoadExistingConfiguration()
+	if err == nil {
+		minikube.SetKubeContext(config.UseVM)
+	}
+	interrupt.SetStep("running doctor checks")
+	results := doctor.Run(ctx, doctor.Checks(config, err), *fix)
+	doctor.Print(results)
+	if doctor.Failed(results) {
+		fatalLog("\nSome checks failed")
+	}
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/dragonchain/dragonchain-installer/internal/configuration"
+	"github.com/dragonchain/dragonchain-installer/internal/dragonchain"
+	"github.com/dragonchain/dragonchain-installer/internal/dragonnet"
+	"github.com/dragonchain/dragonchain-installer/internal/dyndns"
+	"github.com/dragonchain/dragonchain-installer/internal/interrupt"
+)
+
+// Environment variable with the dynamic dns password, so it isn't visible on the command line
+const dyndnsPasswordEnv = "DYNDNS_PASSWORD"
+
+type endpointUpdater struct {
+	config   *configuration.Configuration
+	pubID    string
+	endpoint string
+	provider *dyndns.Provider
+	// Last ip successfully pushed to the dynamic dns provider
+	dyndnsIP string
+}
+
+// Get the endpoint the chain should have for the current public ip. Endpoints using a hostname don't change with the ip
+func (updater *endpointUpdater) newEndpoint(publicIP string) (string, error) {
+	if updater.endpoint != "" {
+		return updater.endpoint + ":" + strconv.Itoa(updater.config.Port), nil
+	}
+	current, err := url.Parse(updater.config.EndpointURL)
+	if err != nil {
+		return "", errors.New("Could not parse current endpoint " + updater.config.EndpointURL + ":\n" + err.Error())
+	}
+	if net.ParseIP(current.Hostname()) == nil {
+		return updater.config.EndpointURL, nil
+	}
+	return current.Scheme + "://" + configuration.EndpointHost(publicIP) + ":" + strconv.Itoa(updater.config.Port), nil
+}
+
+// Check the public ip once, updating dynamic dns and the chain's endpoint if necessary
+func (updater *endpointUpdater) update(ctx context.Context) error {
+	endpoint := updater.config.EndpointURL
+	if updater.endpoint != "" {
+		endpoint = updater.endpoint
+	}
+	publicIP, err := configuration.GetPublicIPForEndpoint(ctx, endpoint)
+	if err != nil {
+		return errors.New("Couldn't get public ip:\n" + err.Error())
+	}
+	if updater.provider != nil && publicIP != updater.dyndnsIP {
+		fmt.Println("Updating dynamic dns hostname " + updater.provider.Hostname + " to " + publicIP)
+		if err := updater.provider.Update(ctx, publicIP); err != nil {
+			return err
+		}
+		updater.dyndnsIP = publicIP
+	}
+	endpoint, err = updater.newEndpoint(publicIP)
+	if err != nil {
+		return err
+	}
+	if endpoint == updater.config.EndpointURL {
+		fmt.Println("Chain endpoint " + endpoint + " is up to date")
+		return nil
+	}
+	fmt.Println("Changing chain endpoint from " + updater.config.EndpointURL + " to " + endpoint)
+	// Only record the new endpoint once the chain is deployed with it, so that a failed upgrade is retried
+	updated := *updater.config
+	updated.EndpointURL = endpoint
+	if err := dragonchain.UpdateDragonchainDeployment(ctx, &updated); err != nil {
+		return err
+	}
+	updater.config.EndpointURL = endpoint
+	if err := configuration.SaveConfiguration(updater.config); err != nil {
+		return err
+	}
+	fmt.Println("Waiting for chain to register its new endpoint with dragon net")
+	if err := dragonnet.WaitForRegisteredEndpoint(ctx, updater.pubID, endpoint); err != nil {
+		return err
+	}
+	fmt.Println("Chain is registered with dragon net at " + endpoint)
+	return nil
+}
+
+func updateEndpointCommand(ctx context.Context, args []string) {
+	flags := flag.NewFlagSet("update-endpoint", flag.ExitOnError)
+	endpoint := flags.String("endpoint", "", "New endpoint for the chain (i.e. http://my.domain), without the port. Defaults to keeping the current endpoint, with its ip replaced by the current public ip")
+	daemon := flags.Bool("daemon", false, "Keep running, checking for public ip changes every interval")
+	interval := flags.Duration("interval", 10*time.Minute, "How often to check for public ip changes with -daemon")
+	dyndnsServer := flags.String("dyndns-server", "", "Base url of a dynamic dns provider's dyndns2 update api to push the public ip to (i.e. https://members.dyndns.org). The password is read from the "+dyndnsPasswordEnv+" environment variable")
+	dyndnsHostname := flags.String("dyndns-hostname", "", "Hostname to update with the dynamic dns provider")
+	dyndnsUsername := flags.String("dyndns-username", "", "Username for the dynamic dns provider")
+	flags.Parse(args)
+	if *daemon && *interval <= 0 {
+		fatalLog("Interval must be positive")
+	}
+	if *endpoint != "" {
+		if err := configuration.ValidateEndpoint(*endpoint); err != nil {
+			fatalLog(err)
+		}
+	}
+	config, pubID := loadInstalledChain(ctx)
+	updater := &endpointUpdater{config: config, pubID: pubID, endpoint: *endpoint}
+	if *dyndnsServer != "" {
+		if *dyndnsHostname == "" {
+			fatalLog("-dyndns-hostname is required with -dyndns-server")
+		}
+		updater.provider = &dyndns.Provider{Server: *dyndnsServer, Hostname: *dyndnsHostname, Username: *dyndnsUsername, Password: os.Getenv(dyndnsPasswordEnv)}
+	}
+	interrupt.SetStep("updating the chain's endpoint")
+	if !*daemon {
+		if err := updater.update(ctx); err != nil {
+			fatalLog(err)
+		}
+		return
+	}
+	fmt.Println("Checking for public ip changes every " + interval.String() + ". Press Ctrl-C to stop")
+	ticker := time.NewTicker(*interval)
+	defer ticker.Stop()
+	for {
+		// Failures (i.e. a connection outage) are expected to be temporary while running as a daemon, so keep going
+		if err := updater.update(ctx); err != nil && ctx.Err() == nil {
+			fmt.Println(time.Now().Format(time.RFC3339) + " Endpoint update failed:\n" + err.Error())
+		}
+		select {
+		case <-ctx.Done():
+			fmt.Println("Stopped checking for public ip changes")
+			return
+		case <-ticker.C:
+		}
+	}
+}
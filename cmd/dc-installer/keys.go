@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/dragonchain/dragonchain-installer/internal/chainapi"
+	"github.com/dragonchain/dragonchain-installer/internal/configuration"
+	"github.com/dragonchain/dragonchain-installer/internal/dragonchain"
+	"github.com/dragonchain/dragonchain-installer/internal/interrupt"
+	"github.com/dragonchain/dragonchain-installer/internal/minikube"
+)
+
+const keysUsage = `Usage: dc-installer keys rotate-root
+       dc-installer keys create [-nickname name]
+       dc-installer keys list
+       dc-installer keys revoke <key id>`
+
+func keysCommand(ctx context.Context, args []string) {
+	if len(args) < 1 {
+		fatalLog(keysUsage)
+	}
+	switch args[0] {
+	case "rotate-root":
+		keysRotateRoot(ctx)
+	case "create":
+		keysCreate(ctx, args[1:])
+	case "list":
+		keysList(ctx)
+	case "revoke":
+		if len(args) != 2 {
+			fatalLog(keysUsage)
+		}
+		keysRevoke(ctx, args[1])
+	default:
+		fatalLog(keysUsage)
+	}
+}
+
+// Load the existing installation and get the chain's public id from the running cluster
+func loadInstalledChain(ctx context.Context) (*configuration.Configuration, string) {
+	config, err := configuration.LoadExistingConfiguration()
+	if err != nil {
+		fatalLog(err)
+	}
+	minikube.SetKubeContext(config.UseVM)
+	pubID, err := dragonchain.GetDragonchainPublicID(ctx, config)
+	if err != nil {
+		fatalLog(err)
+	}
+	return config, pubID
+}
+
+func getChainAPIClient(ctx context.Context) *chainapi.Client {
+	_, pubID := loadInstalledChain(ctx)
+	client, err := chainapi.NewClientFromCredentials(ctx, pubID)
+	if err != nil {
+		fatalLog(err)
+	}
+	return client
+}
+
+func keysRotateRoot(ctx context.Context) {
+	interrupt.SetStep("rotating root HMAC key")
+	config, pubID := loadInstalledChain(ctx)
+	saveCredentials := func() error {
+		if err := configuration.SetDragonchainCredentials(ctx, pubID, map[string]string{"auth_key_id": config.HmacID, "auth_key": config.HmacKey}); err != nil {
+			return err
+		}
+		fmt.Println("Local credentials updated with the new root HMAC key")
+		return nil
+	}
+	if err := dragonchain.RotateRootHmacKey(ctx, config, saveCredentials); err != nil {
+		fatalLog(err)
+	}
+	fmt.Println("Root HMAC key rotated. The previous root key no longer works")
+}
+
+func keysCreate(ctx context.Context, args []string) {
+	flags := flag.NewFlagSet("keys create", flag.ExitOnError)
+	nickname := flags.String("nickname", "", "Nickname for the new api key")
+	flags.Parse(args)
+	interrupt.SetStep("creating api key")
+	key, err := getChainAPIClient(ctx).CreateAPIKey(ctx, *nickname)
+	if err != nil {
+		fatalLog(err)
+	}
+	// Not masked, since this is the only way to get the new key and the user explicitly asked for it
+	fmt.Println("New api key details: ID: " + key.ID + " | KEY: " + key.Key)
+	fmt.Println("The key can not be retrieved again, so keep it somewhere safe")
+}
+
+func keysList(ctx context.Context) {
+	interrupt.SetStep("listing api keys")
+	keys, err := getChainAPIClient(ctx).ListAPIKeys(ctx)
+	if err != nil {
+		fatalLog(err)
+	}
+	for _, key := range keys {
+		line := key.ID
+		if key.Nickname != "" {
+			line += " (" + key.Nickname + ")"
+		}
+		if key.Root {
+			line += " [root]"
+		}
+		if key.RegistrationTime > 0 {
+			line += " created " + time.Unix(key.RegistrationTime, 0).Format(time.RFC3339)
+		}
+		fmt.Println(line)
+	}
+	fmt.Println(strconv.Itoa(len(keys)) + " api key(s)")
+}
+
+func keysRevoke(ctx context.Context, keyID string) {
+	interrupt.SetStep("revoking api key")
+	if err := getChainAPIClient(ctx).DeleteAPIKey(ctx, keyID); err != nil {
+		fatalLog(err)
+	}
+	fmt.Println("Revoked api key " + keyID)
+}
@@ -1,22 +1,45 @@
 package main
 
 import (
+	"context"
+	"flag"
 	"fmt"
+	"net"
 	"os"
-	"strings"
+	"strconv"
 
+	"github.com/dragonchain/dragonchain-installer/internal/chainapi"
 	"github.com/dragonchain/dragonchain-installer/internal/configuration"
 	"github.com/dragonchain/dragonchain-installer/internal/dragonchain"
 	"github.com/dragonchain/dragonchain-installer/internal/dragonnet"
 	"github.com/dragonchain/dragonchain-installer/internal/helm"
+	"github.com/dragonchain/dragonchain-installer/internal/interrupt"
 	"github.com/dragonchain/dragonchain-installer/internal/kubectl"
 	"github.com/dragonchain/dragonchain-installer/internal/minikube"
+	"github.com/dragonchain/dragonchain-installer/internal/portmap"
+	"github.com/dragonchain/dragonchain-installer/internal/preflight"
 	"github.com/dragonchain/dragonchain-installer/internal/upnp"
 	"github.com/dragonchain/dragonchain-installer/internal/virtualbox"
 )
 
+// Whether the running command is used interactively. Commands whose output is read by scripts (i.e. credentials get) clear
+// this, so that errors don't mix with their output and they don't wait for enter on windows
+var interactive = true
+
 func fatalLog(v ...interface{}) {
-	fmt.Println(v...)
+	if !interactive {
+		fmt.Fprintln(os.Stderr, v...)
+		os.Exit(1)
+	}
+	if interrupt.Interrupted() {
+		interrupt.RunCleanups()
+		fmt.Print("\nInterrupted while " + interrupt.CurrentStep() + ":\n")
+		// Usually just the cancelled command, but it can also be an error that happened before the interrupt
+		fmt.Println(v...)
+		fmt.Print("To resume, run the same command again (choosing to use the existing config if asked). Steps which already completed will be detected and skipped\n")
+	} else {
+		fmt.Println(v...)
+	}
 	if configuration.Windows {
 		// If windows, require pressing enter before exiting
 		fmt.Print("\nFinished. Press enter to exit program\n")
@@ -25,48 +48,171 @@ func fatalLog(v ...interface{}) {
 	os.Exit(1)
 }
 
-func installer() {
-	fmt.Print("Starting dragonchain installer\nChecking for required dependencies\n\n")
-	if err := kubectl.InstallKubectlIfNecessary(); err != nil {
+type installOptions struct {
+	// Configuration to use instead of prompting for one (i.e. when restoring a backup)
+	config *configuration.Configuration
+	// Run after the chain is deployed, before checking that it works (i.e. to restore migrated data)
+	afterDeploy func(ctx context.Context, config *configuration.Configuration) error
+}
+
+// Check the chain's reachability from this machine, to help diagnose port forwarding issues
+func reachabilitySelfTest(ctx context.Context, config *configuration.Configuration) *dragonnet.SelfTest {
+	clusterIP, err := minikube.GetClusterIP(ctx, config.UseVM)
+	if err != nil {
+		fmt.Println(err)
+	}
+	publicIP, err := configuration.GetPublicIPForEndpoint(ctx, config.EndpointURL)
+	if err != nil {
+		fmt.Println("Couldn't detect public ip:\n" + err.Error())
+	}
+	return dragonnet.RunReachabilitySelfTest(ctx, config, clusterIP, publicIP)
+}
+
+// Whether the router port forward created with mechanism expires unless the watch command keeps renewing it
+func portForwardExpires(mechanism string) bool {
+	switch mechanism {
+	case "natpmp", "pcp", "upnp-pinhole":
+		// These protocols require a finite lifetime
+		return true
+	case "upnp":
+		return upnp.LeaseDuration > 0
+	}
+	return false
+}
+
+// The cluster's node port only accepts ipv4, so relay ipv6 connections (arriving through a pinhole) to it for as long as
+// the command runs
+func relayIPv6(ctx context.Context, config *configuration.Configuration, port int) error {
+	clusterIP, err := minikube.GetClusterIP(ctx, config.UseVM)
+	if err != nil {
+		return err
+	}
+	return portmap.RelayIPv6(ctx, port, net.JoinHostPort(clusterIP, strconv.Itoa(port)))
+}
+
+// Forwards the chain's port on the router with upnp, nat-pmp or pcp, trying the mechanism which worked last time first,
+// and records the mechanism which worked in the installation config. Endpoints which are only reachable over ipv6 need
+// a pinhole through the router's ipv6 firewall instead
+func portForwarder(config *configuration.Configuration) dragonnet.PortForwarder {
+	return func(ctx context.Context, port int) error {
+		mappers := portmap.Mappers()
+		if configuration.EndpointIPv6Only(ctx, config.EndpointURL) {
+			address, err := configuration.GetPublicIPForEndpoint(ctx, config.EndpointURL)
+			if err != nil {
+				return err
+			}
+			if err := relayIPv6(ctx, config, port); err != nil {
+				return err
+			}
+			mappers = []portmap.Mapper{&portmap.UPnPIPv6Pinhole{Address: address}}
+		}
+		mechanism, err := portmap.Forward(ctx, port, config.PortMapping, mappers)
+		if err != nil {
+			return err
+		}
+		fmt.Println("Forwarded port " + strconv.Itoa(port) + " with " + mechanism)
+		if mechanism != config.PortMapping {
+			config.PortMapping = mechanism
+			if err := configuration.SaveConfiguration(config); err != nil {
+				fmt.Println("Couldn't record port mapping mechanism in installation config:\n" + err.Error())
+			}
+		}
+		return nil
+	}
+}
+
+// Check that this machine can run the cluster before installing anything, exiting if it can't
+func preflightChecks(ctx context.Context, config *configuration.Configuration) {
+	result, err := preflight.Run(ctx, config)
+	if err != nil {
 		fatalLog(err)
 	}
-	if err := helm.InstallHelmIfNecessary(); err != nil {
+	fmt.Print("\n")
+	result.Print()
+	if result.Failed() {
+		if !configuration.SkipPreflight {
+			fatalLog("\nThis machine can't run the chain's cluster as configured. Resolve the failed checks above, or run with -skip-preflight to try anyway")
+		}
+		fmt.Print("Continuing despite failed preflight checks (-skip-preflight)\n")
+	}
+}
+
+// Install (or resume installing) a chain
+func installer(ctx context.Context, options installOptions) {
+	fmt.Print("Starting dragonchain installer\n\n")
+	interrupt.SetStep("getting chain configuration")
+	config := options.config
+	if config == nil {
+		var err error
+		config, err = configuration.PromptForUserConfiguration(ctx)
+		if err != nil {
+			fatalLog(err)
+		}
+	}
+	if configuration.ImportKeys && options.config == nil {
+		if err := configuration.PromptForImportedKeys(ctx, config); err != nil {
+			fatalLog(err)
+		}
+		if err := dragonchain.ValidateImportedKeys(config); err != nil {
+			fatalLog("Keys to import are not valid:\n", err)
+		}
+	}
+	if minikube.ClusterCreated(config.UseVM) {
+		fmt.Print("\nMinikube cluster already exists; skipping preflight checks\n")
+	} else {
+		interrupt.SetStep("checking this machine's resources")
+		preflightChecks(ctx, config)
+	}
+	fmt.Print("\nChecking for required dependencies\n\n")
+	interrupt.SetStep("installing required dependencies")
+	if err := kubectl.InstallKubectlIfNecessary(ctx); err != nil {
 		fatalLog(err)
 	}
-	if err := minikube.InstallMinikubeIfNecessary(); err != nil {
+	if err := helm.InstallHelmIfNecessary(ctx); err != nil {
 		fatalLog(err)
 	}
-	fmt.Print("\nBase dependencies installed\nConfiguring dependencies now\n\n")
-	config, err := configuration.PromptForUserConfiguration()
-	if err != nil {
+	if err := minikube.InstallMinikubeIfNecessary(ctx); err != nil {
 		fatalLog(err)
 	}
+	fmt.Print("\nBase dependencies installed\nConfiguring dependencies now\n\n")
 	if config.UseVM {
 		fmt.Print("Virtualbox required for minikube VM. Checking and installing if necessary\n")
-		if err := virtualbox.InstallVirtualBoxIfNecessary(); err != nil {
+		interrupt.SetStep("installing virtualbox")
+		if err := virtualbox.InstallVirtualBoxIfNecessary(ctx); err != nil {
 			fatalLog(err)
 		}
 	}
-	if err := minikube.StartMinikubeCluster(config.UseVM); err != nil {
+	interrupt.SetStep("starting the minikube cluster")
+	if err := minikube.StartMinikubeCluster(ctx, config); err != nil {
 		fatalLog(err)
 	}
-	if err := helm.InitializeHelm(); err != nil {
+	interrupt.SetStep("initializing helm")
+	if err := helm.InitializeHelm(ctx); err != nil {
 		fatalLog(err)
 	}
-	if err := dragonchain.SetupDragonchainPreReqs(config); err != nil {
+	interrupt.SetStep("setting up dragonchain prerequisites")
+	if err := dragonchain.SetupDragonchainPreReqs(ctx, config); err != nil {
 		fatalLog(err)
 	}
 	if config.UseVM {
-		if err := virtualbox.ConfigureVirtualboxVM(config); err != nil {
+		interrupt.SetStep("configuring the virtualbox VM")
+		if err := virtualbox.ConfigureVirtualboxVM(ctx, config); err != nil {
 			fatalLog(err)
 		}
 	}
 	fmt.Print("\nConfiguration of dependencies complete\nNow installing Dragonchain\n")
-	if err := dragonchain.InstallDragonchain(config); err != nil {
+	interrupt.SetStep("installing dragonchain")
+	if err := dragonchain.InstallDragonchain(ctx, config); err != nil {
 		fatalLog(err)
 	}
+	if options.afterDeploy != nil {
+		if err := options.afterDeploy(ctx, config); err != nil {
+			fatalLog(err)
+		}
+	}
 	fmt.Print("Installation Complete\n\nGetting public ID\n")
-	pubID, err := dragonchain.GetDragonchainPublicID(config)
+	interrupt.SetStep("getting the chain's public id")
+	pubID, err := dragonchain.GetDragonchainPublicID(ctx, config)
 	if err != nil {
 		fatalLog(err)
 	}
@@ -74,27 +220,37 @@ func installer() {
 	startCommand, stopCommand := minikube.FriendlyStartStopCommand(config.UseVM)
 	fmt.Print("In order to stop the dragonchain, run the following command in a terminal:\n" + stopCommand + "\n\n")
 	fmt.Print("In order to restart the dragonchain, run the following command in a terminal:\n" + startCommand + "\n\n")
-	if err := configuration.InstallDragonchainCredentials(config, pubID); err != nil {
+	interrupt.SetStep("installing chain credentials")
+	if err := configuration.InstallDragonchainCredentials(ctx, config, pubID); err != nil {
 		fatalLog(err)
 	}
-	fmt.Print("Checking dragon net for proper chain configuration\n")
-	if err := dragonnet.CheckDragonNetConfiguration(pubID); err != nil {
-		if strings.HasPrefix(err.Error(), "Although registered") {
-			// If only issue with registration is that chain is registered, but not reachable (potential port-forward issue), try upnp
-			fmt.Print("Chain is registered, but does not seem reachable. Trying to automatically port-forward with upnp\n")
-			if upnpErr := upnp.AddUPNPPortMapping(config.Port); upnpErr != nil {
-				fmt.Print("Could not port forward with upnp:\n" + upnpErr.Error())
-			} else {
-				fmt.Print("Port forward with upnp successful, checking dragonnet registration again\n")
-				err = dragonnet.CheckDragonNetConfiguration(pubID)
-			}
-		}
-		if err != nil {
-			fatalLog("\nDragonchain is installed and may be working locally, but dragon net configuration seems invalid\n", err)
-		}
+	fmt.Print("Checking the chain's api with the installed credentials\n")
+	interrupt.SetStep("checking the chain's api")
+	if err := chainapi.CheckCredentials(ctx, pubID, config.Level); err != nil {
+		fatalLog("\nDragonchain is installed, but its api could not be used with the installed credentials\n", err)
+	}
+	fmt.Print("Checking that the chain is reachable before asking dragon net\n")
+	interrupt.SetStep("checking chain reachability")
+	reachabilitySelfTest(ctx, config).Print()
+	fmt.Print("\nChecking dragon net for proper chain configuration\n")
+	interrupt.SetStep("checking dragon net configuration")
+	report, err := dragonnet.CheckDragonNetConfigurationWithPortForward(ctx, pubID, config.Port, portForwarder(config))
+	if report != nil {
+		fmt.Print("\n")
+		report.Print(config.EndpointURL)
+	}
+	if err != nil {
+		fatalLog("\nDragonchain is installed and may be working locally, but dragon net configuration seems invalid\n", err)
 	}
 	// Successful installation and dragon net configuration
 	fmt.Print("\nChain is installed, running, and operating correctly with Dragon Net!\n")
+	if config.PortMapping == "upnp-pinhole" {
+		fmt.Print("\nWARNING: The chain only accepts ipv4 connections, so ipv6 connections through the router's pinhole are relayed to it by the installer,\n" +
+			"which stops now. Keep 'dc-installer watch' running to relay them and renew the pinhole, or dragon net can't reach the chain\n")
+	} else if portForwardExpires(config.PortMapping) {
+		fmt.Print("\nWARNING: The router's port forward (" + config.PortMapping + ") expires, after which dragon net can't reach the chain.\n" +
+			"Keep 'dc-installer watch' running to renew it, or forward TCP port " + strconv.Itoa(config.Port) + " to this machine manually in the router's settings\n")
+	}
 	if configuration.Windows {
 		// If windows, require pressing enter before exiting
 		fmt.Print("\nFinished. Press enter to exit program\n")
@@ -102,6 +258,56 @@ func installer() {
 	}
 }
 
+const usage = `Usage: dc-installer [flags] [command]
+
+Commands:
+  install          Install (or resume installing) a dragonchain (default)
+  backup           Export the chain's keys and installer configuration to an encrypted file
+  restore          Restore a chain from a backup file and install it
+  migrate          Move a chain, including all of its data, to another machine (export/import)
+  keys             Rotate the root HMAC key or create, list and revoke api keys
+  credentials      Print a chain's credentials, resolving the auth key from the OS keyring if necessary (credentials get)
+  verify           Check that the chain processes transactions end to end, reporting how long each stage takes
+  update-endpoint  Update the chain's endpoint after its public ip changes (once, or continuously with -daemon), optionally with dynamic dns
+  watch            Keep checking the chain's dragon net configuration, recreating the router port forward and alerting on failures
+  port-forward     Forward the chain's port on the router, or list and remove the upnp port mappings (add/list/remove)
+  resize           Change the memory and cpus (not the disk size) of the minikube VM, restarting the chain
+  doctor           Diagnose common problems with the installation, and repair them with -fix
+  support-bundle   Collect versions, config, status and logs (with secrets redacted) into a tar.gz to share when asking for help
+  version          Print the version of this installer
+
+Flags:
+`
+
+// Parse command line flags, which take precedence over the installer settings file
+func parseFlags() (showVersion bool) {
+	flag.BoolVar(&showVersion, "version", false, "Print the version of this installer and exit")
+	flag.BoolVar(&showVersion, "V", false, "Print the version of this installer and exit (shorthand)")
+	flag.StringVar(&configuration.CredentialStore, "credential-store", configuration.CredentialStore, "Where to store chain HMAC keys: file (plain text credentials file) or keyring (OS keyring, or an encrypted file if unavailable)")
+	flag.BoolVar(&configuration.ShowSecrets, "show-secrets", configuration.ShowSecrets, "Print generated secrets such as the root HMAC key in full instead of masking them")
+	flag.BoolVar(&configuration.SkipPreflight, "skip-preflight", configuration.SkipPreflight, "Create the cluster even if this machine fails the preflight checks (not enough memory, cpus, disk or virtualization support)")
+	flag.BoolVar(&configuration.ImportKeys, "import-keys", configuration.ImportKeys, "Prompt for an existing chain's private key and root HMAC key to use instead of generating new ones")
+	flag.StringVar(&configuration.MatchmakingURL, "matchmaking-url", configuration.MatchmakingURL, "Base url of the dragon net matchmaking api (i.e. for staging environments)")
+	flag.StringVar(&configuration.MinikubeVMMemory, "vm-memory", configuration.MinikubeVMMemory, "Default memory of a new minikube VM (i.e. 8000mb or 8g)")
+	flag.IntVar(&configuration.MinikubeCpus, "vm-cpus", configuration.MinikubeCpus, "Default number of cpus of a new minikube VM")
+	flag.StringVar(&configuration.MinikubeDiskSize, "vm-disk-size", configuration.MinikubeDiskSize, "Default disk size of a new minikube VM (i.e. 40000mb or 40g)")
+	flag.DurationVar(&upnp.WatchLeaseDuration, "upnp-lease", upnp.WatchLeaseDuration, "Lease of upnp port mappings created by the watch command, which renews them while it runs (0 for a permanent mapping). Other commands create permanent mappings")
+	flag.DurationVar(&configuration.DragonchainReadyTimeout, "dragonchain-ready-timeout", configuration.DragonchainReadyTimeout, "How long to wait for dragonchain pods to become ready")
+	flag.DurationVar(&configuration.DragonchainPublicIDTimeout, "public-id-timeout", configuration.DragonchainPublicIDTimeout, "How long to wait for a running dragonchain pod to get the public id from")
+	flag.DurationVar(&configuration.TillerReadyTimeout, "tiller-ready-timeout", configuration.TillerReadyTimeout, "How long to wait for tiller to become ready (helm 2 only)")
+	flag.DurationVar(&configuration.DragonNetRegistrationTimeout, "dragonnet-registration-timeout", configuration.DragonNetRegistrationTimeout, "How long to wait for the chain to register with dragon net")
+	flag.DurationVar(&configuration.ChainAPITimeout, "chain-api-timeout", configuration.ChainAPITimeout, "How long to wait for the chain's api to accept the installed credentials")
+	flag.DurationVar(&configuration.PublicIPTimeout, "public-ip-timeout", configuration.PublicIPTimeout, "How long to wait for each source when looking up the public ip")
+	flag.DurationVar(&configuration.DockerRestartTimeout, "docker-restart-timeout", configuration.DockerRestartTimeout, "How long to wait for the cluster after restarting docker (native docker only)")
+	flag.DurationVar(&configuration.PollInterval, "poll-interval", configuration.PollInterval, "How often to check again while waiting on any of the above")
+	flag.Usage = func() {
+		fmt.Fprint(flag.CommandLine.Output(), usage)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	return showVersion
+}
+
 func main() {
 	if !(configuration.Windows || configuration.Linux || configuration.Macos) {
 		fatalLog("Unsupported OS")
@@ -113,14 +319,54 @@ func main() {
 		}
 		fmt.Println("WARNING!!! ARM64 support is currently experimental and not fully working/supported.")
 	}
-	if len(os.Args) > 1 && (os.Args[1] == "-V" || os.Args[1] == "--version" || os.Args[1] == "version") {
+	if err := configuration.LoadInstallerSettings(); err != nil {
+		fatalLog(err)
+	}
+	showVersion := parseFlags()
+	command := flag.Arg(0)
+	if showVersion || command == "version" {
 		fmt.Println(configuration.Version)
 	} else {
 		// Don't allow the program to run as root
 		if os.Geteuid() == 0 {
 			fatalLog("Do not run this program as root. Run it as your regular user")
 		}
-		installer()
+		ctx, cancel := interrupt.WithSignals(context.Background())
+		args := flag.Args()
+		if len(args) > 0 {
+			args = args[1:]
+		}
+		switch command {
+		case "", "install":
+			installer(ctx, installOptions{})
+		case "backup":
+			backupCommand(ctx, args)
+		case "restore":
+			restoreCommand(ctx, args)
+		case "migrate":
+			migrateCommand(ctx, args)
+		case "keys":
+			keysCommand(ctx, args)
+		case "credentials":
+			credentialsCommand(ctx, args)
+		case "verify":
+			verifyCommand(ctx, args)
+		case "update-endpoint":
+			updateEndpointCommand(ctx, args)
+		case "watch":
+			watchCommand(ctx, args)
+		case "port-forward":
+			portForwardCommand(ctx, args)
+		case "resize":
+			resizeCommand(ctx, args)
+		case "doctor":
+			doctorCommand(ctx, args)
+		case "support-bundle":
+			supportBundleCommand(ctx, args)
+		default:
+			fatalLog("Unknown command '" + command + "'. Run with -h for usage")
+		}
+		cancel()
 	}
 	os.Exit(0)
 }
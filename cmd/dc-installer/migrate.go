@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+
+	"github.com/dragonchain/dragonchain-installer/internal/backup"
+	"github.com/dragonchain/dragonchain-installer/internal/configuration"
+	"github.com/dragonchain/dragonchain-installer/internal/dragonchain"
+	"github.com/dragonchain/dragonchain-installer/internal/interrupt"
+	"github.com/dragonchain/dragonchain-installer/internal/migrate"
+	"github.com/dragonchain/dragonchain-installer/internal/minikube"
+)
+
+const migrateUsage = `Usage: dc-installer migrate export [-o file]
+       dc-installer migrate import [-i file]`
+
+func migrateCommand(ctx context.Context, args []string) {
+	if len(args) < 1 {
+		fatalLog(migrateUsage)
+	}
+	switch args[0] {
+	case "export":
+		migrateExport(ctx, args[1:])
+	case "import":
+		migrateImport(ctx, args[1:])
+	default:
+		fatalLog(migrateUsage)
+	}
+}
+
+func migrateExport(ctx context.Context, args []string) {
+	flags := flag.NewFlagSet("migrate export", flag.ExitOnError)
+	output := flags.String("o", "dragonchain-migration.tar", "Path of the migration archive to write")
+	flags.Parse(args)
+	interrupt.SetStep("exporting chain")
+	config, err := configuration.LoadExistingConfiguration()
+	if err != nil {
+		fatalLog(err)
+	}
+	minikube.SetKubeContext(config.UseVM)
+	fmt.Println("Getting chain details from the running cluster")
+	pubID, err := dragonchain.GetDragonchainPublicID(ctx, config)
+	if err != nil {
+		fatalLog(err)
+	}
+	bundle, err := backup.CreateBundle(ctx, config, pubID)
+	if err != nil {
+		fatalLog(err)
+	}
+	passphrase, err := configuration.PromptForNewPassphrase(ctx)
+	if err != nil {
+		fatalLog(err)
+	}
+	identity, err := backup.SealBundle(bundle, passphrase)
+	if err != nil {
+		fatalLog(err)
+	}
+	if err := migrate.Export(ctx, config, pubID, identity, *output); err != nil {
+		fatalLog(err)
+	}
+	fmt.Print("Migration archive for chain " + pubID + " written to " + *output + "\n")
+	fmt.Print("Run 'dc-installer migrate import -i " + *output + "' on the new machine, then stop this chain so that only one copy is running\n")
+}
+
+func migrateImport(ctx context.Context, args []string) {
+	flags := flag.NewFlagSet("migrate import", flag.ExitOnError)
+	input := flags.String("i", "dragonchain-migration.tar", "Path of the migration archive to import")
+	flags.Parse(args)
+	interrupt.SetStep("importing chain")
+	manifest, identity, err := migrate.ReadArchive(*input)
+	if err != nil {
+		fatalLog(err)
+	}
+	fmt.Println("Importing chain " + manifest.PublicID + " exported at " + manifest.Created)
+	passphrase, err := configuration.PromptForPassphrase(ctx, "Enter the migration archive's passphrase: ")
+	if err != nil {
+		fatalLog(err)
+	}
+	bundle, err := backup.OpenBundle(identity, passphrase)
+	if err != nil {
+		fatalLog(err)
+	}
+	if _, err := configuration.LoadExistingConfiguration(); err == nil {
+		confirmed, err := configuration.PromptForConfirmation(ctx, "Existing installation config will be replaced by the one from the migration archive. Continue?")
+		if err != nil {
+			fatalLog(err)
+		}
+		if !confirmed {
+			fatalLog("Import cancelled")
+		}
+	}
+	config, err := backup.Restore(ctx, bundle)
+	if err != nil {
+		fatalLog(err)
+	}
+	fmt.Print("Restored configuration for chain " + bundle.PublicID + ". Installing chain with migrated keys and data\n\n")
+	installer(ctx, installOptions{
+		config: config,
+		afterDeploy: func(ctx context.Context, config *configuration.Configuration) error {
+			interrupt.SetStep("restoring chain data")
+			return migrate.RestoreVolumes(ctx, config, *input)
+		},
+	})
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"strconv"
+
+	"github.com/dragonchain/dragonchain-installer/internal/configuration"
+	"github.com/dragonchain/dragonchain-installer/internal/interrupt"
+	"github.com/dragonchain/dragonchain-installer/internal/upnp"
+	"github.com/dragonchain/dragonchain-installer/internal/virtualbox"
+)
+
+const portForwardUsage = `Usage: dc-installer port-forward add
+       dc-installer port-forward list
+       dc-installer port-forward remove [-port port] [-force]`
+
+func portForwardCommand(ctx context.Context, args []string) {
+	if len(args) < 1 {
+		fatalLog(portForwardUsage)
+	}
+	config, err := configuration.LoadExistingConfiguration()
+	if err != nil {
+		fatalLog(err)
+	}
+	switch args[0] {
+	case "add":
+		interrupt.SetStep("forwarding the chain's port")
+		if err := portForwarder(config)(ctx, config.Port); err != nil {
+			fatalLog(err)
+		}
+	case "list":
+		portForwardList(ctx, config)
+	case "remove":
+		portForwardRemove(ctx, config, args[1:])
+	default:
+		fatalLog(portForwardUsage)
+	}
+}
+
+func portForwardList(ctx context.Context, config *configuration.Configuration) {
+	if config.UseVM {
+		portForwardListVirtualbox(ctx, config)
+	}
+	interrupt.SetStep("listing upnp port mappings")
+	mappings, err := upnp.ListPortMappings(ctx, []int{config.Port})
+	if err != nil {
+		fatalLog(err)
+	}
+	for _, mapping := range mappings {
+		line := strconv.Itoa(mapping.ExternalPort) + " -> " + mapping.InternalClient + ":" + strconv.Itoa(mapping.InternalPort) + " (" + mapping.Description + ")"
+		if mapping.LeaseDuration > 0 {
+			line += " expires in " + mapping.LeaseDuration.String()
+		} else {
+			line += " permanent"
+		}
+		if !mapping.Enabled {
+			line += " [disabled]"
+		}
+		if mapping.ExternalPort == config.Port {
+			line += " [this chain's port]"
+		}
+		fmt.Println(line)
+	}
+	fmt.Println(strconv.Itoa(len(mappings)) + " upnp port mapping(s)")
+}
+
+func portForwardListVirtualbox(ctx context.Context, config *configuration.Configuration) {
+	interrupt.SetStep("listing virtualbox port forwards")
+	rules, err := virtualbox.ListNATRules(ctx)
+	if err != nil {
+		fatalLog(err)
+	}
+	for _, rule := range rules {
+		line := rule.Protocol + " " + rule.HostIP + ":" + strconv.Itoa(rule.HostPort) + " -> " + rule.VM + " " + rule.GuestIP + ":" + strconv.Itoa(rule.GuestPort) + " (" + rule.Name + ")"
+		if rule.Name == virtualbox.RuleName(config) {
+			line += " [this chain]"
+		} else if rule.ConflictsWith(config) {
+			line += " [conflicts with this chain's port]"
+		}
+		fmt.Println(line)
+	}
+	fmt.Println(strconv.Itoa(len(rules)) + " virtualbox port forward(s)")
+}
+
+func portForwardRemove(ctx context.Context, config *configuration.Configuration, args []string) {
+	flags := flag.NewFlagSet("port-forward remove", flag.ExitOnError)
+	port := flags.Int("port", config.Port, "External port to stop forwarding")
+	force := flags.Bool("force", false, "Remove the mapping even if it forwards to another machine")
+	flags.Parse(args)
+	interrupt.SetStep("removing upnp port mapping")
+	if err := upnp.RemovePortMapping(ctx, *port, *force); err != nil {
+		fatalLog(err)
+	}
+	fmt.Println("Removed upnp port mapping for port " + strconv.Itoa(*port))
+}
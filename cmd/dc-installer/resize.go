@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"strconv"
+
+	"github.com/dragonchain/dragonchain-installer/internal/configuration"
+	"github.com/dragonchain/dragonchain-installer/internal/dragonchain"
+	"github.com/dragonchain/dragonchain-installer/internal/interrupt"
+	"github.com/dragonchain/dragonchain-installer/internal/minikube"
+	"github.com/dragonchain/dragonchain-installer/internal/virtualbox"
+)
+
+const resizeUsage = `Usage: dc-installer resize [-memory 8000mb] [-cpus 4]
+The disk size of an existing VM can't be changed`
+
+// Put the VM back to its previous size and start the cluster again after resizing failed, so that a failure doesn't leave
+// the chain stopped, then exit with err. Deliberately not bound to the installer context, which may have been cancelled
+func restartAfterFailedResize(config *configuration.Configuration, err error) {
+	fmt.Println("Resizing failed, starting the cluster again with its previous size")
+	memory, cpus, _ := configuration.VMResources(config)
+	if memoryMB, parseErr := configuration.ParseSizeMB(memory); parseErr == nil {
+		if resizeErr := virtualbox.ResizeVM(context.Background(), memoryMB, cpus); resizeErr != nil {
+			fmt.Println(resizeErr)
+		} else if profileErr := minikube.SetProfileResources(memoryMB, cpus); profileErr != nil {
+			fmt.Println(profileErr)
+		}
+	}
+	if startErr := minikube.StartMinikubeCluster(context.Background(), config); startErr != nil {
+		fmt.Println(startErr)
+	} else if configureErr := virtualbox.ConfigureVirtualboxVM(context.Background(), config); configureErr != nil {
+		fmt.Println(configureErr)
+	}
+	fatalLog(err)
+}
+
+func resizeCommand(ctx context.Context, args []string) {
+	flags := flag.NewFlagSet("resize", flag.ExitOnError)
+	memory := flags.String("memory", "", "New amount of memory for the minikube VM (i.e. 8000mb or 8g)")
+	cpus := flags.Int("cpus", 0, "New number of cpus for the minikube VM")
+	flags.Parse(args)
+	if *memory == "" && *cpus == 0 {
+		fatalLog(resizeUsage)
+	}
+	config, err := configuration.LoadExistingConfiguration()
+	if err != nil {
+		fatalLog(err)
+	}
+	if !config.UseVM {
+		fatalLog("The chain doesn't run in a minikube VM, so there is nothing to resize (kubernetes can use all of this machine's resources)")
+	}
+	currentMemory, currentCpus, _ := configuration.VMResources(config)
+	if *memory == "" {
+		*memory = currentMemory
+	}
+	if *cpus == 0 {
+		*cpus = currentCpus
+	}
+	memoryMB, err := configuration.ValidateVMResources(*memory, *cpus)
+	if err != nil {
+		fatalLog(err)
+	}
+	minikube.SetKubeContext(config.UseVM)
+	interrupt.SetStep("stopping the minikube cluster")
+	if err := minikube.StopMinikubeCluster(ctx); err != nil {
+		fatalLog(err)
+	}
+	interrupt.SetStep("resizing the minikube VM")
+	fmt.Println("Resizing minikube VM to " + strconv.Itoa(memoryMB) + "mb of memory and " + strconv.Itoa(*cpus) + " cpus")
+	if err := virtualbox.ResizeVM(ctx, memoryMB, *cpus); err != nil {
+		restartAfterFailedResize(config, err)
+	}
+	if err := minikube.SetProfileResources(memoryMB, *cpus); err != nil {
+		restartAfterFailedResize(config, err)
+	}
+	config.VMMemory = strconv.Itoa(memoryMB) + "mb"
+	config.VMCpus = *cpus
+	if err := configuration.SaveConfiguration(config); err != nil {
+		fatalLog(err)
+	}
+	interrupt.SetStep("starting the minikube cluster")
+	if err := minikube.StartMinikubeCluster(ctx, config); err != nil {
+		fatalLog(err)
+	}
+	interrupt.SetStep("configuring the virtualbox VM")
+	if err := virtualbox.ConfigureVirtualboxVM(ctx, config); err != nil {
+		fatalLog(err)
+	}
+	fmt.Println("Waiting for the chain to be ready")
+	interrupt.SetStep("waiting for the chain to be ready")
+	if err := dragonchain.WaitForDragonchainToBeReady(ctx, config); err != nil {
+		fatalLog(err)
+	}
+	pubID, err := dragonchain.GetDragonchainPublicID(ctx, config)
+	if err != nil {
+		fatalLog(err)
+	}
+	fmt.Println("\nChain " + pubID + " is running again with " + config.VMMemory + " of memory and " + strconv.Itoa(config.VMCpus) + " cpus")
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"time"
+
+	"github.com/dragonchain/dragonchain-installer/internal/configuration"
+	"github.com/dragonchain/dragonchain-installer/internal/interrupt"
+	"github.com/dragonchain/dragonchain-installer/internal/minikube"
+	"github.com/dragonchain/dragonchain-installer/internal/supportbundle"
+)
+
+func supportBundleCommand(ctx context.Context, args []string) {
+	flags := flag.NewFlagSet("support-bundle", flag.ExitOnError)
+	output := flags.String("o", "dragonchain-support-"+time.Now().Format("20060102-150405")+".tar.gz", "Path of the support bundle to write")
+	flags.DurationVar(&supportbundle.CommandTimeout, "command-timeout", supportbundle.CommandTimeout, "How long to wait for each command collected into the bundle")
+	flags.Parse(args)
+	config, err := configuration.LoadExistingConfiguration()
+	if err != nil {
+		// Still worth collecting everything else, i.e. when the installer failed before saving its config
+		fmt.Println(err)
+		config = nil
+	} else {
+		minikube.SetKubeContext(config.UseVM)
+	}
+	interrupt.SetStep("collecting the support bundle")
+	if err := supportbundle.Create(ctx, config, *output); err != nil {
+		fatalLog(err)
+	}
+	fmt.Print("\nSupport bundle written to " + *output + "\nSecrets have been redacted, but check its contents before sharing it\n")
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"time"
+
+	"github.com/dragonchain/dragonchain-installer/internal/chainapi"
+	"github.com/dragonchain/dragonchain-installer/internal/verify"
+)
+
+// Print how long each completed stage took
+func printStages(stages []verify.Stage) {
+	total := time.Duration(0)
+	for _, stage := range stages {
+		fmt.Printf("  %-40s %s\n", stage.Name, stage.Duration.Round(time.Millisecond))
+		total += stage.Duration
+	}
+	fmt.Printf("  %-40s %s\n", "total", total.Round(time.Millisecond))
+}
+
+func verifyCommand(ctx context.Context, args []string) {
+	flags := flag.NewFlagSet("verify", flag.ExitOnError)
+	timeout := flags.Duration("timeout", 2*time.Minute, "How long to wait for any single stage (i.e. a transaction being put in a block) to complete")
+	contract := flags.Bool("contract", false, "Also deploy a trivial smart contract through openfaas and invoke it (level 1 only)")
+	contractImage := flags.String("contract-image", "alpine:3.11", "Image for the smart contract deployed with -contract")
+	contractCmd := flags.String("contract-cmd", "cat", "Command for the smart contract deployed with -contract, which should echo its input")
+	flags.Parse(args)
+	config, pubID := loadInstalledChain(ctx)
+	if *contract && config.Level != 1 {
+		fatalLog("Smart contracts can only be verified on level 1 chains")
+	}
+	client, err := chainapi.NewClientFromCredentials(ctx, pubID)
+	if err != nil {
+		fatalLog(err)
+	}
+	stages, err := verify.Run(ctx, client, verify.Options{
+		StageTimeout:  *timeout,
+		SmartContract: *contract,
+		ContractImage: *contractImage,
+		ContractCmd:   *contractCmd,
+	})
+	fmt.Print("\nCompleted stages:\n")
+	printStages(stages)
+	if err != nil {
+		fatalLog("\nChain " + pubID + " failed verification:\n" + err.Error())
+	}
+	fmt.Print("\nChain " + pubID + " is processing transactions correctly\n")
+}
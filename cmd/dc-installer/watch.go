@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"time"
+
+	"github.com/dragonchain/dragonchain-installer/internal/configuration"
+	"github.com/dragonchain/dragonchain-installer/internal/dragonnet"
+	"github.com/dragonchain/dragonchain-installer/internal/interrupt"
+	"github.com/dragonchain/dragonchain-installer/internal/upnp"
+	"github.com/dragonchain/dragonchain-installer/internal/watchdog"
+)
+
+func watchCommand(ctx context.Context, args []string) {
+	flags := flag.NewFlagSet("watch", flag.ExitOnError)
+	interval := flags.Duration("interval", 5*time.Minute, "How often to check the chain's dragon net configuration")
+	threshold := flags.Int("failure-threshold", 3, "Number of consecutive failed checks before alerting")
+	alertCommand := flags.String("alert-command", "", "Shell command to run when alerting, with the alert in the DRAGONCHAIN_WATCH_MESSAGE environment variable")
+	flags.Parse(args)
+	if *interval <= 0 || *threshold < 1 {
+		fatalLog("Interval must be positive and failure threshold must be at least 1")
+	}
+	config, pubID := loadInstalledChain(ctx)
+	interrupt.SetStep("watching dragon net configuration")
+	onRenewError := func(err error) {
+		fmt.Println(time.Now().Format(time.RFC3339) + " Could not renew upnp port forward: " + err.Error())
+	}
+	// Keep the upnp port mapping or ipv6 pinhole from expiring while watching. Mappings recreated while watching get a lease,
+	// so that they are cleaned up once nothing renews them
+	upnp.LeaseDuration = upnp.WatchLeaseDuration
+	switch config.PortMapping {
+	case "upnp":
+		go upnp.RenewPortMapping(ctx, config.Port, onRenewError)
+	case "upnp-pinhole":
+		// Nothing else receives the connections arriving through the pinhole
+		if err := relayIPv6(ctx, config, config.Port); err != nil {
+			onRenewError(err)
+		}
+		if address, err := configuration.GetPublicIPForEndpoint(ctx, config.EndpointURL); err == nil {
+			go upnp.RenewIPv6Pinhole(ctx, config.Port, address, onRenewError)
+		} else {
+			onRenewError(err)
+		}
+	}
+	fmt.Println("Watching dragon net configuration of chain " + pubID + " every " + interval.String() + ". Press Ctrl-C to stop")
+	err := watchdog.Run(ctx, watchdog.Options{
+		PubID:            pubID,
+		Port:             config.Port,
+		EndpointURL:      config.EndpointURL,
+		Interval:         *interval,
+		FailureThreshold: *threshold,
+		AlertCommand:     *alertCommand,
+		Check:            dragonnet.CheckDragonNetConfiguration,
+		ForwardPort:      portForwarder(config),
+		PublicIP: func(ctx context.Context) (string, error) {
+			return configuration.GetPublicIPForEndpoint(ctx, config.EndpointURL)
+		},
+	})
+	if interrupt.Interrupted() {
+		// Stopping is the normal way to end watching, so don't report it as an error
+		fmt.Println("Stopped watching")
+		return
+	}
+	if err != nil {
+		fatalLog(err)
+	}
+}
@@ -7,8 +7,8 @@ require (
 	github.com/huin/goupnp v1.0.0
 	github.com/smartystreets/goconvey v1.6.4 // indirect
 	github.com/vsergeev/btckeygenie v1.0.1-0.20180404052910-413cbe3261ad
-	golang.org/x/crypto v0.0.0-20191119213627-4f8c1d86b1ba // indirect
-	golang.org/x/net v0.0.0-20191119073136-fc4aabc6c914 // indirect
+	golang.org/x/crypto v0.0.0-20191119213627-4f8c1d86b1ba
+	golang.org/x/net v0.0.0-20191119073136-fc4aabc6c914
 	golang.org/x/text v0.3.2 // indirect
 	gopkg.in/ini.v1 v1.51.0
 )
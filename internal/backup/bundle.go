@@ -0,0 +1,110 @@
+package backup
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+
+	"github.com/dragonchain/dragonchain-installer/internal/configuration"
+	"github.com/dragonchain/dragonchain-installer/internal/dragonchain"
+	"github.com/dragonchain/dragonchain-installer/internal/encryption"
+)
+
+// Bundle is everything needed to restore a chain's identity and configuration onto a fresh cluster
+type Bundle struct {
+	InstallerVersion   string            `json:"InstallerVersion"`
+	PublicID           string            `json:"PublicID"`
+	InstallationConfig json.RawMessage   `json:"InstallationConfig"`
+	ChainSecret        json.RawMessage   `json:"ChainSecret"`
+	Credentials        map[string]string `json:"Credentials"`
+}
+
+// CreateBundle collects the chain's secret, installation config, and local credentials from a running installation
+func CreateBundle(ctx context.Context, config *configuration.Configuration, pubID string) (*Bundle, error) {
+	installationConfig, err := configuration.ReadInstallationConfigFile()
+	if err != nil {
+		return nil, err
+	}
+	secret, err := dragonchain.GetDragonchainSecret(ctx, config.InternalID)
+	if err != nil {
+		return nil, err
+	}
+	credentials, err := configuration.GetDragonchainCredentials(ctx, pubID)
+	if err != nil {
+		return nil, err
+	}
+	bundle := new(Bundle)
+	bundle.InstallerVersion = configuration.Version
+	bundle.PublicID = pubID
+	bundle.InstallationConfig = installationConfig
+	bundle.ChainSecret = secret
+	bundle.Credentials = credentials
+	return bundle, nil
+}
+
+// SealBundle encrypts a bundle with passphrase
+func SealBundle(bundle *Bundle, passphrase string) ([]byte, error) {
+	bundleJSON, err := json.Marshal(bundle)
+	if err != nil {
+		return nil, err
+	}
+	return encryption.Encrypt(bundleJSON, passphrase)
+}
+
+// OpenBundle decrypts a bundle previously encrypted with SealBundle
+func OpenBundle(encrypted []byte, passphrase string) (*Bundle, error) {
+	bundleJSON, err := encryption.Decrypt(encrypted, passphrase)
+	if err != nil {
+		return nil, err
+	}
+	bundle := new(Bundle)
+	if err := json.Unmarshal(bundleJSON, bundle); err != nil {
+		return nil, errors.New("Error parsing backup contents:\n" + err.Error())
+	}
+	return bundle, nil
+}
+
+// WriteBundle encrypts a bundle with passphrase and writes it to path
+func WriteBundle(path string, bundle *Bundle, passphrase string) error {
+	encrypted, err := SealBundle(bundle, passphrase)
+	if err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(path, encrypted, 0600); err != nil {
+		return errors.New("Error writing backup file " + path + ":\n" + err.Error())
+	}
+	return nil
+}
+
+// ReadBundle reads and decrypts a bundle previously written with WriteBundle
+func ReadBundle(path string, passphrase string) (*Bundle, error) {
+	encrypted, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.New("Error reading backup file " + path + ":\n" + err.Error())
+	}
+	return OpenBundle(encrypted, passphrase)
+}
+
+// Restore writes the bundle's installation config and local credentials, returning the chain's configuration with its keys set
+// so that the chain's secret is created from them when the chain is installed
+func Restore(ctx context.Context, bundle *Bundle) (*configuration.Configuration, error) {
+	config := new(configuration.Configuration)
+	if err := json.Unmarshal(bundle.InstallationConfig, config); err != nil {
+		return nil, errors.New("Error parsing backed up installation config:\n" + err.Error())
+	}
+	if err := dragonchain.ApplyDragonchainSecret(config, bundle.ChainSecret); err != nil {
+		return nil, err
+	}
+	// Check the keys like imported ones before anything is written, so a damaged backup isn't installed
+	if err := dragonchain.ValidateImportedKeys(config); err != nil {
+		return nil, errors.New("Backup contains invalid chain keys:\n" + err.Error())
+	}
+	if err := configuration.WriteInstallationConfigFile(bundle.InstallationConfig); err != nil {
+		return nil, errors.New("Error restoring installation config:\n" + err.Error())
+	}
+	if err := configuration.SetDragonchainCredentials(ctx, bundle.PublicID, bundle.Credentials); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
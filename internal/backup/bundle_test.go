@@ -0,0 +1,100 @@
+package backup
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/dragonchain/dragonchain-installer/internal/configuration"
+	"github.com/vsergeev/btckeygenie/btckey"
+)
+
+const testChainID = "zN8xSCY1TVpNgNzGxPwj1ys7ZdvvG4nYpD6pCbmXPYRy"
+
+// Point the dragonchain configuration folder at a temporary folder, until the returned function is called
+func useConfigurationFolder(t *testing.T) func() {
+	folder, err := ioutil.TempDir("", "backup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	previousHome := os.Getenv("HOME")
+	previousAppData := os.Getenv("LOCALAPPDATA")
+	os.Setenv("HOME", folder)
+	os.Setenv("LOCALAPPDATA", folder)
+	return func() {
+		os.Setenv("HOME", previousHome)
+		os.Setenv("LOCALAPPDATA", previousAppData)
+		os.RemoveAll(folder)
+	}
+}
+
+// A bundle of a chain whose secret has the given keys
+func testBundle(t *testing.T, privateKey string, hmacID string, hmacKey string) *Bundle {
+	secret, err := json.Marshal(map[string]string{"private-key": privateKey, "hmac-id": hmacID, "hmac-key": hmacKey, "registry-password": ""})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &Bundle{
+		PublicID:           testChainID,
+		InstallationConfig: json.RawMessage(`{"Name":"test","InternalID":"8d3f2b5c-4d2a-4b0e-9c1f-2a6e7d9b0c11","Level":1}`),
+		ChainSecret:        secret,
+		Credentials:        map[string]string{"auth_key_id": hmacID, "auth_key": hmacKey, "endpoint": "http://203.0.113.7:30000"},
+	}
+}
+
+func testPrivateKey(t *testing.T) string {
+	key, err := btckey.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return base64.StdEncoding.EncodeToString(key.ToBytes())
+}
+
+func TestRestore(t *testing.T) {
+	defer useConfigurationFolder(t)()
+	privateKey := testPrivateKey(t)
+
+	config, err := Restore(context.Background(), testBundle(t, privateKey, "ABCDEFGHIJKL", "0123456789abcdefghijklmnopqrstuvwxyzABCDEFG"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.PrivateKey != privateKey || config.HmacID != "ABCDEFGHIJKL" || config.HmacKey != "0123456789abcdefghijklmnopqrstuvwxyzABCDEFG" || config.Name != "test" {
+		t.Errorf("Unexpected restored config %+v", config)
+	}
+	if _, err := configuration.ReadInstallationConfigFile(); err != nil {
+		t.Errorf("Expected the installation config to be written: %v", err)
+	}
+}
+
+func TestRestoreInvalidKeys(t *testing.T) {
+	privateKey := testPrivateKey(t)
+	cases := []struct {
+		name       string
+		privateKey string
+		hmacID     string
+		hmacKey    string
+		expected   string
+	}{
+		{"private key", "not a key", "ABCDEFGHIJKL", "0123456789abcdefghijklmnopqrstuvwxyzABCDEFG", "Backup contains invalid chain keys"},
+		{"hmac id", privateKey, `ABC","x":"`, "0123456789abcdefghijklmnopqrstuvwxyzABCDEFG", "HMAC key ID is not valid"},
+		{"hmac key", privateKey, "ABCDEFGHIJKL", `0123456789abcdefghij","registry-password":"x`, "HMAC key is not valid"},
+		{"missing hmac key", privateKey, "ABCDEFGHIJKL", "", "HMAC key is not valid"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			defer useConfigurationFolder(t)()
+			_, err := Restore(context.Background(), testBundle(t, c.privateKey, c.hmacID, c.hmacKey))
+			if err == nil || !strings.Contains(err.Error(), c.expected) {
+				t.Errorf("Expected an error containing %q, got %v", c.expected, err)
+			}
+			if _, err := configuration.ReadInstallationConfigFile(); err == nil {
+				t.Error("Expected nothing to be written for a backup with invalid keys")
+			}
+		})
+	}
+}
@@ -0,0 +1,44 @@
+package chainapi
+
+import (
+	"context"
+	"net/url"
+)
+
+// APIKey is an HMAC key for a chain's api (Key is only returned when the key is created)
+type APIKey struct {
+	ID               string `json:"id"`
+	Key              string `json:"key,omitempty"`
+	Nickname         string `json:"nickname"`
+	RegistrationTime int64  `json:"registration_time"`
+	Root             bool   `json:"root"`
+}
+
+// CreateAPIKey creates a new api key on the chain
+func (client *Client) CreateAPIKey(ctx context.Context, nickname string) (*APIKey, error) {
+	body := map[string]string{}
+	if nickname != "" {
+		body["nickname"] = nickname
+	}
+	key := new(APIKey)
+	if err := client.Request(ctx, "POST", "/v1/api-key", body, key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// ListAPIKeys lists the api keys on the chain (not including their secret keys)
+func (client *Client) ListAPIKeys(ctx context.Context) ([]APIKey, error) {
+	var list struct {
+		Keys []APIKey `json:"keys"`
+	}
+	if err := client.Request(ctx, "GET", "/v1/api-key", nil, &list); err != nil {
+		return nil, err
+	}
+	return list.Keys, nil
+}
+
+// DeleteAPIKey revokes an api key on the chain
+func (client *Client) DeleteAPIKey(ctx context.Context, keyID string) error {
+	return client.Request(ctx, "DELETE", "/v1/api-key/"+url.PathEscape(keyID), nil, nil)
+}
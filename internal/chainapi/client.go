@@ -0,0 +1,127 @@
+package chainapi
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dragonchain/dragonchain-installer/internal/configuration"
+)
+
+// ResponseError is returned when the chain responds to a request with a non-2xx status
+type ResponseError struct {
+	Method     string
+	Path       string
+	StatusCode int
+	Body       string
+}
+
+func (err *ResponseError) Error() string {
+	return "Chain responded to " + err.Method + " " + err.Path + " with status " + strconv.Itoa(err.StatusCode) + ":\n" + err.Body
+}
+
+// Client makes HMAC authenticated requests to a dragonchain's api
+type Client struct {
+	Endpoint      string
+	DragonchainID string
+	AuthKeyID     string
+	AuthKey       string
+	HTTPClient    *http.Client
+}
+
+// NewClient creates a client for the chain with public id dcID at endpoint, using the given HMAC key
+func NewClient(endpoint string, dcID string, authKeyID string, authKey string) *Client {
+	return &Client{
+		Endpoint:      strings.TrimSuffix(endpoint, "/"),
+		DragonchainID: dcID,
+		AuthKeyID:     authKeyID,
+		AuthKey:       authKey,
+		HTTPClient:    &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// NewClientFromCredentials creates a client using the chain's entry in the local credentials file
+func NewClientFromCredentials(ctx context.Context, pubID string) (*Client, error) {
+	credentials, err := configuration.GetDragonchainCredentials(ctx, pubID)
+	if err != nil {
+		return nil, err
+	}
+	if credentials["endpoint"] == "" || credentials["auth_key_id"] == "" || credentials["auth_key"] == "" {
+		return nil, errors.New("Credentials for chain " + pubID + " are missing from the local credentials file")
+	}
+	return NewClient(credentials["endpoint"], pubID, credentials["auth_key_id"], credentials["auth_key"]), nil
+}
+
+// Timestamp format expected by dragonchain (ISO 8601 in UTC)
+func timestamp(now time.Time) string {
+	return now.UTC().Format("2006-01-02T15:04:05.000000") + "Z"
+}
+
+// Signature computes the HMAC signature for a request as defined by dragonchain's DC1-HMAC-SHA256 scheme
+func Signature(authKey string, method string, path string, dcID string, timestamp string, contentType string, content []byte) string {
+	contentHash := sha256.Sum256(content)
+	message := strings.Join([]string{
+		strings.ToUpper(method),
+		path,
+		dcID,
+		timestamp,
+		contentType,
+		base64.StdEncoding.EncodeToString(contentHash[:]),
+	}, "\n")
+	mac := hmac.New(sha256.New, []byte(authKey))
+	mac.Write([]byte(message))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// Request makes an authenticated request to path (including any query string), sending body as json if not nil,
+// and parsing the json response into result if not nil
+func (client *Client) Request(ctx context.Context, method string, path string, body interface{}, result interface{}) error {
+	content := []byte{}
+	contentType := ""
+	if body != nil {
+		var err error
+		content, err = json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		contentType = "application/json"
+	}
+	req, err := http.NewRequest(method, client.Endpoint+path, bytes.NewReader(content))
+	if err != nil {
+		return err
+	}
+	now := timestamp(time.Now())
+	req.Header.Set("dragonchain", client.DragonchainID)
+	req.Header.Set("timestamp", now)
+	req.Header.Set("Authorization", "DC1-HMAC-SHA256 "+client.AuthKeyID+":"+Signature(client.AuthKey, method, path, client.DragonchainID, now, contentType, content))
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	resp, err := client.HTTPClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return errors.New("Error communicating with chain at " + client.Endpoint + ":\n" + err.Error())
+	}
+	defer resp.Body.Close()
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return errors.New("Error reading chain response body:\n" + err.Error())
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return &ResponseError{Method: method, Path: path, StatusCode: resp.StatusCode, Body: string(respBody)}
+	}
+	if result != nil {
+		if err := json.Unmarshal(respBody, result); err != nil {
+			return errors.New("Error parsing chain response:\n" + err.Error())
+		}
+	}
+	return nil
+}
@@ -0,0 +1,146 @@
+package chainapi
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const (
+	testChainID   = "zN8xSCY1TVpNgNzGxPwj1ys7ZdvvG4nYpD6pCbmXPYRy"
+	testAuthKeyID = "ABCDEFGHIJKL"
+	testAuthKey   = "0123456789abcdefghijklmnopqrstuvwxyzABCDEFG"
+)
+
+// A stand-in for a chain's api which checks the DC1-HMAC-SHA256 signature of every request (rejecting it with 401 like the
+// chain does), and then answers with handle
+type fakeChain struct {
+	server   *httptest.Server
+	lock     sync.Mutex
+	requests int
+	handle   func(w http.ResponseWriter, r *http.Request, body []byte)
+}
+
+func newFakeChain(handle func(w http.ResponseWriter, r *http.Request, body []byte)) *fakeChain {
+	chain := &fakeChain{handle: handle}
+	chain.server = httptest.NewServer(http.HandlerFunc(chain.serve))
+	return chain
+}
+
+func (chain *fakeChain) count() int {
+	chain.lock.Lock()
+	defer chain.lock.Unlock()
+	return chain.requests
+}
+
+// Compute the signature independently of Signature, from the request as the chain receives it
+func expectedSignature(r *http.Request, body []byte) string {
+	contentHash := sha256.Sum256(body)
+	message := r.Method + "\n" + r.URL.RequestURI() + "\n" + r.Header.Get("dragonchain") + "\n" + r.Header.Get("timestamp") + "\n" +
+		r.Header.Get("Content-Type") + "\n" + base64.StdEncoding.EncodeToString(contentHash[:])
+	mac := hmac.New(sha256.New, []byte(testAuthKey))
+	io.WriteString(mac, message)
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func (chain *fakeChain) serve(w http.ResponseWriter, r *http.Request) {
+	chain.lock.Lock()
+	chain.requests++
+	chain.lock.Unlock()
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		w.WriteHeader(400)
+		return
+	}
+	timestamp, err := time.Parse("2006-01-02T15:04:05.000000Z", r.Header.Get("timestamp"))
+	if err != nil || time.Since(timestamp) > time.Minute || time.Since(timestamp) < -time.Minute {
+		w.WriteHeader(401)
+		io.WriteString(w, `{"error":"bad timestamp"}`)
+		return
+	}
+	if r.Header.Get("dragonchain") != testChainID || r.Header.Get("Authorization") != "DC1-HMAC-SHA256 "+testAuthKeyID+":"+expectedSignature(r, body) {
+		w.WriteHeader(401)
+		io.WriteString(w, `{"error":"bad signature"}`)
+		return
+	}
+	chain.handle(w, r, body)
+}
+
+func TestRequestSignature(t *testing.T) {
+	chain := newFakeChain(func(w http.ResponseWriter, r *http.Request, body []byte) {
+		if r.Method == "POST" && r.Header.Get("Content-Type") != "application/json" {
+			w.WriteHeader(400)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"method": r.Method, "path": r.URL.RequestURI(), "body": string(body)})
+	})
+	defer chain.server.Close()
+	client := NewClient(chain.server.URL+"/", testChainID, testAuthKeyID, testAuthKey)
+
+	cases := []struct {
+		method string
+		path   string
+		body   interface{}
+	}{
+		{"GET", "/v1/status", nil},
+		{"GET", "/v1/transaction?q=*&limit=1", nil},
+		{"POST", "/v1/api-key", map[string]string{"nickname": "test"}},
+		{"DELETE", "/v1/api-key/ABCDEFGHIJKL", nil},
+	}
+	for _, c := range cases {
+		var result map[string]string
+		if err := client.Request(context.Background(), c.method, c.path, c.body, &result); err != nil {
+			t.Errorf("%s %s: %v", c.method, c.path, err)
+			continue
+		}
+		if result["method"] != c.method || result["path"] != c.path {
+			t.Errorf("Expected %s %s to reach the chain, got %v", c.method, c.path, result)
+		}
+		if c.body != nil && result["body"] != `{"nickname":"test"}` {
+			t.Errorf("Expected the body to be sent as json, got %q", result["body"])
+		}
+	}
+}
+
+func TestRequestRejected(t *testing.T) {
+	chain := newFakeChain(func(w http.ResponseWriter, r *http.Request, body []byte) {
+		io.WriteString(w, "{}")
+	})
+	defer chain.server.Close()
+
+	clients := map[string]*Client{
+		"wrong key":    NewClient(chain.server.URL, testChainID, testAuthKeyID, strings.ToUpper(testAuthKey)),
+		"wrong key id": NewClient(chain.server.URL, testChainID, "LKJIHGFEDCBA", testAuthKey),
+		"wrong chain":  NewClient(chain.server.URL, "other", testAuthKeyID, testAuthKey),
+	}
+	for name, client := range clients {
+		err := client.Request(context.Background(), "GET", "/v1/status", nil, nil)
+		responseErr, ok := err.(*ResponseError)
+		if !ok || responseErr.StatusCode != 401 || responseErr.Path != "/v1/status" || !strings.Contains(responseErr.Body, "bad signature") {
+			t.Errorf("%s: expected a 401 *ResponseError, got %v", name, err)
+		}
+	}
+}
+
+func TestRequestInvalidResponse(t *testing.T) {
+	chain := newFakeChain(func(w http.ResponseWriter, r *http.Request, body []byte) {
+		io.WriteString(w, "<html>not json</html>")
+	})
+	defer chain.server.Close()
+
+	var status Status
+	err := NewClient(chain.server.URL, testChainID, testAuthKeyID, testAuthKey).Request(context.Background(), "GET", "/v1/status", nil, &status)
+	if err == nil || !strings.Contains(err.Error(), "Error parsing chain response") {
+		t.Errorf("Expected a parse error, got %v", err)
+	}
+}
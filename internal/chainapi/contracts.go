@@ -0,0 +1,41 @@
+package chainapi
+
+import (
+	"context"
+	"net/url"
+)
+
+// SmartContract is a smart contract as returned by the chain
+type SmartContract struct {
+	ID      string `json:"id"`
+	TxnType string `json:"txn_type"`
+	Image   string `json:"image"`
+	Status  (struct {
+		State string `json:"state"`
+		Msg   string `json:"msg"`
+	}) `json:"status"`
+}
+
+// CreateSmartContract deploys a smart contract (level 1 only) which is invoked by transactions of txnType
+func (client *Client) CreateSmartContract(ctx context.Context, txnType string, image string, cmd string, args []string) (*SmartContract, error) {
+	body := map[string]interface{}{"version": "3", "txn_type": txnType, "image": image, "cmd": cmd, "args": args, "execution_order": "parallel"}
+	contract := new(SmartContract)
+	if err := client.Request(ctx, "POST", "/v1/contract", body, contract); err != nil {
+		return nil, err
+	}
+	return contract, nil
+}
+
+// GetSmartContract gets a smart contract by id
+func (client *Client) GetSmartContract(ctx context.Context, contractID string) (*SmartContract, error) {
+	contract := new(SmartContract)
+	if err := client.Request(ctx, "GET", "/v1/contract/"+url.PathEscape(contractID), nil, contract); err != nil {
+		return nil, err
+	}
+	return contract, nil
+}
+
+// DeleteSmartContract removes a smart contract (and its transaction type) from the chain
+func (client *Client) DeleteSmartContract(ctx context.Context, contractID string) error {
+	return client.Request(ctx, "DELETE", "/v1/contract/"+url.PathEscape(contractID), nil, nil)
+}
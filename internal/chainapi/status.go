@@ -0,0 +1,62 @@
+package chainapi
+
+import (
+	"context"
+	"errors"
+	"strconv"
+
+	"github.com/dragonchain/dragonchain-installer/internal/configuration"
+	"github.com/dragonchain/dragonchain-installer/internal/wait"
+)
+
+// Status is the response from a chain's status endpoint
+type Status struct {
+	ID              string `json:"id"`
+	Level           int    `json:"level"`
+	URL             string `json:"url"`
+	Version         string `json:"version"`
+	HashAlgo        string `json:"hashAlgo"`
+	Scheme          string `json:"scheme"`
+	EncryptionAlgo  string `json:"encryptionAlgo"`
+	IndexingEnabled bool   `json:"indexingEnabled"`
+}
+
+// GetStatus gets the status of the chain
+func (client *Client) GetStatus(ctx context.Context) (*Status, error) {
+	status := new(Status)
+	if err := client.Request(ctx, "GET", "/v1/status", nil, status); err != nil {
+		return nil, err
+	}
+	return status, nil
+}
+
+// CheckCredentials checks that the chain's api accepts requests signed with its entry in the local credentials file
+func CheckCredentials(ctx context.Context, pubID string, level int) error {
+	client, err := NewClientFromCredentials(ctx, pubID)
+	if err != nil {
+		return err
+	}
+	var status *Status
+	var lastErr error
+	err = wait.Poll(ctx, configuration.ChainAPITimeout, configuration.PollInterval, func() (bool, error) {
+		status, lastErr = client.GetStatus(ctx)
+		if responseErr, ok := lastErr.(*ResponseError); ok && (responseErr.StatusCode == 401 || responseErr.StatusCode == 403) {
+			// The chain is answering but rejecting the credentials, so waiting won't help
+			return false, errors.New("Chain rejected the credentials for " + pubID + " from the local credentials file:\n" + lastErr.Error())
+		}
+		// Otherwise the webserver may not be answering yet; keep trying
+		return lastErr == nil, nil
+	})
+	if err == wait.ErrTimeout {
+		return errors.New("Chain api at " + client.Endpoint + " did not respond successfully in time:\n" + lastErr.Error())
+	} else if err != nil {
+		return err
+	}
+	if status.ID != pubID {
+		return errors.New("Chain api at " + client.Endpoint + " reported id " + status.ID + " instead of " + pubID)
+	}
+	if status.Level != level {
+		return errors.New("Chain api at " + client.Endpoint + " reported level " + strconv.Itoa(status.Level) + " instead of " + strconv.Itoa(level))
+	}
+	return nil
+}
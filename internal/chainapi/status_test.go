@@ -0,0 +1,170 @@
+package chainapi
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dragonchain/dragonchain-installer/internal/configuration"
+)
+
+// Point the local credentials file at a temporary folder with an entry for the test chain at endpoint, and poll quickly,
+// until the returned function is called
+func useCredentials(t *testing.T, endpoint string) func() {
+	folder, err := ioutil.TempDir("", "chainapi")
+	if err != nil {
+		t.Fatal(err)
+	}
+	previousHome := os.Getenv("HOME")
+	previousAppData := os.Getenv("LOCALAPPDATA")
+	os.Setenv("HOME", folder)
+	os.Setenv("LOCALAPPDATA", folder)
+	credentialsFolder := filepath.Join(folder, ".dragonchain")
+	if configuration.Windows {
+		credentialsFolder = filepath.Join(folder, "dragonchain")
+	}
+	if err := os.MkdirAll(credentialsFolder, 0700); err != nil {
+		t.Fatal(err)
+	}
+	credentials := "[" + testChainID + "]\nauth_key_id = " + testAuthKeyID + "\nauth_key = " + testAuthKey + "\nendpoint = " + endpoint + "\n"
+	if err := ioutil.WriteFile(filepath.Join(credentialsFolder, "credentials"), []byte(credentials), 0600); err != nil {
+		t.Fatal(err)
+	}
+	previousTimeout, previousInterval := configuration.ChainAPITimeout, configuration.PollInterval
+	configuration.ChainAPITimeout, configuration.PollInterval = time.Second, 50*time.Millisecond
+	return func() {
+		configuration.ChainAPITimeout, configuration.PollInterval = previousTimeout, previousInterval
+		os.Setenv("HOME", previousHome)
+		os.Setenv("LOCALAPPDATA", previousAppData)
+		os.RemoveAll(folder)
+	}
+}
+
+// A chain which fails the first failures status requests with failureStatus, then reports status
+func statusChain(failures int, failureStatus int, status Status) *fakeChain {
+	var chain *fakeChain
+	chain = newFakeChain(func(w http.ResponseWriter, r *http.Request, body []byte) {
+		if r.URL.Path != "/v1/status" {
+			w.WriteHeader(404)
+			return
+		}
+		if chain.count() <= failures {
+			w.WriteHeader(failureStatus)
+			return
+		}
+		json.NewEncoder(w).Encode(status)
+	})
+	return chain
+}
+
+func TestCheckCredentials(t *testing.T) {
+	chain := statusChain(0, 0, Status{ID: testChainID, Level: 1})
+	defer chain.server.Close()
+	defer useCredentials(t, chain.server.URL)()
+
+	if err := CheckCredentials(context.Background(), testChainID, 1); err != nil {
+		t.Fatal(err)
+	}
+	if chain.count() != 1 {
+		t.Errorf("Expected 1 request, got %d", chain.count())
+	}
+}
+
+func TestCheckCredentialsRejected(t *testing.T) {
+	for _, status := range []int{401, 403} {
+		chain := statusChain(100, status, Status{ID: testChainID, Level: 1})
+		done := useCredentials(t, chain.server.URL)
+		err := CheckCredentials(context.Background(), testChainID, 1)
+		done()
+		chain.server.Close()
+		if err == nil || !strings.Contains(err.Error(), "Chain rejected the credentials") {
+			t.Errorf("Expected status %d to be rejected, got %v", status, err)
+		}
+		if chain.count() != 1 {
+			t.Errorf("Expected status %d to stop polling, got %d requests", status, chain.count())
+		}
+	}
+}
+
+func TestCheckCredentialsWrongKey(t *testing.T) {
+	chain := statusChain(0, 0, Status{ID: testChainID, Level: 1})
+	defer chain.server.Close()
+	defer useCredentials(t, chain.server.URL)()
+	// The signature check fails, as if the chain's secret was replaced after the credentials were installed
+	if err := configuration.SetDragonchainCredentials(context.Background(), testChainID, map[string]string{"auth_key_id": "LKJIHGFEDCBA"}); err != nil {
+		t.Fatal(err)
+	}
+
+	err := CheckCredentials(context.Background(), testChainID, 1)
+	if err == nil || !strings.Contains(err.Error(), "Chain rejected the credentials") {
+		t.Errorf("Expected the credentials to be rejected, got %v", err)
+	}
+	if chain.count() != 1 {
+		t.Errorf("Expected no retries, got %d requests", chain.count())
+	}
+}
+
+func TestCheckCredentialsPolls(t *testing.T) {
+	for _, status := range []int{404, 500, 502, 503} {
+		chain := statusChain(2, status, Status{ID: testChainID, Level: 1})
+		done := useCredentials(t, chain.server.URL)
+		err := CheckCredentials(context.Background(), testChainID, 1)
+		done()
+		chain.server.Close()
+		if err != nil {
+			t.Errorf("Expected status %d to be retried until the chain answers, got %v", status, err)
+		}
+		if chain.count() != 3 {
+			t.Errorf("Expected status %d to be retried twice, got %d requests", status, chain.count())
+		}
+	}
+}
+
+func TestCheckCredentialsTimeout(t *testing.T) {
+	chain := statusChain(1000, 503, Status{ID: testChainID, Level: 1})
+	defer chain.server.Close()
+	defer useCredentials(t, chain.server.URL)()
+
+	start := time.Now()
+	err := CheckCredentials(context.Background(), testChainID, 1)
+	if err == nil || !strings.Contains(err.Error(), "did not respond successfully in time") || !strings.Contains(err.Error(), "status 503") {
+		t.Errorf("Expected a timeout with the last error, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 3*time.Second {
+		t.Errorf("Expected to give up after ChainAPITimeout, took %s", elapsed)
+	}
+	if chain.count() < 2 {
+		t.Errorf("Expected several attempts, got %d", chain.count())
+	}
+}
+
+func TestCheckCredentialsWrongChain(t *testing.T) {
+	cases := map[string]Status{
+		"reported id":    {ID: "other", Level: 1},
+		"reported level": {ID: testChainID, Level: 2},
+	}
+	for expected, status := range cases {
+		chain := statusChain(0, 0, status)
+		done := useCredentials(t, chain.server.URL)
+		err := CheckCredentials(context.Background(), testChainID, 1)
+		done()
+		chain.server.Close()
+		if err == nil || !strings.Contains(err.Error(), expected) {
+			t.Errorf("Expected an error about the %s, got %v", expected, err)
+		}
+	}
+}
+
+func TestCheckCredentialsMissing(t *testing.T) {
+	defer useCredentials(t, "http://127.0.0.1:1")()
+
+	if err := CheckCredentials(context.Background(), "unknown", 1); err == nil || !strings.Contains(err.Error(), "missing from the local credentials file") {
+		t.Errorf("Expected missing credentials to be reported, got %v", err)
+	}
+}
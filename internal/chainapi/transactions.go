@@ -0,0 +1,66 @@
+package chainapi
+
+import (
+	"context"
+	"net/url"
+)
+
+// Transaction is a transaction as returned by the chain
+type Transaction struct {
+	Version string `json:"version"`
+	Header  (struct {
+		TxnType   string `json:"txn_type"`
+		DcID      string `json:"dc_id"`
+		TxnID     string `json:"txn_id"`
+		BlockID   string `json:"block_id"`
+		Timestamp string `json:"timestamp"`
+		Tag       string `json:"tag"`
+		Invoker   string `json:"invoker"`
+	}) `json:"header"`
+	Payload interface{} `json:"payload"`
+}
+
+// CreateTransactionType registers a new transaction type on the chain
+func (client *Client) CreateTransactionType(ctx context.Context, txnType string) error {
+	body := map[string]interface{}{"version": "1", "txn_type": txnType, "custom_indexes": []interface{}{}}
+	return client.Request(ctx, "POST", "/v1/transaction-type", body, nil)
+}
+
+// DeleteTransactionType removes a transaction type from the chain
+func (client *Client) DeleteTransactionType(ctx context.Context, txnType string) error {
+	return client.Request(ctx, "DELETE", "/v1/transaction-type/"+url.PathEscape(txnType), nil, nil)
+}
+
+// PostTransaction submits a transaction to the chain, returning its id
+func (client *Client) PostTransaction(ctx context.Context, txnType string, payload interface{}, tag string) (string, error) {
+	body := map[string]interface{}{"version": "1", "txn_type": txnType, "payload": payload, "tag": tag}
+	var result struct {
+		TransactionID string `json:"transaction_id"`
+	}
+	if err := client.Request(ctx, "POST", "/v1/transaction", body, &result); err != nil {
+		return "", err
+	}
+	return result.TransactionID, nil
+}
+
+// GetTransaction gets a transaction by id. Header.BlockID is empty until the transaction is in a block
+func (client *Client) GetTransaction(ctx context.Context, txnID string) (*Transaction, error) {
+	transaction := new(Transaction)
+	if err := client.Request(ctx, "GET", "/v1/transaction/"+url.PathEscape(txnID), nil, transaction); err != nil {
+		return nil, err
+	}
+	return transaction, nil
+}
+
+// QueryTransactions searches the chain's index of transactions of txnType with a redisearch query
+func (client *Client) QueryTransactions(ctx context.Context, txnType string, query string) ([]Transaction, error) {
+	var result struct {
+		Total   int           `json:"total"`
+		Results []Transaction `json:"results"`
+	}
+	path := "/v1/transaction?transaction_type=" + url.QueryEscape(txnType) + "&q=" + url.QueryEscape(query) + "&limit=50"
+	if err := client.Request(ctx, "GET", path, nil, &result); err != nil {
+		return nil, err
+	}
+	return result.Results, nil
+}
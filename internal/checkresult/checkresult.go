@@ -0,0 +1,44 @@
+// Package checkresult is the result of a check, shared by the preflight checks, the reachability self-test and the doctor
+package checkresult
+
+import (
+	"fmt"
+)
+
+// Statuses of a check
+const (
+	Pass = "PASS"
+	Warn = "WARN"
+	Fail = "FAIL"
+	Skip = "SKIP"
+)
+
+// Result is the outcome of one check
+type Result struct {
+	Name    string
+	Status  string
+	Details string
+}
+
+// Line formats the result as a line of a table of results
+func (result Result) Line() string {
+	return fmt.Sprintf("  %-4s %s (%s)", result.Status, result.Name, result.Details)
+}
+
+// Print displays a title followed by each result
+func Print(title string, results []Result) {
+	fmt.Print(title + ":\n")
+	for _, result := range results {
+		fmt.Println(result.Line())
+	}
+}
+
+// Failed returns whether any result failed
+func Failed(results []Result) bool {
+	for _, result := range results {
+		if result.Status == Fail {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,30 @@
+package checkresult
+
+import (
+	"testing"
+)
+
+func TestLine(t *testing.T) {
+	cases := []struct {
+		result   Result
+		expected string
+	}{
+		{Result{Name: "CPUs", Status: Pass, Details: "4 available, 2 needed"}, "  PASS CPUs (4 available, 2 needed)"},
+		{Result{Name: "Helm", Status: Skip, Details: "Cluster isn't running"}, "  SKIP Helm (Cluster isn't running)"},
+	}
+	for _, c := range cases {
+		if line := c.result.Line(); line != c.expected {
+			t.Errorf("Expected %q, got %q", c.expected, line)
+		}
+	}
+}
+
+func TestFailed(t *testing.T) {
+	passing := []Result{{Status: Pass}, {Status: Warn}, {Status: Skip}}
+	if Failed(passing) || Failed(nil) {
+		t.Error("Expected results without a failure not to have failed")
+	}
+	if !Failed(append(passing, Result{Status: Fail})) {
+		t.Error("Expected a failed result to fail")
+	}
+}
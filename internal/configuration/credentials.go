@@ -1,11 +1,13 @@
 package configuration
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
 	"strconv"
+	"strings"
 
 	"gopkg.in/ini.v1"
 )
@@ -33,15 +35,43 @@ func credentialFilePath() (string, error) {
 	return filepath.Join(credentialFolder, "credentials"), nil
 }
 
-// Ensures that the file for dragonchain credentials exists
-func ensureCredentialFile() error {
+// MaskSecret hides all but the start of a secret for printing, unless ShowSecrets is set
+func MaskSecret(secret string) string {
+	if ShowSecrets || len(secret) <= 4 {
+		return secret
+	}
+	return secret[:4] + strings.Repeat("*", len(secret)-4) + " (run with -show-secrets to print in full)"
+}
+
+// Restricts permissions of an existing file or folder so that only the current user can access it (no-op on windows)
+func restrictPermissions(path string, mode os.FileMode) error {
+	if Windows {
+		return nil
+	}
+	if err := os.Chmod(path, mode); err != nil {
+		return errors.New("Failed to restrict permissions of " + path + ":\n" + err.Error())
+	}
+	return nil
+}
+
+// Ensures that the dragonchain configuration folder exists and is only accessible by the current user
+func ensureCredentialFolder() error {
 	folder, err := credentialFolderPath()
 	if err != nil {
 		return errors.New("Could not get credential folder:\n" + err.Error())
 	}
-	if err := os.MkdirAll(folder, os.ModePerm); err != nil {
+	if err := os.MkdirAll(folder, 0700); err != nil {
 		return errors.New("Failed to create dragonchain credentials configuration folder:\n" + err.Error())
 	}
+	// MkdirAll doesn't change the permissions of a folder which already existed
+	return restrictPermissions(folder, 0700)
+}
+
+// Ensures that the file for dragonchain credentials exists
+func ensureCredentialFile() error {
+	if err := ensureCredentialFolder(); err != nil {
+		return err
+	}
 	filePath, err := credentialFilePath()
 	if err != nil {
 		return errors.New("Could not get credential file path:\n" + err.Error())
@@ -49,19 +79,20 @@ func ensureCredentialFile() error {
 	if _, err := os.Stat(filePath); err != nil {
 		if os.IsNotExist(err) {
 			// Create empty file since it does not exist
-			_, err := os.Create(filePath)
+			file, err := os.OpenFile(filePath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
 			if err != nil {
 				return errors.New("Failure to create file " + filePath + ":\n" + err.Error())
 			}
-		} else {
-			return errors.New("Could not confirm existence of " + filePath + ":\n" + err.Error())
+			return file.Close()
 		}
+		return errors.New("Could not confirm existence of " + filePath + ":\n" + err.Error())
 	}
-	return nil
+	// Files created by older versions of the installer are readable by other users
+	return restrictPermissions(filePath, 0600)
 }
 
 // InstallDragonchainCredentials installs the credentials for this dragonchain to the local config to be used by sdk/cli tool, etc
-func InstallDragonchainCredentials(config *Configuration, pubID string) error {
+func InstallDragonchainCredentials(ctx context.Context, config *Configuration, pubID string) error {
 	fmt.Println("Installing new chain credentials for local use")
 	// Make sure credentials file exists before reading it
 	if err := ensureCredentialFile(); err != nil {
@@ -76,7 +107,9 @@ func InstallDragonchainCredentials(config *Configuration, pubID string) error {
 		return errors.New("Error loading credentials file:\n" + err.Error())
 	}
 	cfg.Section(pubID).Key("auth_key_id").SetValue(config.HmacID)
-	cfg.Section(pubID).Key("auth_key").SetValue(config.HmacKey)
+	if err := setAuthKey(ctx, cfg.Section(pubID), pubID, config.HmacKey); err != nil {
+		return err
+	}
 	/* Set the endpoint to the forwarded port from the VM on localhost
 
 	In the future, we should consider allowing configuring this with the public endpoint, as using localhost won't easily support https,
@@ -91,3 +124,72 @@ func InstallDragonchainCredentials(config *Configuration, pubID string) error {
 	}
 	return nil
 }
+
+// GetDragonchainCredentials gets all of the entries in the local credentials file for a chain, with auth_key resolved from the keyring if necessary
+func GetDragonchainCredentials(ctx context.Context, pubID string) (map[string]string, error) {
+	if err := ensureCredentialFile(); err != nil {
+		return nil, err
+	}
+	credentialsFile, err := credentialFilePath()
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := ini.Load(credentialsFile)
+	if err != nil {
+		return nil, errors.New("Error loading credentials file:\n" + err.Error())
+	}
+	credentials := cfg.Section(pubID).KeysHash()
+	if _, exists := credentials["auth_key_ref"]; exists {
+		authKey, err := getAuthKey(ctx, cfg.Section(pubID), pubID)
+		if err != nil {
+			return nil, err
+		}
+		credentials["auth_key"] = authKey
+		delete(credentials, "auth_key_ref")
+	}
+	return credentials, nil
+}
+
+// GetDefaultDragonchainID gets the id of the default chain in the local credentials file (empty if not set)
+func GetDefaultDragonchainID() (string, error) {
+	if err := ensureCredentialFile(); err != nil {
+		return "", err
+	}
+	credentialsFile, err := credentialFilePath()
+	if err != nil {
+		return "", err
+	}
+	cfg, err := ini.Load(credentialsFile)
+	if err != nil {
+		return "", errors.New("Error loading credentials file:\n" + err.Error())
+	}
+	return cfg.Section("default").Key("dragonchain_id").String(), nil
+}
+
+// SetDragonchainCredentials sets entries in the local credentials file for a chain
+func SetDragonchainCredentials(ctx context.Context, pubID string, entries map[string]string) error {
+	if err := ensureCredentialFile(); err != nil {
+		return err
+	}
+	credentialsFile, err := credentialFilePath()
+	if err != nil {
+		return err
+	}
+	cfg, err := ini.Load(credentialsFile)
+	if err != nil {
+		return errors.New("Error loading credentials file:\n" + err.Error())
+	}
+	for key, value := range entries {
+		if key == "auth_key" {
+			if err := setAuthKey(ctx, cfg.Section(pubID), pubID, value); err != nil {
+				return err
+			}
+		} else if key != "auth_key_ref" {
+			cfg.Section(pubID).Key(key).SetValue(value)
+		}
+	}
+	if err := cfg.SaveTo(credentialsFile); err != nil {
+		return errors.New("Error saving credentials file " + credentialsFile + ":\n" + err.Error())
+	}
+	return nil
+}
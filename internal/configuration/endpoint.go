@@ -0,0 +1,52 @@
+package configuration
+
+import (
+	"context"
+	"net"
+	"net/url"
+)
+
+// EndpointHost formats an ip for use as the host of an endpoint url (ipv6 addresses need brackets)
+func EndpointHost(ip string) string {
+	if parsed := net.ParseIP(ip); parsed != nil && parsed.To4() == nil {
+		return "[" + ip + "]"
+	}
+	return ip
+}
+
+// EndpointIPv6Only checks whether an endpoint can only be reached over ipv6: either it is an ipv6 literal (http://[2001:db8::1]),
+// or its hostname only has AAAA records
+func EndpointIPv6Only(ctx context.Context, endpoint string) bool {
+	parsed, err := url.Parse(endpoint)
+	if err != nil || parsed.Hostname() == "" {
+		return false
+	}
+	host := parsed.Hostname()
+	if ip := net.ParseIP(host); ip != nil {
+		return ip.To4() == nil
+	}
+	addresses, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil || len(addresses) == 0 {
+		return false
+	}
+	for _, address := range addresses {
+		if address.IP.To4() != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// GetPublicIPForEndpoint gets the public ip that an endpoint should point at: the ipv6 address for ipv6 only endpoints,
+// otherwise the ipv4 address (either falls back to the other if this machine doesn't have one)
+func GetPublicIPForEndpoint(ctx context.Context, endpoint string) (string, error) {
+	ipv4, ipv6, err := PublicIPResolver().Resolve(ctx)
+	if err != nil {
+		return "", err
+	}
+	// Resolve always finds at least one of them
+	if ipv4 == nil || (ipv6 != nil && EndpointIPv6Only(ctx, endpoint)) {
+		return ipv6.String(), nil
+	}
+	return ipv4.String(), nil
+}
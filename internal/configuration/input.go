@@ -2,11 +2,12 @@ package configuration
 
 import (
 	"bufio"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io/ioutil"
-	"net/http"
+	"net"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -15,6 +16,9 @@ import (
 	"strings"
 
 	"github.com/dchest/uniuri"
+	"github.com/dragonchain/dragonchain-installer/internal/publicip"
+	"github.com/dragonchain/dragonchain-installer/internal/upnp"
+	"golang.org/x/crypto/ssh/terminal"
 )
 
 // Configuration is all of the data needed to configure a new chain
@@ -26,6 +30,10 @@ type Configuration struct {
 	InternalID        string `json:"InternalID"`
 	RegistrationToken string `json:"RegistrationToken"`
 	UseVM             bool   `json:"UseVM"`
+	PortMapping       string `json:"PortMapping,omitempty"`
+	VMMemory          string `json:"VMMemory,omitempty"`
+	VMCpus            int    `json:"VMCpus,omitempty"`
+	VMDiskSize        string `json:"VMDiskSize,omitempty"`
 	PrivateKey        string
 	HmacID            string
 	HmacKey           string
@@ -33,6 +41,8 @@ type Configuration struct {
 
 var lowerCharNum = []byte("abcdefghijklmnopqrstuvxyz0123456789")
 
+var stdinReader = bufio.NewReader(os.Stdin)
+
 func configFilePath() (string, error) {
 	credentialFolder, err := credentialFolderPath()
 	if err != nil {
@@ -60,34 +70,179 @@ func checkExistingConfig() (*Configuration, error) {
 	return existingConf, nil
 }
 
-func getPublicIP() (string, error) {
-	resp, err := http.Get("https://ifconfig.co/")
+// LoadExistingConfiguration loads the configuration saved from a previous run of the installer
+func LoadExistingConfiguration() (*Configuration, error) {
+	config, err := checkExistingConfig()
 	if err != nil {
-		return "", err
+		return nil, errors.New("Could not load existing installation config (has the installer been run yet?):\n" + err.Error())
+	}
+	return config, nil
+}
+
+// SaveConfiguration saves config as the installation config, to be reused by later runs of the installer
+func SaveConfiguration(config *Configuration) error {
+	// Keys belong in the chain's kubernetes secret, not in the config file
+	saved := *config
+	saved.PrivateKey = ""
+	saved.HmacID = ""
+	saved.HmacKey = ""
+	configJSON, err := json.Marshal(saved)
+	if err != nil {
+		return err
+	}
+	return WriteInstallationConfigFile(configJSON)
+}
+
+// ReadInstallationConfigFile reads the raw contents of the saved installation config file
+func ReadInstallationConfigFile() ([]byte, error) {
+	configFile, err := configFilePath()
+	if err != nil {
+		return nil, err
+	}
+	contents, err := ioutil.ReadFile(configFile)
+	if err != nil {
+		return nil, errors.New("Error reading installation config " + configFile + ":\n" + err.Error())
+	}
+	return contents, nil
+}
+
+// WriteInstallationConfigFile writes the raw contents of the installation config file, creating the config folder if necessary
+func WriteInstallationConfigFile(contents []byte) error {
+	if err := ensureCredentialFolder(); err != nil {
+		return err
 	}
-	defer resp.Body.Close()
-	body, err := ioutil.ReadAll(resp.Body)
+	configFile, err := configFilePath()
+	if err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(configFile, contents, 0600); err != nil {
+		return err
+	}
+	// WriteFile keeps the permissions of a file which already existed
+	return restrictPermissions(configFile, 0600)
+}
+
+// PublicIPResolver gets the resolver used to find the public ip, from the configured sources
+func PublicIPResolver() *publicip.Resolver {
+	return &publicip.Resolver{
+		HTTPProviders: PublicIPProviders,
+		STUNServers:   STUNServers,
+		Gateway:       upnp.GetExternalIPAddress,
+		Timeout:       PublicIPTimeout,
+	}
+}
+
+// GetPublicIP gets the public ip of this machine's internet connection (ipv4 if it has one)
+func GetPublicIP(ctx context.Context) (string, error) {
+	ip, err := PublicIPResolver().ResolvePreferIPv4(ctx)
 	if err != nil {
 		return "", err
 	}
-	bodyStr := string(body)
-	return strings.TrimSuffix(bodyStr, "\n"), nil
+	return ip.String(), nil
 }
 
-func getUserInput(question string) (string, error) {
-	reader := bufio.NewReader(os.Stdin)
+type userInput struct {
+	text string
+	err  error
+}
+
+func getUserInput(ctx context.Context, question string) (string, error) {
 	fmt.Print(question)
-	text, err := reader.ReadString('\n')
-	if err != nil {
-		return "", errors.New("Error reading input:\n" + err.Error())
+	return readUserInput(ctx)
+}
+
+// Read a line of input from stdin
+func readUserInput(ctx context.Context) (string, error) {
+	// Read in the background so that waiting for input can still be interrupted
+	input := make(chan userInput, 1)
+	go func() {
+		text, err := stdinReader.ReadString('\n')
+		input <- userInput{text, err}
+	}()
+	var text string
+	select {
+	case <-ctx.Done():
+		return "", ctx.Err()
+	case result := <-input:
+		if result.err != nil {
+			return "", errors.New("Error reading input:\n" + result.err.Error())
+		}
+		text = result.text
 	}
 	text = strings.TrimSuffix(text, "\r\n") // Handle windows-style newlines
 	text = strings.TrimSuffix(text, "\n")   // Handle unix-style newlines
 	return text, nil
 }
 
-func getLevel() (int, error) {
-	strLevel, err := getUserInput("What level chain would you like to create? [1-5]: ")
+// PromptForConfirmation asks the user a yes/no question, returning true if they answered yes
+func PromptForConfirmation(ctx context.Context, question string) (bool, error) {
+	answer, err := getUserInput(ctx, question+" (yes/no) ")
+	if err != nil {
+		return false, err
+	}
+	answer = strings.ToLower(answer)
+	if answer == "y" || answer == "yes" {
+		return true, nil
+	} else if answer == "n" || answer == "no" {
+		return false, nil
+	}
+	return false, errors.New("Must answer yes/no")
+}
+
+// PromptForPassphrase gets a passphrase from the user without echoing it to the terminal
+func PromptForPassphrase(ctx context.Context, question string) (string, error) {
+	stdin := int(os.Stdin.Fd())
+	// Prompt on stderr, so that the output of commands printing secrets (i.e. credentials get) stays clean
+	if !terminal.IsTerminal(stdin) {
+		// Fall back to a normal read (i.e. if input is piped in)
+		fmt.Fprint(os.Stderr, question)
+		return readUserInput(ctx)
+	}
+	state, err := terminal.GetState(stdin)
+	if err != nil {
+		return "", errors.New("Error getting terminal state:\n" + err.Error())
+	}
+	fmt.Fprint(os.Stderr, question)
+	input := make(chan userInput, 1)
+	go func() {
+		passphrase, err := terminal.ReadPassword(stdin)
+		input <- userInput{string(passphrase), err}
+	}()
+	select {
+	case <-ctx.Done():
+		// Make sure the terminal isn't left with echo disabled
+		terminal.Restore(stdin, state)
+		return "", ctx.Err()
+	case result := <-input:
+		fmt.Fprint(os.Stderr, "\n")
+		if result.err != nil {
+			return "", errors.New("Error reading passphrase:\n" + result.err.Error())
+		}
+		return result.text, nil
+	}
+}
+
+// PromptForNewPassphrase gets a new passphrase from the user, asking for it twice to confirm
+func PromptForNewPassphrase(ctx context.Context) (string, error) {
+	passphrase, err := PromptForPassphrase(ctx, "Enter a passphrase to encrypt with: ")
+	if err != nil {
+		return "", err
+	}
+	if len(passphrase) < 8 {
+		return "", errors.New("Passphrase must be at least 8 characters")
+	}
+	confirm, err := PromptForPassphrase(ctx, "Enter the passphrase again to confirm: ")
+	if err != nil {
+		return "", err
+	}
+	if passphrase != confirm {
+		return "", errors.New("Passphrases do not match")
+	}
+	return passphrase, nil
+}
+
+func getLevel(ctx context.Context) (int, error) {
+	strLevel, err := getUserInput(ctx, "What level chain would you like to create? [1-5]: ")
 	if err != nil {
 		return -1, err
 	}
@@ -101,8 +256,8 @@ func getLevel() (int, error) {
 	return int(level), nil
 }
 
-func getName() (string, error) {
-	name, err := getUserInput("What name would you like for this chain? ")
+func getName(ctx context.Context) (string, error) {
+	name, err := getUserInput(ctx, "What name would you like for this chain? ")
 	if err != nil {
 		return "", err
 	}
@@ -117,8 +272,8 @@ func getName() (string, error) {
 	return name, nil
 }
 
-func getInternalID() (string, error) {
-	internalID, err := getUserInput("Input the Chain ID for this chain (from Dragonchain console for Dragonnet support, otherwise leave empty): ")
+func getInternalID(ctx context.Context) (string, error) {
+	internalID, err := getUserInput(ctx, "Input the Chain ID for this chain (from Dragonchain console for Dragonnet support, otherwise leave empty): ")
 	if err != nil {
 		return "", err
 	}
@@ -130,8 +285,8 @@ func getInternalID() (string, error) {
 	return internalID, nil
 }
 
-func getRegistrationToken() (string, error) {
-	registrationToken, err := getUserInput("Input the matchmaking token for this chain (from Dragonchain console for Dragonnet support, otherwise leave empty): ")
+func getRegistrationToken(ctx context.Context) (string, error) {
+	registrationToken, err := getUserInput(ctx, "Input the matchmaking token for this chain (from Dragonchain console for Dragonnet support, otherwise leave empty): ")
 	if err != nil {
 		return "", err
 	}
@@ -143,8 +298,8 @@ func getRegistrationToken() (string, error) {
 	return registrationToken, nil
 }
 
-func getPort() (int, error) {
-	portStr, err := getUserInput("What port would you like to run the dragonchain on? [30000-32767]: ")
+func getPort(ctx context.Context) (int, error) {
+	portStr, err := getUserInput(ctx, "What port would you like to run the dragonchain on? [30000-32767]: ")
 	if err != nil {
 		return -1, err
 	}
@@ -164,35 +319,47 @@ func getPort() (int, error) {
 	return port, nil
 }
 
-func getEndpoint(port int) (string, error) {
-	endpoint, err := getUserInput("What endpoint would you like to broadcast that this chain is available at? (i.e. http://my.domain) (Leave blank to find your public ip and use that): ")
+// ValidateEndpoint checks that endpoint is a valid http(s) url of a dns name, ip or bracketed ipv6 address, without a port
+func ValidateEndpoint(endpoint string) error {
+	if match := regexp.MustCompile(`^https?://\[([0-9a-fA-F:.]+)\]$`).FindStringSubmatch(endpoint); match != nil {
+		if ip := net.ParseIP(match[1]); ip == nil || ip.To4() != nil {
+			return errors.New("Provided endpoint is not valid; " + match[1] + " is not an ipv6 address")
+		}
+		return nil
+	}
+	validEndpointRegex := `^http(s)?://(((([0-9]|[1-9][0-9]|1[0-9]{2}|2[0-4][0-9]|25[0-5])\.){3}([0-9]|[1-9][0-9]|1[0-9]{2}|2[0-4][0-9]|25[0-5]))|((([a-zA-Z0-9]|[a-zA-Z0-9][a-zA-Z0-9\-]*[a-zA-Z0-9])\.)*([A-Za-z0-9]|[A-Za-z0-9][A-Za-z0-9\-]*[A-Za-z0-9])))$`
+	matched, err := regexp.MatchString(validEndpointRegex, endpoint)
+	if err != nil {
+		return errors.New("Failed to perform regex " + validEndpointRegex + " on " + endpoint)
+	}
+	if !matched {
+		return errors.New("Provided endpoint is not valid; Must look something like: http://a.b (dns name, ip, or ipv6 in brackets like http://[2001:db8::1] are valid)")
+	}
+	return nil
+}
+
+func getEndpoint(ctx context.Context, port int) (string, error) {
+	endpoint, err := getUserInput(ctx, "What endpoint would you like to broadcast that this chain is available at? (i.e. http://my.domain) (Leave blank to find your public ip and use that): ")
 	if err != nil {
 		return "", err
 	}
 	if endpoint == "" {
 		// Default endpoint to auto-retrieved public ip if not provided
-		pubIP, err := getPublicIP()
+		pubIP, err := GetPublicIP(ctx)
 		if err != nil {
 			return "", errors.New("Issue getting public IP:\n" + err.Error())
 		}
 		fmt.Println("Defaulting to endpoint with public ip " + pubIP)
-		endpoint = "http://" + pubIP
-	} else {
-		validEndpointRegex := `^http(s)?://(((([0-9]|[1-9][0-9]|1[0-9]{2}|2[0-4][0-9]|25[0-5])\.){3}([0-9]|[1-9][0-9]|1[0-9]{2}|2[0-4][0-9]|25[0-5]))|((([a-zA-Z0-9]|[a-zA-Z0-9][a-zA-Z0-9\-]*[a-zA-Z0-9])\.)*([A-Za-z0-9]|[A-Za-z0-9][A-Za-z0-9\-]*[A-Za-z0-9])))$`
-		matched, err := regexp.MatchString(validEndpointRegex, endpoint)
-		if err != nil {
-			return "", errors.New("Failed to perform regex " + validEndpointRegex + " on " + endpoint)
-		}
-		if !matched {
-			return "", errors.New("Provided endpoint is not valid; Must look something like: http://a.b (dns name or ip are valid)")
-		}
+		endpoint = "http://" + EndpointHost(pubIP)
+	} else if err := ValidateEndpoint(endpoint); err != nil {
+		return "", err
 	}
 	// add selected port to the endpoint
 	endpoint += ":" + strconv.Itoa(port)
 	return endpoint, nil
 }
 
-func getVMDriver() (bool, error) {
+func getVMDriver(ctx context.Context) (bool, error) {
 	if !Linux {
 		// VM Driver must be used if not on linux
 		return true, nil
@@ -201,14 +368,14 @@ func getVMDriver() (bool, error) {
 		// VM Driver false is required if not AMD64
 		return false, nil
 	}
-	driver, err := getUserInput("Would you like to use your machine's native docker and run kubernetes outside of a VM? (yes/no) ")
+	driver, err := getUserInput(ctx, "Would you like to use your machine's native docker and run kubernetes outside of a VM? (yes/no) ")
 	if err != nil {
 		return false, err
 	}
 	driver = strings.ToLower(driver)
 	if driver == "y" || driver == "yes" {
 		// ensure docker is installed and running
-		cmd := exec.Command("sudo", "docker", "version")
+		cmd := exec.CommandContext(ctx, "sudo", "docker", "version")
 		cmd.Stderr = os.Stderr
 		cmd.Stdin = os.Stdin
 		if err := cmd.Run(); err != nil {
@@ -221,19 +388,39 @@ func getVMDriver() (bool, error) {
 	return false, errors.New("Must answer yes/no")
 }
 
+// PromptForImportedKeys gets an existing chain's private key and root HMAC key to use instead of generating new ones
+func PromptForImportedKeys(ctx context.Context, config *Configuration) error {
+	privateKey, err := getUserInput(ctx, "Input the private key of the chain to import (base64, hex, or WIF): ")
+	if err != nil {
+		return err
+	}
+	hmacID, err := getUserInput(ctx, "Input the root HMAC key ID of the chain to import: ")
+	if err != nil {
+		return err
+	}
+	hmacKey, err := getUserInput(ctx, "Input the root HMAC key of the chain to import: ")
+	if err != nil {
+		return err
+	}
+	config.PrivateKey = strings.TrimSpace(privateKey)
+	config.HmacID = strings.TrimSpace(hmacID)
+	config.HmacKey = strings.TrimSpace(hmacKey)
+	return nil
+}
+
 // PromptForUserConfiguration get user input for all the necessary configurable variables of a Dragonchain
-func PromptForUserConfiguration() (*Configuration, error) {
+func PromptForUserConfiguration(ctx context.Context) (*Configuration, error) {
 	// Check for existing configuration from previous run first
 	existingConf, err := checkExistingConfig()
 	if err == nil {
-		answer, err := getUserInput(`Existing config found:
-			Level: ` + strconv.Itoa(existingConf.Level) + `
-			Name: ` + existingConf.Name + `
-			EndpointURL: ` + existingConf.EndpointURL + `
-			Port: ` + strconv.Itoa(existingConf.Port) + `
-			ChainID: ` + existingConf.InternalID + `
-			MatchmakingToken: ` + existingConf.RegistrationToken + `
-			UseVM: ` + strconv.FormatBool(existingConf.UseVM) + `
+		answer, err := getUserInput(ctx, `Existing config found:
+			Level: `+strconv.Itoa(existingConf.Level)+`
+			Name: `+existingConf.Name+`
+			EndpointURL: `+existingConf.EndpointURL+`
+			Port: `+strconv.Itoa(existingConf.Port)+`
+			ChainID: `+existingConf.InternalID+`
+			MatchmakingToken: `+existingConf.RegistrationToken+`
+			UseVM: `+strconv.FormatBool(existingConf.UseVM)+vmResourcesDescription(existingConf)+`
 			Would you like to use this config? (yes/no) `)
 		if err != nil {
 			return nil, err
@@ -248,12 +435,12 @@ func PromptForUserConfiguration() (*Configuration, error) {
 		}
 	}
 	// Get desired vm usage
-	vmDriver, err := getVMDriver()
+	vmDriver, err := getVMDriver(ctx)
 	if err != nil {
 		return nil, err
 	}
 	// Get desired level
-	level, err := getLevel()
+	level, err := getLevel(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -261,32 +448,38 @@ func PromptForUserConfiguration() (*Configuration, error) {
 		return nil, errors.New("Level 1 chains are not supported on your cpu architecture")
 	}
 	// Get desired name
-	name, err := getName()
+	name, err := getName(ctx)
 	if err != nil {
 		return nil, err
 	}
 	// Get internal id
-	internalID, err := getInternalID()
+	internalID, err := getInternalID(ctx)
 	if err != nil {
 		return nil, err
 	}
 	// Get registration token
-	registrationToken, err := getRegistrationToken()
+	registrationToken, err := getRegistrationToken(ctx)
 	if err != nil {
 		return nil, err
 	}
 	// Get the desired port for the dragonchain
-	port, err := getPort()
+	port, err := getPort(ctx)
 	if err != nil {
 		return nil, err
 	}
 	// Get the desired endpoint
-	endpoint, err := getEndpoint(port)
+	endpoint, err := getEndpoint(ctx, port)
 	if err != nil {
 		return nil, err
 	}
 	// Construct and save the config object
 	config := new(Configuration)
+	if vmDriver {
+		// Get desired VM size
+		if err := getVMResources(ctx, config); err != nil {
+			return nil, err
+		}
+	}
 	config.Level = level
 	config.Name = name
 	config.EndpointURL = endpoint
@@ -294,22 +487,7 @@ func PromptForUserConfiguration() (*Configuration, error) {
 	config.InternalID = internalID
 	config.RegistrationToken = registrationToken
 	config.UseVM = vmDriver
-	configJSON, err := json.Marshal(config)
-	if err != nil {
-		return nil, err
-	}
-	folder, err := credentialFolderPath()
-	if err != nil {
-		return nil, err
-	}
-	if err := os.MkdirAll(folder, os.ModePerm); err != nil {
-		return nil, err
-	}
-	configFile, err := configFilePath()
-	if err != nil {
-		return nil, err
-	}
-	if err = ioutil.WriteFile(configFile, configJSON, 0664); err != nil {
+	if err := SaveConfiguration(config); err != nil {
 		return nil, err
 	}
 	return config, nil
@@ -0,0 +1,98 @@
+package configuration
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/dragonchain/dragonchain-installer/internal/keyring"
+	"gopkg.in/ini.v1"
+)
+
+// Environment variable to unlock the encrypted keyring file with instead of prompting (i.e. for non-interactive tooling)
+const keyringPassphraseEnv = "DRAGONCHAIN_KEYRING_PASSPHRASE"
+
+// Prefix of the auth_key_ref credentials entry, followed by the name of the keyring holding the chain's auth key
+const keyringRefPrefix = "keyring:"
+
+func keyringFilePath() (string, error) {
+	credentialFolder, err := credentialFolderPath()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(credentialFolder, "keyring"), nil
+}
+
+// Get the passphrase for the encrypted keyring file, asking for a new one if the file doesn't exist yet
+func keyringPassphrase(ctx context.Context, path string) func() (string, error) {
+	return func() (string, error) {
+		if passphrase, exists := os.LookupEnv(keyringPassphraseEnv); exists {
+			return passphrase, nil
+		}
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			fmt.Fprintln(os.Stderr, "No OS keyring is available, so chain credentials will be stored in an encrypted file instead")
+			return PromptForNewPassphrase(ctx)
+		}
+		return PromptForPassphrase(ctx, "Enter the passphrase for the dragonchain keyring file: ")
+	}
+}
+
+// Open the keyring to store auth keys in (or the one named by an auth_key_ref entry if name is not empty)
+func openKeyring(ctx context.Context, name string) (keyring.Store, error) {
+	if err := ensureCredentialFolder(); err != nil {
+		return nil, err
+	}
+	path, err := keyringFilePath()
+	if err != nil {
+		return nil, err
+	}
+	if name == "" {
+		return keyring.Open(path, keyringPassphrase(ctx, path)), nil
+	}
+	return keyring.ByName(name, path, keyringPassphrase(ctx, path))
+}
+
+// Sets a chain's auth key in its credentials section, or in the keyring with only a reference in the section when
+// the keyring credential store is used (or the chain's key is already in a keyring)
+func setAuthKey(ctx context.Context, section *ini.Section, pubID string, authKey string) error {
+	if CredentialStore != "keyring" && CredentialStore != "file" {
+		return errors.New("Unknown credential store '" + CredentialStore + "' (must be file or keyring)")
+	}
+	if CredentialStore == "file" && !section.HasKey("auth_key_ref") {
+		section.Key("auth_key").SetValue(authKey)
+		return nil
+	}
+	store, err := openKeyring(ctx, "")
+	if err != nil {
+		return err
+	}
+	if err := store.Set(pubID, authKey); err != nil {
+		return err
+	}
+	section.DeleteKey("auth_key")
+	section.Key("auth_key_ref").SetValue(keyringRefPrefix + store.Name())
+	return nil
+}
+
+// Gets a chain's auth key from its credentials section, resolving it from the keyring if the section has a reference
+func getAuthKey(ctx context.Context, section *ini.Section, pubID string) (string, error) {
+	ref := section.Key("auth_key_ref").String()
+	if ref == "" {
+		return section.Key("auth_key").String(), nil
+	}
+	if !strings.HasPrefix(ref, keyringRefPrefix) {
+		return "", errors.New("Unsupported auth_key_ref '" + ref + "' for chain " + pubID)
+	}
+	store, err := openKeyring(ctx, strings.TrimPrefix(ref, keyringRefPrefix))
+	if err != nil {
+		return "", err
+	}
+	authKey, err := store.Get(pubID)
+	if err == keyring.ErrNotFound {
+		return "", errors.New("Auth key for chain " + pubID + " is missing from the " + store.Name() + " keyring")
+	}
+	return authKey, err
+}
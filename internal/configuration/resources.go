@@ -0,0 +1,147 @@
+package configuration
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Smallest VM that minikube and a dragonchain can run in
+const (
+	minVMMemoryMB = 2000
+	minVMCpus     = 2
+	minVMDiskMB   = 5000
+)
+
+// ParseSizeMB parses a size like minikube accepts (i.e. 4000mb, 4g or 4000, which is in megabytes) into megabytes
+func ParseSizeMB(size string) (int, error) {
+	match := regexp.MustCompile(`^([0-9]+)(m|mb|g|gb)?$`).FindStringSubmatch(strings.ToLower(strings.TrimSpace(size)))
+	if match == nil {
+		return -1, errors.New("Couldn't parse size '" + size + "'; Must look something like 4000mb or 4g")
+	}
+	amount, err := strconv.Atoi(match[1])
+	if err != nil {
+		return -1, errors.New("Couldn't parse size '" + size + "':\n" + err.Error())
+	}
+	if strings.HasPrefix(match[2], "g") {
+		amount *= 1024
+	}
+	return amount, nil
+}
+
+// VMResources gets the memory, cpus and disk size of the chain's minikube VM, falling back to the defaults for configs saved
+// before they were configurable
+func VMResources(config *Configuration) (memory string, cpus int, diskSize string) {
+	memory, cpus, diskSize = config.VMMemory, config.VMCpus, config.VMDiskSize
+	if memory == "" {
+		memory = MinikubeVMMemory
+	}
+	if cpus == 0 {
+		cpus = MinikubeCpus
+	}
+	if diskSize == "" {
+		diskSize = MinikubeDiskSize
+	}
+	return
+}
+
+// ValidateVMResources checks that a minikube VM with this memory and cpus could run a dragonchain, returning the memory in megabytes
+func ValidateVMResources(memory string, cpus int) (int, error) {
+	memoryMB, err := ParseSizeMB(memory)
+	if err != nil {
+		return -1, err
+	}
+	if memoryMB < minVMMemoryMB {
+		return -1, errors.New("The minikube VM needs at least " + strconv.Itoa(minVMMemoryMB) + "mb of memory")
+	}
+	if cpus < minVMCpus {
+		return -1, errors.New("The minikube VM needs at least " + strconv.Itoa(minVMCpus) + " cpus")
+	}
+	return memoryMB, nil
+}
+
+func getVMMemory(ctx context.Context) (string, error) {
+	memory, err := getUserInput(ctx, "How much memory should the minikube VM have? (level 1 chains with many smart contracts may need more) ["+MinikubeVMMemory+"]: ")
+	if err != nil {
+		return "", err
+	}
+	if memory == "" {
+		fmt.Println("Defaulting memory to " + MinikubeVMMemory)
+		memory = MinikubeVMMemory
+	}
+	memoryMB, err := ParseSizeMB(memory)
+	if err != nil {
+		return "", err
+	}
+	return strconv.Itoa(memoryMB) + "mb", nil
+}
+
+func getVMCpus(ctx context.Context) (int, error) {
+	cpusStr, err := getUserInput(ctx, "How many cpus should the minikube VM have? ["+strconv.Itoa(MinikubeCpus)+"]: ")
+	if err != nil {
+		return -1, err
+	}
+	if cpusStr == "" {
+		fmt.Println("Defaulting cpus to " + strconv.Itoa(MinikubeCpus))
+		return MinikubeCpus, nil
+	}
+	cpus, err := strconv.Atoi(cpusStr)
+	if err != nil {
+		return -1, errors.New("Couldn't parse provided cpus into integer:\n" + err.Error())
+	}
+	return cpus, nil
+}
+
+func getVMDiskSize(ctx context.Context) (string, error) {
+	diskSize, err := getUserInput(ctx, "How much disk should the minikube VM have? (this can't be changed later) ["+MinikubeDiskSize+"]: ")
+	if err != nil {
+		return "", err
+	}
+	if diskSize == "" {
+		fmt.Println("Defaulting disk size to " + MinikubeDiskSize)
+		diskSize = MinikubeDiskSize
+	}
+	diskMB, err := ParseSizeMB(diskSize)
+	if err != nil {
+		return "", err
+	}
+	if diskMB < minVMDiskMB {
+		return "", errors.New("The minikube VM needs at least " + strconv.Itoa(minVMDiskMB) + "mb of disk")
+	}
+	return strconv.Itoa(diskMB) + "mb", nil
+}
+
+// Get the memory, cpus and disk size for a new minikube VM
+func getVMResources(ctx context.Context, config *Configuration) error {
+	memory, err := getVMMemory(ctx)
+	if err != nil {
+		return err
+	}
+	cpus, err := getVMCpus(ctx)
+	if err != nil {
+		return err
+	}
+	if _, err := ValidateVMResources(memory, cpus); err != nil {
+		return err
+	}
+	diskSize, err := getVMDiskSize(ctx)
+	if err != nil {
+		return err
+	}
+	config.VMMemory = memory
+	config.VMCpus = cpus
+	config.VMDiskSize = diskSize
+	return nil
+}
+
+// Describe the VM size of an existing config, lined up with the rest of the existing config prompt
+func vmResourcesDescription(config *Configuration) string {
+	if !config.UseVM {
+		return ""
+	}
+	memory, cpus, diskSize := VMResources(config)
+	return "\n\t\t\tVMMemory: " + memory + "\n\t\t\tVMCpus: " + strconv.Itoa(cpus) + "\n\t\t\tVMDiskSize: " + diskSize
+}
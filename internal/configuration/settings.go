@@ -0,0 +1,109 @@
+package configuration
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+type installerSettings struct {
+	CredentialStore   string   `json:"CredentialStore"`
+	MatchmakingURL    string   `json:"MatchmakingURL"`
+	PublicIPProviders []string `json:"PublicIPProviders"`
+	STUNServers       []string `json:"STUNServers"`
+	Timeouts          (struct {
+		DragonchainReady      string `json:"DragonchainReady"`
+		DragonchainPublicID   string `json:"DragonchainPublicID"`
+		TillerReady           string `json:"TillerReady"`
+		DragonNetRegistration string `json:"DragonNetRegistration"`
+		ChainAPI              string `json:"ChainAPI"`
+		PublicIP              string `json:"PublicIP"`
+		DockerRestart         string `json:"DockerRestart"`
+		PollInterval          string `json:"PollInterval"`
+	}) `json:"Timeouts"`
+}
+
+func settingsFilePath() (string, error) {
+	credentialFolder, err := credentialFolderPath()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(credentialFolder, "installer_settings"), nil
+}
+
+// Parse a duration string (i.e. "90s" or "5m") into target, leaving target unchanged if value is empty
+func setDuration(target *time.Duration, name string, value string) error {
+	if value == "" {
+		return nil
+	}
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		return errors.New("Invalid duration for " + name + ":\n" + err.Error())
+	}
+	if duration <= 0 {
+		return errors.New("Duration for " + name + " must be positive")
+	}
+	*target = duration
+	return nil
+}
+
+// LoadInstallerSettings applies any overrides from the optional installer_settings file in the dragonchain config folder
+func LoadInstallerSettings() error {
+	settingsFile, err := settingsFilePath()
+	if err != nil {
+		return err
+	}
+	file, err := ioutil.ReadFile(settingsFile)
+	if err != nil {
+		if os.IsNotExist(err) {
+			// Settings file is optional
+			return nil
+		}
+		return errors.New("Error reading installer settings file " + settingsFile + ":\n" + err.Error())
+	}
+	var settings installerSettings
+	if err := json.Unmarshal(file, &settings); err != nil {
+		return errors.New("Error parsing installer settings file " + settingsFile + ":\n" + err.Error())
+	}
+	if settings.CredentialStore != "" {
+		CredentialStore = settings.CredentialStore
+	}
+	if settings.MatchmakingURL != "" {
+		MatchmakingURL = settings.MatchmakingURL
+	}
+	// Empty lists are allowed, to disable a kind of source
+	if settings.PublicIPProviders != nil {
+		PublicIPProviders = settings.PublicIPProviders
+	}
+	if settings.STUNServers != nil {
+		STUNServers = settings.STUNServers
+	}
+	if err := setDuration(&DragonchainReadyTimeout, "DragonchainReady", settings.Timeouts.DragonchainReady); err != nil {
+		return err
+	}
+	if err := setDuration(&DragonchainPublicIDTimeout, "DragonchainPublicID", settings.Timeouts.DragonchainPublicID); err != nil {
+		return err
+	}
+	if err := setDuration(&TillerReadyTimeout, "TillerReady", settings.Timeouts.TillerReady); err != nil {
+		return err
+	}
+	if err := setDuration(&DragonNetRegistrationTimeout, "DragonNetRegistration", settings.Timeouts.DragonNetRegistration); err != nil {
+		return err
+	}
+	if err := setDuration(&ChainAPITimeout, "ChainAPI", settings.Timeouts.ChainAPI); err != nil {
+		return err
+	}
+	if err := setDuration(&PublicIPTimeout, "PublicIP", settings.Timeouts.PublicIP); err != nil {
+		return err
+	}
+	if err := setDuration(&DockerRestartTimeout, "DockerRestart", settings.Timeouts.DockerRestart); err != nil {
+		return err
+	}
+	if err := setDuration(&PollInterval, "PollInterval", settings.Timeouts.PollInterval); err != nil {
+		return err
+	}
+	return nil
+}
@@ -1,5 +1,9 @@
 package configuration
 
+import (
+	"time"
+)
+
 // Version is the version of this tool (changes for each release, set when compiling with the Makefile)
 var Version string
 
@@ -18,18 +22,36 @@ var RegistryIP = "10.98.76.54"
 // RegistryPort the port to use for the docker registry deployment
 var RegistryPort = 5000
 
+// MigrationHelperImage the container image used to copy chain volume data in and out of the cluster when migrating
+var MigrationHelperImage = "busybox:1.31"
+
+// MatchmakingURL base url of the dragon net matchmaking api used to check the chain's registration
+var MatchmakingURL = "https://matchmaking.api.dragonchain.com"
+
+// PublicIPProviders urls which respond with the requesting ip in plain text, used to find the public ip
+var PublicIPProviders = []string{"https://ifconfig.co/ip", "https://api.ipify.org", "https://icanhazip.com"}
+
+// STUNServers STUN servers (host:port) also used to find the public ip
+var STUNServers = []string{"stun.l.google.com:19302", "stun1.l.google.com:19302"}
+
+// PublicIPTimeout how long to wait for any one source of the public ip
+var PublicIPTimeout = 5 * time.Second
+
 // MinikubeContext the name of the minikube profile to use, which is also the kubernetes context and VM name
 var MinikubeContext = "dragonchain"
 
 // KubernetesVersion the kubernetes version to use with the dragonchain's minikube cluster
 var KubernetesVersion = "v1.15.10"
 
-// MinikubeVMMemory amount of memory to give to the minikube VM (only applicable when creating new minikube cluster)
+// MinikubeVMMemory default amount of memory to give to the minikube VM when creating a new minikube cluster (change later with the resize command)
 var MinikubeVMMemory = "4000mb"
 
-// MinikubeCpus number of cpus to give to the minikube VM (only applicable when creating new minikube cluster)
+// MinikubeCpus default number of cpus to give to the minikube VM when creating a new minikube cluster (change later with the resize command)
 var MinikubeCpus = 2
 
+// MinikubeDiskSize default disk size of the minikube VM when creating a new minikube cluster (can't be changed afterwards)
+var MinikubeDiskSize = "20000mb"
+
 // LinuxVirtualboxLink direct link for linux virtualbox installer download
 var LinuxVirtualboxLink = "https://download.virtualbox.org/virtualbox/6.1.2/VirtualBox-6.1.2-135662-Linux_amd64.run"
 
@@ -75,5 +97,39 @@ var MacosKubectlLink = "https://storage.googleapis.com/kubernetes-release/releas
 // WindowsKubectlLink direct link for windows kubectl executable
 var WindowsKubectlLink = "https://storage.googleapis.com/kubernetes-release/release/v1.17.3/bin/windows/amd64/kubectl.exe"
 
+// ImportKeys indicates whether to prompt for an existing private key and root HMAC key rather than generating new ones
+var ImportKeys = false
+
+// CredentialStore where to store chain HMAC keys: "file" (in the plain text credentials file) or "keyring" (in the OS keyring,
+// or an encrypted file if none is available, with only a reference in the credentials file)
+var CredentialStore = "file"
+
+// ShowSecrets indicates whether to print generated secrets (i.e. the root HMAC key) in full rather than masking them
+var ShowSecrets = false
+
+// SkipPreflight indicates whether to create the cluster even if this machine fails the preflight checks
+var SkipPreflight = false
+
 // SetDefaultCredentials indicates whether or not to set the default chain whe configuring the credentials ini file
 var SetDefaultCredentials = true
+
+// DragonchainReadyTimeout how long to wait for all dragonchain pods to become ready after deploying
+var DragonchainReadyTimeout = 2 * time.Minute
+
+// DragonchainPublicIDTimeout how long to wait for a running webserver pod to get the chain's public id from
+var DragonchainPublicIDTimeout = 1 * time.Minute
+
+// TillerReadyTimeout how long to wait for the tiller pod to become ready (helm 2 only)
+var TillerReadyTimeout = 1 * time.Minute
+
+// DragonNetRegistrationTimeout how long to wait for the chain to be registered with dragon net matchmaking
+var DragonNetRegistrationTimeout = 30 * time.Second
+
+// ChainAPITimeout how long to wait for the chain's api to answer an authenticated request after installing
+var ChainAPITimeout = 30 * time.Second
+
+// DockerRestartTimeout how long to wait for the cluster to respond again after restarting the docker daemon
+var DockerRestartTimeout = 1 * time.Minute
+
+// PollInterval how often to check again while waiting for any of the above
+var PollInterval = 1 * time.Second
@@ -0,0 +1,365 @@
+package doctor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dragonchain/dragonchain-installer/internal/chainapi"
+	"github.com/dragonchain/dragonchain-installer/internal/checkresult"
+	"github.com/dragonchain/dragonchain-installer/internal/configuration"
+	"github.com/dragonchain/dragonchain-installer/internal/dragonchain"
+	"github.com/dragonchain/dragonchain-installer/internal/helm"
+	"github.com/dragonchain/dragonchain-installer/internal/minikube"
+)
+
+// How long to wait for a running chain pod when checking credentials, rather than the full public id timeout
+const publicIDTimeout = 30 * time.Second
+
+// Stops walking a folder once a file owned by another user is found
+var errFoundForeignOwner = errors.New("Found file owned by another user")
+
+func homeDirs() (minikubeHome string, kubeHome string, err error) {
+	minikubeHome, err = minikube.Home()
+	if err != nil {
+		return "", "", err
+	}
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", "", errors.New("Error getting home dir:\n" + err.Error())
+	}
+	return minikubeHome, filepath.Join(homeDir, ".kube"), nil
+}
+
+func installationConfigCheck(config *configuration.Configuration, configErr error) Check {
+	return Check{
+		Name: "Installation config",
+		Run: func(ctx context.Context) (string, string) {
+			if configErr != nil {
+				return checkresult.Fail, configErr.Error()
+			}
+			return checkresult.Pass, "level " + strconv.Itoa(config.Level) + " chain " + config.Name + " (" + config.InternalID + ")"
+		},
+	}
+}
+
+// After using native docker, minikube's files can be left owned by root (i.e. if the installer was interrupted before
+// fixing them), which breaks every later minikube and kubectl command run as the user
+func ownershipCheck() Check {
+	return Check{
+		Name: "Minikube and kubectl config ownership",
+		Run: func(ctx context.Context) (string, string) {
+			minikubeHome, kubeHome, err := homeDirs()
+			if err != nil {
+				return checkresult.Warn, err.Error()
+			}
+			uid := os.Getuid()
+			if uid < 0 {
+				return checkresult.Pass, "Not applicable on this OS"
+			}
+			for _, folder := range []string{minikubeHome, kubeHome} {
+				foreign := ""
+				err := filepath.Walk(folder, func(path string, info os.FileInfo, err error) error {
+					if err != nil {
+						if os.IsPermission(err) {
+							foreign = path + " can't be read"
+							return errFoundForeignOwner
+						}
+						return err
+					}
+					if owner, ok := fileOwner(info); ok && owner != uid {
+						foreign = path + " is owned by uid " + strconv.Itoa(owner)
+						return errFoundForeignOwner
+					}
+					return nil
+				})
+				if err == errFoundForeignOwner {
+					return checkresult.Fail, foreign + " (the current user is uid " + strconv.Itoa(uid) + ")"
+				} else if err != nil && !os.IsNotExist(err) {
+					return checkresult.Warn, "Couldn't check " + folder + ": " + err.Error()
+				}
+			}
+			return checkresult.Pass, minikubeHome + " and " + kubeHome + " are owned by the current user"
+		},
+		Fix: func(ctx context.Context) error {
+			minikubeHome, kubeHome, err := homeDirs()
+			if err != nil {
+				return err
+			}
+			cmd := exec.CommandContext(ctx, "sudo", "chown", "-R", strconv.Itoa(os.Getuid())+":"+strconv.Itoa(os.Getgid()), minikubeHome, kubeHome)
+			cmd.Stderr = os.Stderr
+			cmd.Stdin = os.Stdin
+			if err := cmd.Run(); err != nil {
+				return errors.New("Was not able to chown config directories:\n" + err.Error())
+			}
+			return nil
+		},
+	}
+}
+
+// Minikube can unexpectedly fail if its profiles folder is missing: https://github.com/kubernetes/minikube/issues/5898
+func profilesFolderCheck() Check {
+	return Check{
+		Name: "Minikube profiles folder",
+		Run: func(ctx context.Context) (string, string) {
+			minikubeHome, _, err := homeDirs()
+			if err != nil {
+				return checkresult.Warn, err.Error()
+			}
+			if _, err := os.Stat(minikubeHome); os.IsNotExist(err) {
+				return checkresult.Pass, "Minikube hasn't been run yet"
+			}
+			profiles := filepath.Join(minikubeHome, "profiles")
+			if _, err := os.Stat(profiles); err != nil {
+				return checkresult.Fail, profiles + " is missing"
+			}
+			return checkresult.Pass, profiles + " exists"
+		},
+		Fix: func(ctx context.Context) error {
+			minikubeHome, _, err := homeDirs()
+			if err != nil {
+				return err
+			}
+			return os.MkdirAll(filepath.Join(minikubeHome, "profiles"), os.ModePerm)
+		},
+	}
+}
+
+func clusterCheck(config *configuration.Configuration) Check {
+	check := Check{
+		Name:      "Minikube cluster running",
+		IsCluster: true,
+		Run: func(ctx context.Context) (string, string) {
+			out, err := exec.CommandContext(ctx, "minikube", "status", "-p", configuration.MinikubeContext).CombinedOutput()
+			if err != nil {
+				status := strings.Join(strings.Fields(string(out)), " ")
+				if status == "" {
+					status = err.Error()
+				}
+				return checkresult.Fail, "Cluster '" + configuration.MinikubeContext + "' isn't running: " + status
+			}
+			return checkresult.Pass, "Cluster '" + configuration.MinikubeContext + "' is running"
+		},
+	}
+	if config != nil {
+		check.Fix = func(ctx context.Context) error {
+			return minikube.StartMinikubeCluster(ctx, config)
+		}
+	}
+	return check
+}
+
+// The installer always passes its context explicitly, but the context has to exist and point at the cluster's current ip
+func kubectlContextCheck() Check {
+	return Check{
+		Name:         "Kubectl context",
+		NeedsCluster: true,
+		Run: func(ctx context.Context) (string, string) {
+			if exec.CommandContext(ctx, "kubectl", "config", "get-contexts", configuration.MinikubeContext).Run() != nil {
+				return checkresult.Fail, "Context '" + configuration.MinikubeContext + "' is missing from the kubectl config"
+			}
+			if exec.CommandContext(ctx, "kubectl", "get", "--raw", "/healthz", "--context="+configuration.MinikubeContext).Run() != nil {
+				return checkresult.Fail, "Kubernetes api isn't answering through context '" + configuration.MinikubeContext + "' (its ip may be out of date)"
+			}
+			out, err := exec.CommandContext(ctx, "kubectl", "config", "current-context").Output()
+			if current := strings.TrimSpace(string(out)); err != nil || current != configuration.MinikubeContext {
+				return checkresult.Warn, "Context '" + configuration.MinikubeContext + "' works, but isn't the current context, so kubectl commands without --context=" + configuration.MinikubeContext + " go to another cluster"
+			}
+			return checkresult.Pass, "Context '" + configuration.MinikubeContext + "' works and is current"
+		},
+		Fix: func(ctx context.Context) error {
+			cmd := exec.CommandContext(ctx, "minikube", "update-context", "-p", configuration.MinikubeContext)
+			cmd.Stderr = os.Stderr
+			if err := cmd.Run(); err != nil {
+				return errors.New("Couldn't update kubectl context:\n" + err.Error())
+			}
+			return nil
+		},
+	}
+}
+
+func helmCheck() Check {
+	return Check{
+		Name: "Helm",
+		// Initializing helm 2 installs tiller in the cluster
+		NeedsCluster: true,
+		Run: func(ctx context.Context) (string, string) {
+			version, err := helm.GetHelmMajorVersion(ctx)
+			if err != nil {
+				return checkresult.Fail, err.Error()
+			}
+			missing, err := helm.MissingRepos(ctx)
+			if err != nil {
+				return checkresult.Fail, err.Error()
+			}
+			if len(missing) > 0 {
+				return checkresult.Fail, "Helm " + strconv.Itoa(version) + " is missing chart repositories: " + strings.Join(missing, ", ")
+			}
+			return checkresult.Pass, "Helm " + strconv.Itoa(version) + " with all chart repositories"
+		},
+		Fix: helm.InitializeHelm,
+	}
+}
+
+// Releases which hold no data of their own, so can be removed and installed again from scratch by the installer
+var reinstallableReleases = map[string]string{"registry": "registry", "openfaas": "openfaas"}
+
+// Helm 2 reports statuses like PENDING_UPGRADE, helm 3 like pending-upgrade
+func releaseStatus(release helm.Release) string {
+	return strings.Replace(strings.ToLower(release.Status), "_", "-", -1)
+}
+
+// Describes a failed or stuck release, and whether it can be removed automatically. The chain's own release is never removed:
+// uninstalling it deletes its volumes and so its ledger, while the chain often still runs on its previous revision. Pending
+// releases may be in the middle of an install or upgrade by another run of the installer
+func describeRelease(release helm.Release, internalID string) (description string, removable bool) {
+	status := releaseStatus(release)
+	description = release.Name + " (" + release.Chart + ") is " + status
+	switch {
+	case release.Name == "d-"+internalID:
+		return description + "; Roll it back to its last working revision with 'helm history " + release.Name + "' and 'helm rollback " + release.Name + " <revision>'", false
+	case strings.HasPrefix(status, "pending"):
+		return description + "; Another run of the installer may be changing it. If not, roll it back with 'helm rollback " + release.Name + " <revision>'", false
+	case reinstallableReleases[release.Name] == release.Namespace:
+		return description, true
+	}
+	return description + "; Not managed by the doctor", false
+}
+
+func helmReleasesCheck(config *configuration.Configuration) Check {
+	return Check{
+		Name:         "Helm releases",
+		NeedsCluster: true,
+		Run: func(ctx context.Context) (string, string) {
+			releases, err := helm.FailedReleases(ctx)
+			if err != nil {
+				return checkresult.Fail, err.Error()
+			}
+			if len(releases) == 0 {
+				return checkresult.Pass, "No failed or stuck releases"
+			}
+			var descriptions []string
+			anyRemovable := false
+			for _, release := range releases {
+				description, removable := describeRelease(release, config.InternalID)
+				descriptions = append(descriptions, description)
+				anyRemovable = anyRemovable || removable
+			}
+			details := strings.Join(descriptions, ", ")
+			if anyRemovable {
+				details += ". The installer won't reinstall the registry or openfaas until they are removed"
+			}
+			return checkresult.Fail, details
+		},
+		Fix: func(ctx context.Context) error {
+			releases, err := helm.FailedReleases(ctx)
+			if err != nil {
+				return err
+			}
+			removed := 0
+			for _, release := range releases {
+				if _, removable := describeRelease(release, config.InternalID); !removable {
+					continue
+				}
+				if err := helm.UninstallRelease(ctx, release); err != nil {
+					return err
+				}
+				removed++
+			}
+			if removed == 0 {
+				return errors.New("None of the releases can be removed automatically")
+			}
+			// Uninstalling doesn't put them back; the installer does
+			fmt.Println("Removed failed helm releases. Run the installer again (using the existing config) to reinstall them")
+			return nil
+		},
+	}
+}
+
+func openfaasAuthCheck(config *configuration.Configuration) Check {
+	return Check{
+		Name:         "OpenFaaS basic auth",
+		NeedsCluster: true,
+		Run: func(ctx context.Context) (string, string) {
+			if exec.CommandContext(ctx, "kubectl", "get", "namespace", "openfaas", "--context="+configuration.MinikubeContext).Run() != nil {
+				return checkresult.Warn, "OpenFaaS isn't installed (level 1 chains need it for smart contracts). Run the installer again (using the existing config) to install it"
+			}
+			var missing []string
+			if exec.CommandContext(ctx, "kubectl", "get", "secret", "basic-auth", "-n", "openfaas", "--context="+configuration.MinikubeContext).Run() != nil {
+				missing = append(missing, "basic-auth in namespace openfaas")
+			}
+			if exec.CommandContext(ctx, "kubectl", "get", "secret", "openfaas-auth", "-n", "dragonchain", "--context="+configuration.MinikubeContext).Run() != nil {
+				missing = append(missing, "openfaas-auth in namespace dragonchain")
+			}
+			if len(missing) > 0 {
+				return checkresult.Fail, "Missing secrets: " + strings.Join(missing, ", ")
+			}
+			return checkresult.Pass, "basic-auth and openfaas-auth secrets exist"
+		},
+		Fix: func(ctx context.Context) error {
+			return dragonchain.RecreateOpenfaasAuth(ctx, config)
+		},
+	}
+}
+
+func credentialsCheck(config *configuration.Configuration) Check {
+	pubID := ""
+	return Check{
+		Name:         "Chain credentials",
+		NeedsCluster: true,
+		Run: func(ctx context.Context) (string, string) {
+			idCtx, cancel := context.WithTimeout(ctx, publicIDTimeout)
+			defer cancel()
+			var err error
+			if pubID, err = dragonchain.GetDragonchainPublicID(idCtx, config); err != nil {
+				// Without the public id there is no way to find the chain's credentials
+				pubID = ""
+				return checkresult.Fail, "Couldn't get the chain's public id: " + err.Error()
+			}
+			credentials, err := configuration.GetDragonchainCredentials(ctx, pubID)
+			if err != nil {
+				return checkresult.Fail, err.Error()
+			}
+			if credentials["auth_key_id"] == "" || credentials["auth_key"] == "" {
+				return checkresult.Fail, "No credentials for chain " + pubID + " in the local credentials file"
+			}
+			if err := chainapi.CheckCredentials(ctx, pubID, config.Level); err != nil {
+				return checkresult.Fail, err.Error()
+			}
+			return checkresult.Pass, "Chain " + pubID + " accepts the local credentials"
+		},
+		Fix: func(ctx context.Context) error {
+			if pubID == "" {
+				return errors.New("The chain isn't running, so its credentials can't be restored")
+			}
+			secret, err := dragonchain.GetDragonchainSecret(ctx, config.InternalID)
+			if err != nil {
+				return err
+			}
+			if err := dragonchain.ApplyDragonchainSecret(config, secret); err != nil {
+				return err
+			}
+			return configuration.InstallDragonchainCredentials(ctx, config, pubID)
+		},
+	}
+}
+
+// Checks returns the catalogue of checks for an installation. If the installation config couldn't be loaded (configErr),
+// only the checks of this machine are run
+func Checks(config *configuration.Configuration, configErr error) []Check {
+	checks := []Check{installationConfigCheck(config, configErr), ownershipCheck(), profilesFolderCheck()}
+	if configErr != nil {
+		return checks
+	}
+	checks = append(checks, clusterCheck(config), kubectlContextCheck(), helmCheck(), helmReleasesCheck(config))
+	if config.Level == 1 {
+		checks = append(checks, openfaasAuthCheck(config))
+	}
+	return append(checks, credentialsCheck(config))
+}
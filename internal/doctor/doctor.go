@@ -0,0 +1,99 @@
+// Package doctor diagnoses (and repairs) common broken states of an installation
+package doctor
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/dragonchain/dragonchain-installer/internal/checkresult"
+)
+
+// Check is one diagnosis of the installation
+type Check struct {
+	Name string
+	// Whether the check talks to the kubernetes cluster, so is skipped when the cluster isn't running
+	NeedsCluster bool
+	// Whether this check is of the cluster running, which later checks that need the cluster depend on
+	IsCluster bool
+	// Run diagnoses the installation, returning a status and details
+	Run func(ctx context.Context) (string, string)
+	// Fix repairs what Run found wrong (nil if there is no known repair)
+	Fix func(ctx context.Context) error
+}
+
+// Result is the outcome of one check
+type Result struct {
+	checkresult.Result
+	// Whether the check has a repair which wasn't applied
+	Fixable bool
+	// Whether the check was repaired
+	Fixed bool
+}
+
+// Run runs each check in order, and if fix is set, repairs those which didn't pass and have a known repair
+func Run(ctx context.Context, checks []Check, fix bool) []Result {
+	results := make([]Result, 0, len(checks))
+	clusterRunning := true
+	for _, check := range checks {
+		if check.NeedsCluster && !clusterRunning {
+			results = append(results, Result{Result: checkresult.Result{Name: check.Name, Status: checkresult.Skip, Details: "Cluster isn't running"}})
+			continue
+		}
+		result := Result{Result: checkresult.Result{Name: check.Name}}
+		result.Status, result.Details = check.Run(ctx)
+		if result.Status != checkresult.Pass && check.Fix != nil {
+			if fix {
+				fmt.Println("Fixing: " + check.Name)
+				if err := check.Fix(ctx); err != nil {
+					result.Details += "; Fix failed: " + err.Error()
+				} else {
+					// Only count the repair if the check now passes
+					result.Status, result.Details = check.Run(ctx)
+					result.Fixed = result.Status == checkresult.Pass
+					if !result.Fixed {
+						result.Details += "; Still not passing after the fix"
+					}
+				}
+			} else {
+				result.Fixable = true
+			}
+		}
+		if check.IsCluster && result.Status == checkresult.Fail {
+			clusterRunning = false
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+// Print displays the result of each check and a summary
+func Print(results []Result) {
+	counts := map[string]int{}
+	fixable := false
+	fmt.Print("Doctor checks:\n")
+	for _, result := range results {
+		line := result.Line()
+		if result.Fixed {
+			line += " [fixed]"
+		} else if result.Fixable {
+			line += " [fixable with -fix]"
+			fixable = true
+		}
+		fmt.Println(line)
+		counts[result.Status]++
+	}
+	fmt.Printf("%d passed, %d warnings, %d failed, %d skipped\n", counts[checkresult.Pass], counts[checkresult.Warn], counts[checkresult.Fail], counts[checkresult.Skip])
+	if fixable {
+		fmt.Println("Run 'dc-installer doctor -fix' to repair the problems marked as fixable")
+	}
+}
+
+// Failed returns whether any check failed
+func Failed(results []Result) bool {
+	for _, result := range results {
+		if result.Status == checkresult.Fail {
+			return true
+		}
+	}
+	return false
+}
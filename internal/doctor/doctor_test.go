@@ -0,0 +1,114 @@
+package doctor
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/dragonchain/dragonchain-installer/internal/checkresult"
+	"github.com/dragonchain/dragonchain-installer/internal/helm"
+)
+
+// A check which reports each of statuses in turn, and counts the repairs applied
+type fakeCheck struct {
+	statuses []string
+	runs     int
+	fixes    int
+	fixErr   error
+}
+
+func (fake *fakeCheck) check(name string) Check {
+	return Check{
+		Name: name,
+		Run: func(ctx context.Context) (string, string) {
+			status := fake.statuses[fake.runs]
+			fake.runs++
+			return status, "details"
+		},
+		Fix: func(ctx context.Context) error {
+			fake.fixes++
+			return fake.fixErr
+		},
+	}
+}
+
+func TestRunFixed(t *testing.T) {
+	cases := []struct {
+		name     string
+		fake     *fakeCheck
+		status   string
+		fixed    bool
+		contains string
+	}{
+		{"passes after the fix", &fakeCheck{statuses: []string{checkresult.Fail, checkresult.Pass}}, checkresult.Pass, true, ""},
+		{"still failing after the fix", &fakeCheck{statuses: []string{checkresult.Fail, checkresult.Fail}}, checkresult.Fail, false, "Still not passing after the fix"},
+		{"warning after the fix", &fakeCheck{statuses: []string{checkresult.Fail, checkresult.Warn}}, checkresult.Warn, false, "Still not passing after the fix"},
+		{"fix fails", &fakeCheck{statuses: []string{checkresult.Fail}, fixErr: errors.New("no permission")}, checkresult.Fail, false, "Fix failed: no permission"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			results := Run(context.Background(), []Check{c.fake.check("fake")}, true)
+			if len(results) != 1 {
+				t.Fatalf("Expected 1 result, got %+v", results)
+			}
+			result := results[0]
+			if result.Status != c.status || result.Fixed != c.fixed || result.Fixable || !strings.Contains(result.Details, c.contains) {
+				t.Errorf("Unexpected result %+v", result)
+			}
+			if c.fake.fixes != 1 {
+				t.Errorf("Expected one repair, got %d", c.fake.fixes)
+			}
+		})
+	}
+}
+
+func TestRunWithoutFix(t *testing.T) {
+	fake := &fakeCheck{statuses: []string{checkresult.Fail}}
+	results := Run(context.Background(), []Check{fake.check("fake")}, false)
+	if !results[0].Fixable || results[0].Fixed || fake.fixes != 0 {
+		t.Errorf("Expected a fixable result without a repair, got %+v after %d repairs", results[0], fake.fixes)
+	}
+	if !Failed(results) {
+		t.Error("Expected the results to have failed")
+	}
+}
+
+func TestRunSkipsWithoutCluster(t *testing.T) {
+	cluster := &fakeCheck{statuses: []string{checkresult.Fail}}
+	clusterCheck := cluster.check("cluster")
+	clusterCheck.IsCluster = true
+	clusterCheck.Fix = nil
+	needsCluster := &fakeCheck{statuses: []string{checkresult.Pass}}
+	dependent := needsCluster.check("helm")
+	dependent.NeedsCluster = true
+
+	results := Run(context.Background(), []Check{clusterCheck, dependent}, false)
+	if len(results) != 2 || results[1].Status != checkresult.Skip || needsCluster.runs != 0 {
+		t.Errorf("Expected the check needing the cluster to be skipped, got %+v", results)
+	}
+}
+
+func TestDescribeRelease(t *testing.T) {
+	const internalID = "8d3f2b5c-4d2a-4b0e-9c1f-2a6e7d9b0c11"
+	cases := []struct {
+		release   helm.Release
+		removable bool
+		contains  string
+	}{
+		{helm.Release{Name: "registry", Namespace: "registry", Status: "failed", Chart: "docker-registry-1.8.3"}, true, "registry (docker-registry-1.8.3) is failed"},
+		{helm.Release{Name: "openfaas", Namespace: "openfaas", Status: "FAILED", Chart: "openfaas-5.4.1"}, true, "is failed"},
+		{helm.Release{Name: "d-" + internalID, Namespace: "dragonchain", Status: "failed", Chart: "dragonchain-k8s-1.0.9"}, false, "helm rollback d-" + internalID},
+		{helm.Release{Name: "d-" + internalID, Namespace: "dragonchain", Status: "pending-upgrade", Chart: "dragonchain-k8s-1.0.9"}, false, "helm rollback"},
+		{helm.Release{Name: "registry", Namespace: "registry", Status: "pending-install", Chart: "docker-registry-1.8.3"}, false, "Another run of the installer"},
+		{helm.Release{Name: "openfaas", Namespace: "openfaas", Status: "PENDING_UPGRADE", Chart: "openfaas-5.4.1"}, false, "is pending-upgrade"},
+		{helm.Release{Name: "registry", Namespace: "default", Status: "failed", Chart: "docker-registry-1.8.3"}, false, "Not managed"},
+		{helm.Release{Name: "d-1a2b3c4d-0000-0000-0000-000000000000", Namespace: "dragonchain", Status: "failed", Chart: "dragonchain-k8s-1.0.9"}, false, "Not managed"},
+	}
+	for _, c := range cases {
+		description, removable := describeRelease(c.release, internalID)
+		if removable != c.removable || !strings.Contains(description, c.contains) {
+			t.Errorf("Expected %+v to be removable %t with %q, got %t with %q", c.release, c.removable, c.contains, removable, description)
+		}
+	}
+}
@@ -0,0 +1,18 @@
+//go:build !windows
+// +build !windows
+
+package doctor
+
+import (
+	"os"
+	"syscall"
+)
+
+// Get the uid of a file's owner
+func fileOwner(info os.FileInfo) (int, bool) {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return -1, false
+	}
+	return int(stat.Uid), true
+}
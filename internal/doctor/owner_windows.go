@@ -0,0 +1,10 @@
+package doctor
+
+import (
+	"os"
+)
+
+// Files don't have a uid on windows (and minikube never runs with sudo there)
+func fileOwner(info os.FileInfo) (int, bool) {
+	return -1, false
+}
@@ -1,6 +1,7 @@
 package downloader
 
 import (
+	"context"
 	"errors"
 	"io"
 	"net/http"
@@ -8,16 +9,27 @@ import (
 )
 
 // DownloadFile downloads a file from url to filepath
-func DownloadFile(filepath string, url string) error {
+func DownloadFile(ctx context.Context, filepath string, url string) (err error) {
 	// Create the file
 	out, err := os.Create(filepath)
 	if err != nil {
 		return errors.New("Error creating file " + filepath + ":\n" + err.Error())
 	}
 	defer out.Close()
+	// Don't leave a partially downloaded file behind if the download fails or is interrupted
+	defer func() {
+		if err != nil {
+			out.Close()
+			os.Remove(filepath)
+		}
+	}()
 
 	// Get the data
-	resp, err := http.Get(url)
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return errors.New("Error creating request for " + url + ":\n" + err.Error())
+	}
+	resp, err := http.DefaultClient.Do(req.WithContext(ctx))
 	if err != nil {
 		return errors.New("Error retrieving data from " + url + ":\n" + err.Error())
 	}
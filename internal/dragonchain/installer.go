@@ -1,6 +1,8 @@
 package dragonchain
 
 import (
+	"bytes"
+	"context"
 	"crypto/rand"
 	"encoding/base64"
 	"encoding/json"
@@ -12,6 +14,7 @@ import (
 
 	"github.com/dchest/uniuri"
 	"github.com/dragonchain/dragonchain-installer/internal/configuration"
+	"github.com/dragonchain/dragonchain-installer/internal/interrupt"
 	"github.com/vsergeev/btckeygenie/btckey"
 )
 
@@ -25,9 +28,10 @@ type kubectlSecretJSON struct {
 }
 
 type dcSecretJSON struct {
-	PrivateKey string `json:"private-key"`
-	HmacID     string `json:"hmac-id"`
-	HmacKey    string `json:"hmac-key"`
+	PrivateKey       string `json:"private-key"`
+	HmacID           string `json:"hmac-id"`
+	HmacKey          string `json:"hmac-key"`
+	RegistryPassword string `json:"registry-password"`
 }
 
 func genRandomSecp256k1Key() (string, error) {
@@ -38,14 +42,17 @@ func genRandomSecp256k1Key() (string, error) {
 	return base64.StdEncoding.EncodeToString(priv.ToBytes()), nil
 }
 
+func genRandomHmacKey() (string, string) {
+	return uniuri.NewLenChars(12, upperChars), uniuri.NewLenChars(43, allChars)
+}
+
 func generateDragonchainSecrets() (string, string, string, error) {
 	key, err := genRandomSecp256k1Key()
 	if err != nil {
 		return "", "", "", errors.New("Error generating new private key:\n" + err.Error())
 	}
-	hmacID := uniuri.NewLenChars(12, upperChars)
-	hmacKey := uniuri.NewLenChars(43, allChars)
-	fmt.Println("Root HMAC key details: ID: " + hmacID + " | KEY: " + hmacKey)
+	hmacID, hmacKey := genRandomHmacKey()
+	fmt.Println("Root HMAC key details: ID: " + hmacID + " | KEY: " + configuration.MaskSecret(hmacKey))
 	return key, hmacID, hmacKey, nil
 }
 
@@ -53,45 +60,78 @@ func dragonchainSecretName(internalID string) string {
 	return "d-" + internalID + "-secrets"
 }
 
-func createDragonchainSecret(config *configuration.Configuration) error {
-	key, hmacID, hmacKey, err := generateDragonchainSecrets()
+// Creates (verb "create"), replaces (verb "replace") or creates or replaces (verb "apply") a kubernetes secret. The manifest is passed through stdin so that
+// secret values never appear on the command line (where other users could see them in the process list)
+func kubectlSecret(ctx context.Context, verb string, namespace string, name string, data map[string]string) error {
+	encoded := map[string]string{}
+	for key, value := range data {
+		encoded[key] = base64.StdEncoding.EncodeToString([]byte(value))
+	}
+	manifest, err := json.Marshal(map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "Secret",
+		"type":       "Opaque",
+		"metadata":   map[string]string{"name": name, "namespace": namespace},
+		"data":       encoded,
+	})
 	if err != nil {
 		return err
 	}
-	// Set secrets on configuration struct
-	config.PrivateKey = key
-	config.HmacID = hmacID
-	config.HmacKey = hmacKey
-	secretJSON := "{\"private-key\":\"" + key + "\",\"hmac-id\":\"" + hmacID + "\",\"hmac-key\":\"" + hmacKey + "\",\"registry-password\":\"\"}"
-	cmd := exec.Command("kubectl", "create", "secret", "generic", dragonchainSecretName(config.InternalID), "--from-literal=SecretString="+secretJSON, "-n", "dragonchain", "--context="+configuration.MinikubeContext)
+	cmd := exec.CommandContext(ctx, "kubectl", verb, "--context="+configuration.MinikubeContext, "-f", "-")
 	cmd.Stderr = os.Stderr
-	if err := cmd.Run(); err != nil {
+	cmd.Stdin = bytes.NewBuffer(manifest)
+	return cmd.Run()
+}
+
+func createDragonchainSecret(ctx context.Context, config *configuration.Configuration) error {
+	// Only generate new keys if they weren't imported
+	if config.PrivateKey == "" {
+		key, hmacID, hmacKey, err := generateDragonchainSecrets()
+		if err != nil {
+			return err
+		}
+		// Set secrets on configuration struct
+		config.PrivateKey = key
+		config.HmacID = hmacID
+		config.HmacKey = hmacKey
+	}
+	secretJSON, err := json.Marshal(dcSecretJSON{PrivateKey: config.PrivateKey, HmacID: config.HmacID, HmacKey: config.HmacKey})
+	if err != nil {
+		return errors.New("Error encoding dragonchain secret:\n" + err.Error())
+	}
+	if err := kubectlSecret(ctx, "create", "dragonchain", dragonchainSecretName(config.InternalID), map[string]string{"SecretString": string(secretJSON)}); err != nil {
 		return errors.New("Error adding secret for new dragonchain:\n" + err.Error())
 	}
 	return nil
 }
 
-func chainSecretExists(internalID string) bool {
-	return exec.Command("kubectl", "get", "secret", "-n", "dragonchain", dragonchainSecretName(internalID), "--context="+configuration.MinikubeContext).Run() == nil
+func chainSecretExists(ctx context.Context, internalID string) bool {
+	return exec.CommandContext(ctx, "kubectl", "get", "secret", "-n", "dragonchain", dragonchainSecretName(internalID), "--context="+configuration.MinikubeContext).Run() == nil
 }
 
-func getExistingSecret(config *configuration.Configuration) error {
-	cmd := exec.Command("kubectl", "get", "secret", "-n", "dragonchain", dragonchainSecretName(config.InternalID), "-o", "json", "--context="+configuration.MinikubeContext)
+// GetDragonchainSecret gets the decoded contents of an existing chain's kubernetes secret
+func GetDragonchainSecret(ctx context.Context, internalID string) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, "kubectl", "get", "secret", "-n", "dragonchain", dragonchainSecretName(internalID), "-o", "json", "--context="+configuration.MinikubeContext)
 	cmd.Stderr = os.Stderr
 	output, err := cmd.Output()
 	if err != nil {
-		return errors.New("Error retrieving existing dragonchain secret:\n" + err.Error())
+		return nil, errors.New("Error retrieving existing dragonchain secret:\n" + err.Error())
 	}
 	var kubeSecret kubectlSecretJSON
 	if err := json.Unmarshal(output, &kubeSecret); err != nil {
-		return errors.New("Error parsing dragonchain secret:\n" + err.Error())
+		return nil, errors.New("Error parsing dragonchain secret:\n" + err.Error())
 	}
 	decoded, err := base64.StdEncoding.DecodeString(kubeSecret.Data.SecretString)
 	if err != nil {
-		return errors.New("Error decoding base64 secret value:\n" + err.Error())
+		return nil, errors.New("Error decoding base64 secret value:\n" + err.Error())
 	}
+	return decoded, nil
+}
+
+// ApplyDragonchainSecret sets the keys from the contents of a chain's secret on config
+func ApplyDragonchainSecret(config *configuration.Configuration, secret []byte) error {
 	var dcSecrets dcSecretJSON
-	if err := json.Unmarshal(decoded, &dcSecrets); err != nil {
+	if err := json.Unmarshal(secret, &dcSecrets); err != nil {
 		return errors.New("Error parsing dragonchain secret:\n" + err.Error())
 	}
 	config.PrivateKey = dcSecrets.PrivateKey
@@ -100,13 +140,21 @@ func getExistingSecret(config *configuration.Configuration) error {
 	return nil
 }
 
-func upsertDragonchainHelmDeployment(config *configuration.Configuration) error {
+func getExistingSecret(ctx context.Context, config *configuration.Configuration) error {
+	secret, err := GetDragonchainSecret(ctx, config.InternalID)
+	if err != nil {
+		return err
+	}
+	return ApplyDragonchainSecret(config, secret)
+}
+
+func upsertDragonchainHelmDeployment(ctx context.Context, config *configuration.Configuration) error {
 	setStringStr := "global.environment.LEVEL=" + strconv.Itoa(config.Level)
 	setStr := "dragonchain.storage.spec.storageClassName=local-path,redis.storage.spec.storageClassName=local-path,redisearch.storage.spec.storageClassName=local-path,global.environment.DRAGONCHAIN_NAME=" + config.Name + ",global.environment.REGISTRATION_TOKEN=" + config.RegistrationToken + ",global.environment.INTERNAL_ID=" + config.InternalID + ",global.environment.DRAGONCHAIN_ENDPOINT=" + config.EndpointURL + ",service.port=" + strconv.Itoa(config.Port)
 	if config.Level == 1 {
 		setStr += ",faas.gateway=http://gateway.openfaas:8080,faas.mountFaasSecret=true,faas.registry=" + configuration.RegistryIP + ":" + strconv.Itoa(configuration.RegistryPort)
 	}
-	cmd := exec.Command("helm", "upgrade", "--install", "d-"+config.InternalID, "dragonchain/dragonchain-k8s", "--namespace", "dragonchain", "--set-string", setStringStr, "--set", setStr, "--version", configuration.DragonchainHelmVersion, "--kube-context", configuration.MinikubeContext)
+	cmd := exec.CommandContext(ctx, "helm", "upgrade", "--install", "d-"+config.InternalID, "dragonchain/dragonchain-k8s", "--namespace", "dragonchain", "--set-string", setStringStr, "--set", setStr, "--version", configuration.DragonchainHelmVersion, "--kube-context", configuration.MinikubeContext)
 	cmd.Stderr = os.Stderr
 	if err := cmd.Run(); err != nil {
 		return errors.New("Error installing dragonchain helm chart:\n" + err.Error())
@@ -114,27 +162,59 @@ func upsertDragonchainHelmDeployment(config *configuration.Configuration) error
 	return nil
 }
 
+// UpdateDragonchainDeployment applies changes to config (i.e. a new endpoint) to an installed chain and waits for it to be ready again
+func UpdateDragonchainDeployment(ctx context.Context, config *configuration.Configuration) error {
+	if err := upsertDragonchainHelmDeployment(ctx, config); err != nil {
+		return err
+	}
+	fmt.Println("Dragonchain helm deployment updated. Waiting for chain to be ready.")
+	err := WaitForDragonchainToBeReady(ctx, config)
+	fmt.Print("\n")
+	return err
+}
+
 // InstallDragonchain installs the kubernetes resources for the dragonchain (and upgrades if it already exists)
-func InstallDragonchain(config *configuration.Configuration) error {
+func InstallDragonchain(ctx context.Context, config *configuration.Configuration) error {
 	// Ensure kubernetes secret exists for this dragonchain
-	if chainSecretExists(config.InternalID) {
+	if chainSecretExists(ctx, config.InternalID) {
 		fmt.Println("Existing dragonchain secret for this id already exists. Reusing")
-		if err := getExistingSecret(config); err != nil {
+		imported := *config
+		if err := getExistingSecret(ctx, config); err != nil {
+			return err
+		}
+		if imported.PrivateKey != "" && (imported.PrivateKey != config.PrivateKey || imported.HmacID != config.HmacID || imported.HmacKey != config.HmacKey) {
+			return errors.New("A different secret already exists for chain ID " + config.InternalID + ". Delete it with 'kubectl delete secret " + dragonchainSecretName(config.InternalID) + " -n dragonchain --context=" + configuration.MinikubeContext + "' in order to import keys")
+		}
+	} else if config.PrivateKey != "" {
+		fmt.Println("Creating secret for this dragonchain id from imported keys")
+		if err := createDragonchainSecret(ctx, config); err != nil {
 			return err
 		}
 	} else {
 		fmt.Println("Creating new secret for this dragonchain id")
-		if err := createDragonchainSecret(config); err != nil {
+		if err := createDragonchainSecret(ctx, config); err != nil {
 			return err
 		}
 	}
 	// Actually install (or upgrade) the chain
-	if err := upsertDragonchainHelmDeployment(config); err != nil {
+	exists, err := doesHelmDeploymentExist(ctx, "d-"+config.InternalID, "dragonchain")
+	if err != nil {
+		return errors.New("Error checking for existing dragonchain installation:\n" + err.Error())
+	}
+	done := func() {}
+	if !exists {
+		// Only remove the deployment on interrupt if it's new; the chain's secret is kept so its identity is reused on the next run
+		done = interrupt.OnInterrupt("removing partial dragonchain deployment", func() {
+			removeHelmDeployment("d-"+config.InternalID, "dragonchain")
+		})
+	}
+	if err := upsertDragonchainHelmDeployment(ctx, config); err != nil {
 		return err
 	}
+	done()
 	fmt.Println("Dragonchain helm deployment complete. Waiting for chain to be ready.")
 	// Wait for the deployment to be ready before continuing
-	err := waitForDragonchainToBeReady(config)
+	err = WaitForDragonchainToBeReady(ctx, config)
 	fmt.Print("\n")
 	if err != nil {
 		return err
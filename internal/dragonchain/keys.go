@@ -0,0 +1,137 @@
+package dragonchain
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"os/exec"
+	"regexp"
+
+	"github.com/dragonchain/dragonchain-installer/internal/configuration"
+	"github.com/vsergeev/btckeygenie/btckey"
+)
+
+// Order of the secp256k1 curve; valid private keys must be in the range [1, n-1]
+var secp256k1N, _ = new(big.Int).SetString("FFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFEBAAEDCE6AF48A03BBFD25E8CD0364141", 16)
+
+var hmacIDRegex = regexp.MustCompile(`^[A-Za-z0-9]{1,64}$`)
+var hmacKeyRegex = regexp.MustCompile(`^[A-Za-z0-9]{20,128}$`)
+
+// Decodes a private key, returning an error rather than panicking for keys outside the valid range
+func decodePrivateKey(decode func(priv *btckey.PrivateKey) error) (priv btckey.PrivateKey, err error) {
+	// btckey panics when deriving the public key of an out of range private key
+	defer func() {
+		if recover() != nil {
+			err = errors.New("Private key is not in the valid range for secp256k1")
+		}
+	}()
+	if err := decode(&priv); err != nil {
+		return priv, err
+	}
+	if priv.D.Sign() <= 0 || priv.D.Cmp(secp256k1N) >= 0 {
+		return priv, errors.New("Private key is not in the valid range for secp256k1")
+	}
+	return priv, nil
+}
+
+// Parses a secp256k1 private key in base64 (as generated by this installer), hex, or WIF format into the base64 format used by dragonchain
+func normalizePrivateKey(key string) (string, error) {
+	var decode func(priv *btckey.PrivateKey) error
+	if valid, _ := btckey.CheckWIF(key); valid {
+		decode = func(priv *btckey.PrivateKey) error { return priv.FromWIF(key) }
+	} else if raw, err := hex.DecodeString(key); err == nil && len(raw) == 32 {
+		decode = func(priv *btckey.PrivateKey) error { return priv.FromBytes(raw) }
+	} else if raw, err := base64.StdEncoding.DecodeString(key); err == nil && len(raw) == 32 {
+		decode = func(priv *btckey.PrivateKey) error { return priv.FromBytes(raw) }
+	} else {
+		return "", errors.New("Private key must be a 32 byte secp256k1 key encoded as base64, hex, or WIF")
+	}
+	priv, err := decodePrivateKey(decode)
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(priv.ToBytes()), nil
+}
+
+// ValidateImportedKeys validates the private key and root HMAC key set on config to be imported, converting the private key into base64
+func ValidateImportedKeys(config *configuration.Configuration) error {
+	key, err := normalizePrivateKey(config.PrivateKey)
+	if err != nil {
+		return err
+	}
+	if !hmacIDRegex.MatchString(config.HmacID) {
+		return errors.New("HMAC key ID is not valid; Must match regex: " + hmacIDRegex.String())
+	}
+	if !hmacKeyRegex.MatchString(config.HmacKey) {
+		return errors.New("HMAC key is not valid; Must match regex: " + hmacKeyRegex.String())
+	}
+	config.PrivateKey = key
+	return nil
+}
+
+// Restarts a chain's deployments (so they pick up changes to its secret) and waits for the rollout to finish
+func restartDragonchain(ctx context.Context, config *configuration.Configuration) error {
+	deployments, err := getChainResources(ctx, config, "deployment")
+	if err != nil {
+		return err
+	}
+	for _, deployment := range deployments.Items {
+		if !belongsToChain(deployment.Metadata.Name, config) {
+			continue
+		}
+		cmd := exec.CommandContext(ctx, "kubectl", "rollout", "restart", "deployment/"+deployment.Metadata.Name, "-n", "dragonchain", "--context="+configuration.MinikubeContext)
+		cmd.Stderr = os.Stderr
+		if err := cmd.Run(); err != nil {
+			return errors.New("Error restarting deployment " + deployment.Metadata.Name + ":\n" + err.Error())
+		}
+	}
+	for _, deployment := range deployments.Items {
+		if !belongsToChain(deployment.Metadata.Name, config) {
+			continue
+		}
+		cmd := exec.CommandContext(ctx, "kubectl", "rollout", "status", "deployment/"+deployment.Metadata.Name, "-n", "dragonchain", "--timeout="+configuration.DragonchainReadyTimeout.String(), "--context="+configuration.MinikubeContext)
+		cmd.Stderr = os.Stderr
+		if err := cmd.Run(); err != nil {
+			return errors.New("Deployment " + deployment.Metadata.Name + " failed to restart. Check kubernetes cluster for more information:\n" + err.Error())
+		}
+	}
+	return nil
+}
+
+// RotateRootHmacKey replaces a chain's root HMAC key with a newly generated one and restarts the chain to use it.
+// The new key is set on config, and saveCredentials is called as soon as it replaces the old one (before restarting, which may fail),
+// so that the new key isn't lost
+func RotateRootHmacKey(ctx context.Context, config *configuration.Configuration, saveCredentials func() error) error {
+	secret, err := GetDragonchainSecret(ctx, config.InternalID)
+	if err != nil {
+		return err
+	}
+	// Keep any other fields of the secret as they are
+	var secretFields map[string]interface{}
+	if err := json.Unmarshal(secret, &secretFields); err != nil {
+		return errors.New("Error parsing dragonchain secret:\n" + err.Error())
+	}
+	hmacID, hmacKey := genRandomHmacKey()
+	secretFields["hmac-id"] = hmacID
+	secretFields["hmac-key"] = hmacKey
+	secret, err = json.Marshal(secretFields)
+	if err != nil {
+		return err
+	}
+	if err := kubectlSecret(ctx, "replace", "dragonchain", dragonchainSecretName(config.InternalID), map[string]string{"SecretString": string(secret)}); err != nil {
+		return errors.New("Error updating dragonchain secret:\n" + err.Error())
+	}
+	config.HmacID = hmacID
+	config.HmacKey = hmacKey
+	fmt.Println("New root HMAC key details: ID: " + hmacID + " | KEY: " + configuration.MaskSecret(hmacKey))
+	if err := saveCredentials(); err != nil {
+		return errors.New("Error saving the new root HMAC key. It can still be read from the kubernetes secret " + dragonchainSecretName(config.InternalID) + " in the dragonchain namespace:\n" + err.Error())
+	}
+	fmt.Println("Restarting chain to use the new root HMAC key")
+	return restartDragonchain(ctx, config)
+}
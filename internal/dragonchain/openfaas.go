@@ -2,6 +2,7 @@ package dragonchain
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"os"
 	"os/exec"
@@ -60,13 +61,13 @@ roleRef:
   name: openfaas-builder
   apiGroup: rbac.authorization.k8s.io`)
 
-func openfaasServiceAccountExists() bool {
-	return exec.Command("kubectl", "get", "serviceaccount", "-n", "dragonchain", "openfaas-builder", "--context="+configuration.MinikubeContext).Run() == nil
+func openfaasServiceAccountExists(ctx context.Context) bool {
+	return exec.CommandContext(ctx, "kubectl", "get", "serviceaccount", "-n", "dragonchain", "openfaas-builder", "--context="+configuration.MinikubeContext).Run() == nil
 }
 
-func createOpenFaasDeployment() error {
+func createOpenFaasDeployment(ctx context.Context) error {
 	// Create the necessary namespaces
-	cmd := exec.Command("kubectl", "apply", "--context="+configuration.MinikubeContext, "-f", "-")
+	cmd := exec.CommandContext(ctx, "kubectl", "apply", "--context="+configuration.MinikubeContext, "-f", "-")
 	cmd.Stderr = os.Stderr
 	cmd.Stdin = bytes.NewBuffer(openfaasNamespacesYaml)
 	if err := cmd.Run(); err != nil {
@@ -74,18 +75,14 @@ func createOpenFaasDeployment() error {
 	}
 	// Create the basic auth secrets
 	secret := uniuri.NewLen(40)
-	cmd = exec.Command("kubectl", "create", "secret", "generic", "basic-auth", "--from-literal=basic-auth-user=admin", "--from-literal=basic-auth-password="+secret, "-n", "openfaas", "--context="+configuration.MinikubeContext)
-	cmd.Stderr = os.Stderr
-	if err := cmd.Run(); err != nil {
+	if err := kubectlSecret(ctx, "create", "openfaas", "basic-auth", map[string]string{"basic-auth-user": "admin", "basic-auth-password": secret}); err != nil {
 		return errors.New("Error creating openfaas kubernetes secret:\n" + err.Error())
 	}
-	cmd = exec.Command("kubectl", "create", "secret", "generic", "openfaas-auth", "--from-literal=user=admin", "--from-literal=password="+secret, "-n", "dragonchain", "--context="+configuration.MinikubeContext)
-	cmd.Stderr = os.Stderr
-	if err := cmd.Run(); err != nil {
+	if err := kubectlSecret(ctx, "create", "dragonchain", "openfaas-auth", map[string]string{"user": "admin", "password": secret}); err != nil {
 		return errors.New("Error creating openfaas kubernetes secret:\n" + err.Error())
 	}
 	// Install openfaas
-	cmd = exec.Command("helm", "upgrade", "--install", "openfaas", "openfaas/openfaas", "--namespace", "openfaas", "--set", "basic_auth=true,generateBasicAuth=false,functionNamespace=openfaas-fn,async=false,exposeServices=false,alertmanager.create=false,prometheus.create=false", "--version", configuration.OpenfaasHelmVersion, "--kube-context", configuration.MinikubeContext)
+	cmd = exec.CommandContext(ctx, "helm", "upgrade", "--install", "openfaas", "openfaas/openfaas", "--namespace", "openfaas", "--set", "basic_auth=true,generateBasicAuth=false,functionNamespace=openfaas-fn,async=false,exposeServices=false,alertmanager.create=false,prometheus.create=false", "--version", configuration.OpenfaasHelmVersion, "--kube-context", configuration.MinikubeContext)
 	cmd.Stderr = os.Stderr
 	if err := cmd.Run(); err != nil {
 		return errors.New("Error helm deploying openfaas:\n" + err.Error())
@@ -93,9 +90,9 @@ func createOpenFaasDeployment() error {
 	return nil
 }
 
-func createOpenfaasBuilderServiceAccount() error {
+func createOpenfaasBuilderServiceAccount(ctx context.Context) error {
 	// Add the service account
-	cmd := exec.Command("kubectl", "apply", "--context="+configuration.MinikubeContext, "-f", "-")
+	cmd := exec.CommandContext(ctx, "kubectl", "apply", "--context="+configuration.MinikubeContext, "-f", "-")
 	cmd.Stderr = os.Stderr
 	cmd.Stdin = bytes.NewBuffer(serviceAccountYaml)
 	if err := cmd.Run(); err != nil {
@@ -103,3 +100,21 @@ func createOpenfaasBuilderServiceAccount() error {
 	}
 	return nil
 }
+
+// RecreateOpenfaasAuth replaces the openfaas basic auth secrets with a new password (i.e. when one of them was lost),
+// then restarts the openfaas gateway and the chain to use it
+func RecreateOpenfaasAuth(ctx context.Context, config *configuration.Configuration) error {
+	secret := uniuri.NewLen(40)
+	if err := kubectlSecret(ctx, "apply", "openfaas", "basic-auth", map[string]string{"basic-auth-user": "admin", "basic-auth-password": secret}); err != nil {
+		return errors.New("Error updating openfaas kubernetes secret:\n" + err.Error())
+	}
+	if err := kubectlSecret(ctx, "apply", "dragonchain", "openfaas-auth", map[string]string{"user": "admin", "password": secret}); err != nil {
+		return errors.New("Error updating openfaas kubernetes secret:\n" + err.Error())
+	}
+	cmd := exec.CommandContext(ctx, "kubectl", "rollout", "restart", "deployment/gateway", "-n", "openfaas", "--context="+configuration.MinikubeContext)
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return errors.New("Error restarting openfaas gateway:\n" + err.Error())
+	}
+	return restartDragonchain(ctx, config)
+}
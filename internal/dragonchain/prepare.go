@@ -1,25 +1,27 @@
 package dragonchain
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"os"
 	"os/exec"
 	"strconv"
-	"time"
 
 	"github.com/dragonchain/dragonchain-installer/internal/configuration"
 	"github.com/dragonchain/dragonchain-installer/internal/helm"
+	"github.com/dragonchain/dragonchain-installer/internal/interrupt"
+	"github.com/dragonchain/dragonchain-installer/internal/wait"
 )
 
-func doesHelmDeploymentExist(name string, namespace string) (bool, error) {
-	helmVersion, err := helm.GetHelmMajorVersion()
+func doesHelmDeploymentExist(ctx context.Context, name string, namespace string) (bool, error) {
+	helmVersion, err := helm.GetHelmMajorVersion(ctx)
 	if err != nil {
 		return false, err
 	}
-	cmd := exec.Command("helm", "get", "notes", name, "--kube-context", configuration.MinikubeContext)
+	cmd := exec.CommandContext(ctx, "helm", "get", "notes", name, "--kube-context", configuration.MinikubeContext)
 	if helmVersion > 2 {
-		cmd = exec.Command("helm", "get", "notes", name, "-n", namespace, "--kube-context", configuration.MinikubeContext)
+		cmd = exec.CommandContext(ctx, "helm", "get", "notes", name, "-n", namespace, "--kube-context", configuration.MinikubeContext)
 	}
 	cmd.Stderr = os.Stderr
 	if err := cmd.Run(); err != nil {
@@ -30,15 +32,40 @@ func doesHelmDeploymentExist(name string, namespace string) (bool, error) {
 	return true, nil
 }
 
+// Removes a helm deployment. Used when cleaning up after an interrupt, so deliberately not bound to the (cancelled) installer context
+func removeHelmDeployment(name string, namespace string) {
+	helmVersion, err := helm.GetHelmMajorVersion(context.Background())
+	if err != nil {
+		return
+	}
+	cmd := exec.Command("helm", "delete", "--purge", name, "--kube-context", configuration.MinikubeContext)
+	if helmVersion > 2 {
+		cmd = exec.Command("helm", "uninstall", name, "-n", namespace, "--kube-context", configuration.MinikubeContext)
+	}
+	cmd.Stderr = os.Stderr
+	cmd.Run()
+}
+
+func waitForClusterAfterDockerRestart(ctx context.Context) error {
+	err := wait.Poll(ctx, configuration.DockerRestartTimeout, configuration.PollInterval, func() (bool, error) {
+		// The api server runs in docker, so it only answers once containers are back up
+		return exec.CommandContext(ctx, "kubectl", "get", "--raw", "/healthz", "--context="+configuration.MinikubeContext).Run() == nil, nil
+	})
+	if err == wait.ErrTimeout {
+		return errors.New("Kubernetes cluster did not come back up after restarting docker daemon")
+	}
+	return err
+}
+
 // SetupDragonchainPreReqs sets up kubernetes resource requirements for dragonchain
-func SetupDragonchainPreReqs(config *configuration.Configuration) error {
-	if err := exec.Command("kubectl", "apply", "-f", "https://raw.githubusercontent.com/rancher/local-path-provisioner/master/deploy/local-path-storage.yaml").Run(); err != nil {
+func SetupDragonchainPreReqs(ctx context.Context, config *configuration.Configuration) error {
+	if err := exec.CommandContext(ctx, "kubectl", "apply", "-f", "https://raw.githubusercontent.com/rancher/local-path-provisioner/master/deploy/local-path-storage.yaml").Run(); err != nil {
 		return errors.New("Error creating local path provisioner:\n" + err.Error())
 	}
-	if exec.Command("kubectl", "get", "namespace", "dragonchain", "--context="+configuration.MinikubeContext).Run() != nil {
+	if exec.CommandContext(ctx, "kubectl", "get", "namespace", "dragonchain", "--context="+configuration.MinikubeContext).Run() != nil {
 		// Create the dragonchain namespace if necessary
 		fmt.Println("Creating dragonchain namespace")
-		cmd := exec.Command("kubectl", "create", "namespace", "dragonchain", "--context="+configuration.MinikubeContext)
+		cmd := exec.CommandContext(ctx, "kubectl", "create", "namespace", "dragonchain", "--context="+configuration.MinikubeContext)
 		cmd.Stderr = os.Stderr
 		if err := cmd.Run(); err != nil {
 			return errors.New("Error creating dragonchain namespace:\n" + err.Error())
@@ -47,53 +74,76 @@ func SetupDragonchainPreReqs(config *configuration.Configuration) error {
 	// Set up l1 dependencies if needed
 	if config.Level == 1 {
 		// Set up openfaas
-		exists, err := doesHelmDeploymentExist("openfaas", "openfaas")
+		exists, err := doesHelmDeploymentExist(ctx, "openfaas", "openfaas")
 		if err != nil {
 			return errors.New("Error checking for existing openfaas installation:\n" + err.Error())
 		}
 		if !exists {
 			fmt.Println("Openfaas does not appear to be installed. Installing now")
-			if err := createOpenFaasDeployment(); err != nil {
+			// A partial install would be detected as existing on the next run, so remove it if interrupted
+			done := interrupt.OnInterrupt("removing partial openfaas installation", func() {
+				exec.Command("kubectl", "delete", "secret", "basic-auth", "-n", "openfaas", "--ignore-not-found", "--context="+configuration.MinikubeContext).Run()
+				exec.Command("kubectl", "delete", "secret", "openfaas-auth", "-n", "dragonchain", "--ignore-not-found", "--context="+configuration.MinikubeContext).Run()
+				removeHelmDeployment("openfaas", "openfaas")
+			})
+			if err := createOpenFaasDeployment(ctx); err != nil {
 				return err
 			}
+			done()
 		}
 		if !config.UseVM {
 			// Try to backup old docker daemon config if it exists
-			cmd := exec.Command("sudo", "mv", "/etc/docker/daemon.json", "/etc/docker/daemon.json.bak")
+			cmd := exec.CommandContext(ctx, "sudo", "mv", "/etc/docker/daemon.json", "/etc/docker/daemon.json.bak")
 			cmd.Stdin = os.Stdin
-			cmd.Run()
+			backedUp := cmd.Run() == nil
+			// Put the original daemon config back if interrupted before docker is restarted with the new one
+			done := interrupt.OnInterrupt("restoring original /etc/docker/daemon.json", func() {
+				cmd := exec.Command("sudo", "rm", "-f", "/etc/docker/daemon.json")
+				if backedUp {
+					cmd = exec.Command("sudo", "mv", "/etc/docker/daemon.json.bak", "/etc/docker/daemon.json")
+				}
+				cmd.Stdin = os.Stdin
+				cmd.Run()
+			})
 			// If using native machine docker, need to ensure that insecure registry for the registry is set on the daemon
 			dockerDaemonJSON := "{\\\"insecure-registries\\\":[\\\"" + configuration.RegistryIP + ":" + strconv.Itoa(configuration.RegistryPort) + "\\\"]}"
-			cmd = exec.Command("sh", "-c", "echo "+dockerDaemonJSON+" | sudo tee /etc/docker/daemon.json")
+			cmd = exec.CommandContext(ctx, "sh", "-c", "echo "+dockerDaemonJSON+" | sudo tee /etc/docker/daemon.json")
 			cmd.Stderr = os.Stderr
 			cmd.Stdin = os.Stdin
 			if err := cmd.Run(); err != nil {
 				return errors.New("Error setting insecure registry setting with docker daemon:\n" + err.Error())
 			}
-			cmd = exec.Command("sudo", "service", "docker", "restart")
+			cmd = exec.CommandContext(ctx, "sudo", "service", "docker", "restart")
 			cmd.Stderr = os.Stderr
 			cmd.Stdin = os.Stdin
 			if err := cmd.Run(); err != nil {
 				return errors.New("Error restarting docker daemon:\n" + err.Error())
 			}
-			// Briefly wait for containers to come back up after restarting
-			time.Sleep(10 * time.Second)
+			done()
+			// Wait for the cluster's containers to come back up after restarting
+			if err := waitForClusterAfterDockerRestart(ctx); err != nil {
+				return err
+			}
 		}
 		// Set up docker registry
-		exists, err = doesHelmDeploymentExist("registry", "registry")
+		exists, err = doesHelmDeploymentExist(ctx, "registry", "registry")
 		if err != nil {
 			return errors.New("Error checking for existing container registry installation:\n" + err.Error())
 		}
 		if !exists {
 			fmt.Println("Docker registry does not appear to be installed. Installing now")
-			if err := createDockerRegistryDeployment(); err != nil {
+			done := interrupt.OnInterrupt("removing partial docker registry installation", func() {
+				removeHelmDeployment("registry", "registry")
+			})
+			if err := createDockerRegistryDeployment(ctx); err != nil {
 				return err
 			}
+			done()
 		}
 		// Set up openfaas builder service account
-		if !openfaasServiceAccountExists() {
+		if !openfaasServiceAccountExists(ctx) {
 			fmt.Println("Openfaas builder service account doesn't exist. Creating now")
-			if err := createOpenfaasBuilderServiceAccount(); err != nil {
+			if err := createOpenfaasBuilderServiceAccount(ctx); err != nil {
 				return err
 			}
 		}
@@ -0,0 +1,123 @@
+package dragonchain
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"github.com/dragonchain/dragonchain-installer/internal/configuration"
+	"github.com/dragonchain/dragonchain-installer/internal/wait"
+)
+
+type kubectlResourceJSONList struct {
+	Items [](struct {
+		Kind     string `json:"kind"`
+		Metadata (struct {
+			Name string `json:"name"`
+		}) `json:"metadata"`
+		Spec (struct {
+			Replicas int `json:"replicas"`
+		}) `json:"spec"`
+	}) `json:"items"`
+}
+
+// Workload is a scalable kubernetes resource (deployment or statefulset) belonging to a chain
+type Workload struct {
+	Kind     string `json:"Kind"`
+	Name     string `json:"Name"`
+	Replicas int    `json:"Replicas"`
+}
+
+// All of a chain's kubernetes resources are prefixed with its helm release name. Other chains share the namespace, so the name
+// has to start with the release name, followed by the resource's own name
+func belongsToChain(name string, config *configuration.Configuration) bool {
+	return strings.HasPrefix(name, "d-"+config.InternalID+"-")
+}
+
+func getChainResources(ctx context.Context, config *configuration.Configuration, kinds string) (*kubectlResourceJSONList, error) {
+	cmd := exec.CommandContext(ctx, "kubectl", "get", kinds, "-n", "dragonchain", "-o", "json", "--context="+configuration.MinikubeContext)
+	cmd.Stderr = os.Stderr
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, errors.New("Error getting dragonchain " + kinds + ":\n" + err.Error())
+	}
+	var resources kubectlResourceJSONList
+	if err := json.Unmarshal(output, &resources); err != nil {
+		return nil, errors.New("Failed to parse " + kinds + " list from kubectl:\n" + err.Error())
+	}
+	return &resources, nil
+}
+
+// ListDragonchainVolumes gets the names of the persistent volume claims belonging to a chain
+func ListDragonchainVolumes(ctx context.Context, config *configuration.Configuration) ([]string, error) {
+	resources, err := getChainResources(ctx, config, "pvc")
+	if err != nil {
+		return nil, err
+	}
+	claims := []string{}
+	for _, item := range resources.Items {
+		if belongsToChain(item.Metadata.Name, config) {
+			claims = append(claims, item.Metadata.Name)
+		}
+	}
+	return claims, nil
+}
+
+func scaleWorkload(ctx context.Context, workload Workload, replicas int) error {
+	cmd := exec.CommandContext(ctx, "kubectl", "scale", strings.ToLower(workload.Kind), workload.Name, "--replicas="+strconv.Itoa(replicas), "-n", "dragonchain", "--context="+configuration.MinikubeContext)
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return errors.New("Error scaling " + workload.Kind + " " + workload.Name + ":\n" + err.Error())
+	}
+	return nil
+}
+
+// ScaleDownDragonchain scales all of a chain's workloads to 0 and waits for its pods to stop, returning the previous replica counts
+func ScaleDownDragonchain(ctx context.Context, config *configuration.Configuration) ([]Workload, error) {
+	resources, err := getChainResources(ctx, config, "deployment,statefulset")
+	if err != nil {
+		return nil, err
+	}
+	workloads := []Workload{}
+	for _, item := range resources.Items {
+		if !belongsToChain(item.Metadata.Name, config) {
+			continue
+		}
+		workload := Workload{item.Kind, item.Metadata.Name, item.Spec.Replicas}
+		if err := scaleWorkload(ctx, workload, 0); err != nil {
+			return workloads, err
+		}
+		workloads = append(workloads, workload)
+	}
+	err = wait.Poll(ctx, configuration.DragonchainReadyTimeout, configuration.PollInterval, func() (bool, error) {
+		cmd := exec.CommandContext(ctx, "kubectl", "get", "pod", "-n", "dragonchain", "-l", "dragonchainId="+config.InternalID, "-o", "json", "--context="+configuration.MinikubeContext)
+		cmd.Stderr = os.Stderr
+		output, err := cmd.Output()
+		if err != nil {
+			return false, errors.New("Error checking dragonchain pods:\n" + err.Error())
+		}
+		var podList kubectlPodJSONList
+		if err := json.Unmarshal(output, &podList); err != nil {
+			return false, errors.New("Failed to parse pod list from kubectl:\n" + err.Error())
+		}
+		return len(podList.Items) == 0, nil
+	})
+	if err == wait.ErrTimeout {
+		return workloads, errors.New("Dragonchain pods failed to stop. Check kubernetes cluster for more information")
+	}
+	return workloads, err
+}
+
+// ScaleUpDragonchain restores the replica counts of a chain's workloads and waits for the chain to be ready
+func ScaleUpDragonchain(ctx context.Context, config *configuration.Configuration, workloads []Workload) error {
+	for _, workload := range workloads {
+		if err := scaleWorkload(ctx, workload, workload.Replicas); err != nil {
+			return err
+		}
+	}
+	return WaitForDragonchainToBeReady(ctx, config)
+}
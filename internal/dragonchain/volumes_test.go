@@ -0,0 +1,27 @@
+package dragonchain
+
+import (
+	"testing"
+
+	"github.com/dragonchain/dragonchain-installer/internal/configuration"
+)
+
+func TestBelongsToChain(t *testing.T) {
+	config := &configuration.Configuration{InternalID: "8d3f2b5c-4d2a-4b0e-9c1f-2a6e7d9b0c11"}
+	cases := []struct {
+		name     string
+		expected bool
+	}{
+		{"d-8d3f2b5c-4d2a-4b0e-9c1f-2a6e7d9b0c11-webserver", true},
+		{"d-8d3f2b5c-4d2a-4b0e-9c1f-2a6e7d9b0c11-redis-persistent-storage", true},
+		{"d-8d3f2b5c-4d2a-4b0e-9c1f-2a6e7d9b0c11", false},
+		{"d-8d3f2b5c-4d2a-4b0e-9c1f-2a6e7d9b0c112-webserver", false},
+		{"backup-d-8d3f2b5c-4d2a-4b0e-9c1f-2a6e7d9b0c11-webserver", false},
+		{"d-1a2b3c4d-0000-0000-0000-000000000000-webserver", false},
+	}
+	for _, c := range cases {
+		if belongs := belongsToChain(c.name, config); belongs != c.expected {
+			t.Errorf("Expected %s belonging to the chain to be %t", c.name, c.expected)
+		}
+	}
+}
@@ -1,48 +1,185 @@
 package dragonnet
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"io/ioutil"
 	"net/http"
+	"strings"
 	"time"
+
+	"github.com/dragonchain/dragonchain-installer/internal/configuration"
+	"github.com/dragonchain/dragonchain-installer/internal/wait"
 )
 
-var tryLimit = 30
+// Client used for requests to matchmaking (matchmaking verifies the chain while responding, so allow time for that)
+var httpClient = &http.Client{Timeout: 30 * time.Second}
 
-func checkMatchmakingRegistration(pubID string, tries int) error {
-	// First check that the chain was able to register correctly (has correct dragon net tokens)
-	resp, err := http.Get("https://matchmaking.api.dragonchain.com/registration/" + pubID)
+// NotReachableError is returned when a chain is registered with dragon net, but matchmaking could not reach it
+type NotReachableError struct {
+	// Reason from matchmaking explaining why the chain could not be reached
+	Details string
+}
+
+func (err *NotReachableError) Error() string {
+	return "Although registered, dragon net is reporting that the chain is not reachable (did you port-forward correctly)? Dragon net support will not work. Error:\n" + err.Details
+}
+
+// PortForwarder forwards a port on the router to this machine (i.e. with portmap.Forward)
+type PortForwarder func(ctx context.Context, port int) error
+
+// Registration is a chain's registration with dragon net matchmaking
+type Registration struct {
+	DcID           string `json:"dcId"`
+	Level          int    `json:"level"`
+	URL            string `json:"url"`
+	BroadcastURL   string `json:"broadcastUrl"`
+	Version        string `json:"version"`
+	Scheme         string `json:"scheme"`
+	HashAlgo       string `json:"hashAlgo"`
+	EncryptionAlgo string `json:"encryptionAlgo"`
+}
+
+// RegistrationReport is the result of checking a chain's dragon net registration
+type RegistrationReport struct {
+	Registration Registration
+	Reachable    bool
+	// Why matchmaking could not reach the chain, if it is not reachable
+	FailureReason string
+}
+
+func matchmakingGet(ctx context.Context, path string) (*http.Response, []byte, error) {
+	req, err := http.NewRequest("GET", strings.TrimSuffix(configuration.MatchmakingURL, "/")+path, nil)
 	if err != nil {
-		return errors.New("Error communicating with matchmaking:\n" + err.Error())
-	}
-	if resp.StatusCode != 200 {
-		if tries > tryLimit {
-			return errors.New("Registration could not be found for your chain. Although your chain may be installed and working locally, dragon net support will not work. Check the logs of the transaction processor for more details")
-		}
-		time.Sleep(1 * time.Second)
-		return checkMatchmakingRegistration(pubID, tries+1)
+		return nil, nil, err
 	}
-	resp.Body.Close()
-	// Now check that the chain is reachable from the greater internet
-	resp, err = http.Get("https://matchmaking.api.dragonchain.com/registration/verify/" + pubID + "?source=installscript")
+	resp, err := httpClient.Do(req.WithContext(ctx))
 	if err != nil {
-		return errors.New("Error communicating with matchmaking:\n" + err.Error())
+		return nil, nil, errors.New("Error communicating with matchmaking:\n" + err.Error())
 	}
 	defer resp.Body.Close()
 	body, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
-		return errors.New("Error reading matchmaking response body:\n" + err.Error())
+		return nil, nil, errors.New("Error reading matchmaking response body:\n" + err.Error())
+	}
+	return resp, body, nil
+}
+
+// Get the reason out of a matchmaking error response, which is usually json with an error message
+func failureReason(body []byte) string {
+	var response struct {
+		Error   interface{} `json:"error"`
+		Message string      `json:"message"`
+	}
+	if json.Unmarshal(body, &response) == nil {
+		if message, ok := response.Error.(string); ok && message != "" {
+			return message
+		}
+		if response.Message != "" {
+			return response.Message
+		}
+	}
+	return strings.TrimSpace(string(body))
+}
+
+// Get a chain's registration from matchmaking, or nil if it is not registered
+func fetchRegistration(ctx context.Context, pubID string) (*Registration, error) {
+	resp, body, err := matchmakingGet(ctx, "/registration/"+pubID)
+	if err != nil {
+		return nil, err
 	}
 	if resp.StatusCode != 200 {
-		return errors.New("Although registered, dragon net is reporting that the chain is not reachable (did you port-forward correctly)? Dragon net support will not work. Error:\n" + string(body))
+		return nil, nil
 	}
-	return nil
+	registration := new(Registration)
+	if err := json.Unmarshal(body, registration); err != nil {
+		return nil, errors.New("Error parsing matchmaking registration:\n" + err.Error())
+	}
+	return registration, nil
+}
+
+func getRegistration(ctx context.Context, pubID string) (*Registration, error) {
+	var registration *Registration
+	err := wait.Poll(ctx, configuration.DragonNetRegistrationTimeout, configuration.PollInterval, func() (bool, error) {
+		var err error
+		registration, err = fetchRegistration(ctx, pubID)
+		return registration != nil, err
+	})
+	if err == wait.ErrTimeout {
+		return nil, errors.New("Registration could not be found for your chain. Although your chain may be installed and working locally, dragon net support will not work. Check the logs of the transaction processor for more details")
+	}
+	return registration, err
+}
+
+// WaitForRegisteredEndpoint waits for the chain to register with dragon net at endpoint (i.e. after its endpoint is changed)
+func WaitForRegisteredEndpoint(ctx context.Context, pubID string, endpoint string) error {
+	err := wait.Poll(ctx, configuration.DragonNetRegistrationTimeout, configuration.PollInterval, func() (bool, error) {
+		registration, err := fetchRegistration(ctx, pubID)
+		if err != nil || registration == nil {
+			return false, err
+		}
+		return normalizeEndpoint(registration.URL) == normalizeEndpoint(endpoint), nil
+	})
+	if err == wait.ErrTimeout {
+		return errors.New("Chain did not register with dragon net at its new endpoint " + endpoint + " in time. Check the logs of the transaction processor for more details")
+	}
+	return err
+}
+
+// CheckDragonNetRegistration gets a chain's dragon net registration and whether matchmaking can reach it.
+// Returns an error if the chain is not registered, otherwise the report says whether it is reachable
+func CheckDragonNetRegistration(ctx context.Context, pubID string) (*RegistrationReport, error) {
+	// First check that the chain was able to register correctly (has correct dragon net tokens)
+	registration, err := getRegistration(ctx, pubID)
+	if err != nil {
+		return nil, err
+	}
+	// Now check that the chain is reachable from the greater internet
+	resp, body, err := matchmakingGet(ctx, "/registration/verify/"+pubID+"?source=installscript")
+	if err != nil {
+		return nil, err
+	}
+	report := &RegistrationReport{Registration: *registration, Reachable: resp.StatusCode == 200}
+	if !report.Reachable {
+		report.FailureReason = failureReason(body)
+	}
+	return report, nil
 }
 
 // CheckDragonNetConfiguration checks if a dragonchain is running and connectable via dragon net
-func CheckDragonNetConfiguration(pubID string) error {
-	if err := checkMatchmakingRegistration(pubID, 0); err != nil {
+func CheckDragonNetConfiguration(ctx context.Context, pubID string) error {
+	report, err := CheckDragonNetRegistration(ctx, pubID)
+	if err != nil {
 		return err
 	}
+	if !report.Reachable {
+		return &NotReachableError{Details: report.FailureReason}
+	}
 	return nil
 }
+
+// CheckDragonNetConfigurationWithPortForward checks the chain's dragon net configuration, and if the only problem is that
+// the chain is registered but not reachable (potential port-forward issue), tries to forward port with forwardPort and checks again.
+// The report of the last check is returned if the chain is registered, even if it is not reachable
+func CheckDragonNetConfigurationWithPortForward(ctx context.Context, pubID string, port int, forwardPort PortForwarder) (*RegistrationReport, error) {
+	report, err := CheckDragonNetRegistration(ctx, pubID)
+	if err != nil || report.Reachable {
+		return report, err
+	}
+	fmt.Print("Chain is registered, but does not seem reachable. Trying to automatically port-forward\n")
+	if forwardErr := forwardPort(ctx, port); forwardErr != nil {
+		fmt.Print("Could not port forward automatically:\n" + forwardErr.Error() + "\n")
+		return report, &NotReachableError{Details: report.FailureReason}
+	}
+	fmt.Print("Port forward successful, checking dragonnet registration again\n")
+	report, err = CheckDragonNetRegistration(ctx, pubID)
+	if err != nil {
+		return nil, err
+	}
+	if !report.Reachable {
+		return report, &NotReachableError{Details: report.FailureReason}
+	}
+	return report, nil
+}
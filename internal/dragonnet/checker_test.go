@@ -0,0 +1,266 @@
+package dragonnet
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dragonchain/dragonchain-installer/internal/configuration"
+	"github.com/dragonchain/dragonchain-installer/internal/dragonnet/mockmatchmaking"
+)
+
+const testChainID = "zN8xSCY1TVpNgNzGxPwj1ys7ZdvvG4nYpD6pCbmXPYRy"
+
+var testRegistration = Registration{DcID: testChainID, Level: 2, URL: "http://203.0.113.7:30000", Version: "4.3.3"}
+
+// Start a mock matchmaking server, and make the checks use it and wait briefly, until the returned function is called
+func useMatchmaking(t *testing.T) (*mockmatchmaking.Server, func()) {
+	mock := mockmatchmaking.NewServer()
+	previousURL := configuration.MatchmakingURL
+	previousTimeout, previousInterval := configuration.DragonNetRegistrationTimeout, configuration.PollInterval
+	configuration.MatchmakingURL = mock.URL
+	configuration.DragonNetRegistrationTimeout, configuration.PollInterval = 500*time.Millisecond, 20*time.Millisecond
+	return mock, func() {
+		configuration.MatchmakingURL = previousURL
+		configuration.DragonNetRegistrationTimeout, configuration.PollInterval = previousTimeout, previousInterval
+		mock.Close()
+	}
+}
+
+// A port forwarder which records the ports it was asked to forward, and fails with err (if not nil) or calls forwarded
+type fakeForwarder struct {
+	ports     []int
+	err       error
+	forwarded func()
+}
+
+func (forwarder *fakeForwarder) forward(ctx context.Context, port int) error {
+	forwarder.ports = append(forwarder.ports, port)
+	if forwarder.err != nil {
+		return forwarder.err
+	}
+	if forwarder.forwarded != nil {
+		forwarder.forwarded()
+	}
+	return nil
+}
+
+func TestRegisteredAndReachable(t *testing.T) {
+	mock, done := useMatchmaking(t)
+	defer done()
+	mock.Register(testChainID, testRegistration)
+
+	report, err := CheckDragonNetRegistration(context.Background(), testChainID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !report.Reachable || report.Registration != testRegistration || report.FailureReason != "" {
+		t.Errorf("Unexpected report %+v", report)
+	}
+	if err := CheckDragonNetConfiguration(context.Background(), testChainID); err != nil {
+		t.Error(err)
+	}
+	requests := mock.Requests()
+	if len(requests) < 2 || requests[0] != "/registration/"+testChainID || requests[1] != "/registration/verify/"+testChainID+"?source=installscript" {
+		t.Errorf("Unexpected requests %v", requests)
+	}
+}
+
+func TestNotRegistered(t *testing.T) {
+	mock, done := useMatchmaking(t)
+	defer done()
+
+	start := time.Now()
+	err := CheckDragonNetConfiguration(context.Background(), testChainID)
+	if err == nil || !strings.Contains(err.Error(), "Registration could not be found") {
+		t.Errorf("Expected the registration not to be found, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < configuration.DragonNetRegistrationTimeout || elapsed > 2*time.Second {
+		t.Errorf("Expected to wait for the registration timeout, took %s", elapsed)
+	}
+	if len(mock.Requests()) < 2 {
+		t.Errorf("Expected the registration to be polled, got requests %v", mock.Requests())
+	}
+	for _, request := range mock.Requests() {
+		if strings.HasPrefix(request, "/registration/verify/") {
+			t.Errorf("Expected reachability not to be checked without a registration, got %s", request)
+		}
+	}
+}
+
+func TestRegisteredWhilePolling(t *testing.T) {
+	mock, done := useMatchmaking(t)
+	defer done()
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		mock.Register(testChainID, testRegistration)
+	}()
+
+	if err := CheckDragonNetConfiguration(context.Background(), testChainID); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestRegisteredButUnreachable(t *testing.T) {
+	mock, done := useMatchmaking(t)
+	defer done()
+	mock.Register(testChainID, testRegistration)
+	mock.SetUnreachable(testChainID, "Failure to connect to dragonchain at http://203.0.113.7:30000")
+
+	report, err := CheckDragonNetRegistration(context.Background(), testChainID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Reachable || report.FailureReason != "Failure to connect to dragonchain at http://203.0.113.7:30000" {
+		t.Errorf("Unexpected report %+v", report)
+	}
+	err = CheckDragonNetConfiguration(context.Background(), testChainID)
+	notReachable, ok := err.(*NotReachableError)
+	if !ok || notReachable.Details != report.FailureReason {
+		t.Errorf("Expected a *NotReachableError with matchmaking's reason, got %v", err)
+	}
+}
+
+func TestPortForwardFallback(t *testing.T) {
+	mock, done := useMatchmaking(t)
+	defer done()
+	mock.Register(testChainID, testRegistration)
+	mock.SetUnreachable(testChainID, "connection refused")
+	forwarder := &fakeForwarder{forwarded: func() { mock.SetReachable(testChainID) }}
+
+	report, err := CheckDragonNetConfigurationWithPortForward(context.Background(), testChainID, 30000, forwarder.forward)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !report.Reachable {
+		t.Errorf("Expected the chain to be reachable after forwarding, got %+v", report)
+	}
+	if len(forwarder.ports) != 1 || forwarder.ports[0] != 30000 {
+		t.Errorf("Expected port 30000 to be forwarded once, got %v", forwarder.ports)
+	}
+}
+
+func TestPortForwardFallbackFails(t *testing.T) {
+	mock, done := useMatchmaking(t)
+	defer done()
+	mock.Register(testChainID, testRegistration)
+	mock.SetUnreachable(testChainID, "connection refused")
+	forwarder := &fakeForwarder{err: errors.New("Couldn't find any UPNP compatible router")}
+
+	report, err := CheckDragonNetConfigurationWithPortForward(context.Background(), testChainID, 30000, forwarder.forward)
+	if notReachable, ok := err.(*NotReachableError); !ok || notReachable.Details != "connection refused" {
+		t.Errorf("Expected a *NotReachableError, got %v", err)
+	}
+	if report == nil || report.Reachable || report.Registration != testRegistration {
+		t.Errorf("Expected the report of the registered chain, got %+v", report)
+	}
+	if len(forwarder.ports) != 1 {
+		t.Errorf("Expected one port forward attempt, got %v", forwarder.ports)
+	}
+}
+
+func TestPortForwardFallbackStillUnreachable(t *testing.T) {
+	mock, done := useMatchmaking(t)
+	defer done()
+	mock.Register(testChainID, testRegistration)
+	mock.SetUnreachable(testChainID, "connection timed out")
+	forwarder := &fakeForwarder{}
+
+	report, err := CheckDragonNetConfigurationWithPortForward(context.Background(), testChainID, 30000, forwarder.forward)
+	if notReachable, ok := err.(*NotReachableError); !ok || notReachable.Details != "connection timed out" {
+		t.Errorf("Expected a *NotReachableError, got %v", err)
+	}
+	if report == nil || report.Reachable {
+		t.Errorf("Expected an unreachable report, got %+v", report)
+	}
+	verifies := 0
+	for _, request := range mock.Requests() {
+		if strings.HasPrefix(request, "/registration/verify/") {
+			verifies++
+		}
+	}
+	if verifies != 2 {
+		t.Errorf("Expected reachability to be checked again after forwarding, got requests %v", mock.Requests())
+	}
+}
+
+func TestPortForwardFallbackSkipped(t *testing.T) {
+	mock, done := useMatchmaking(t)
+	defer done()
+	forwarder := &fakeForwarder{}
+
+	// Not registered; forwarding the port won't help
+	if _, err := CheckDragonNetConfigurationWithPortForward(context.Background(), testChainID, 30000, forwarder.forward); err == nil {
+		t.Error("Expected an error for an unregistered chain")
+	}
+	mock.Register(testChainID, testRegistration)
+	// Already reachable
+	if _, err := CheckDragonNetConfigurationWithPortForward(context.Background(), testChainID, 30000, forwarder.forward); err != nil {
+		t.Error(err)
+	}
+	if len(forwarder.ports) != 0 {
+		t.Errorf("Expected no port forward attempts, got %v", forwarder.ports)
+	}
+}
+
+func TestWaitForRegisteredEndpoint(t *testing.T) {
+	mock, done := useMatchmaking(t)
+	defer done()
+	mock.Register(testChainID, testRegistration)
+
+	if err := WaitForRegisteredEndpoint(context.Background(), testChainID, "http://198.51.100.7:30000"); err == nil {
+		t.Error("Expected a timeout while the old endpoint is registered")
+	}
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		registration := testRegistration
+		registration.URL = "http://198.51.100.7:30000/"
+		mock.Register(testChainID, registration)
+	}()
+	if err := WaitForRegisteredEndpoint(context.Background(), testChainID, "HTTP://198.51.100.7:30000"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestFailureReason(t *testing.T) {
+	cases := []struct {
+		body     string
+		expected string
+	}{
+		{`{"error": "Failure to connect to dragonchain"}`, "Failure to connect to dragonchain"},
+		{`{"message": "Chain is not reachable"}`, "Chain is not reachable"},
+		{`{"error": "", "message": "Chain is not reachable"}`, "Chain is not reachable"},
+		{`{"error": {"type": "NOT_FOUND"}, "message": "Chain is not reachable"}`, "Chain is not reachable"},
+		{`{"error": {"type": "NOT_FOUND"}}`, `{"error": {"type": "NOT_FOUND"}}`},
+		{"  Bad Gateway\n", "Bad Gateway"},
+		{"", ""},
+	}
+	for _, c := range cases {
+		if reason := failureReason([]byte(c.body)); reason != c.expected {
+			t.Errorf("Expected the reason in %q to be %q, got %q", c.body, c.expected, reason)
+		}
+	}
+}
+
+func TestEndpointMismatch(t *testing.T) {
+	cases := []struct {
+		registered string
+		configured string
+		mismatch   bool
+	}{
+		{"http://203.0.113.7:30000", "http://203.0.113.7:30000", false},
+		{"HTTP://Example.com:30000/", "http://example.com:30000", false},
+		{"http://[2001:db8:0:0::7]:30000", "http://[2001:db8::7]:30000", false},
+		{"http://203.0.113.7:30000", "http://203.0.113.8:30000", true},
+		{"http://203.0.113.7:30000", "https://203.0.113.7:30000", true},
+		{"http://203.0.113.7:30000", "http://203.0.113.7:30001", true},
+	}
+	for _, c := range cases {
+		report := &RegistrationReport{Registration: Registration{URL: c.registered}}
+		if mismatch := report.EndpointMismatch(c.configured); mismatch != c.mismatch {
+			t.Errorf("Expected mismatch of %s and %s to be %t", c.registered, c.configured, c.mismatch)
+		}
+	}
+}
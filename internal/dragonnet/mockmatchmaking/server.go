@@ -0,0 +1,112 @@
+// Package mockmatchmaking is a local stand-in for the dragon net matchmaking api, for exercising the dragon net checks
+// (and the port forward fallback) end to end without a real chain being registered.
+// Point configuration.MatchmakingURL at Server.URL to use it
+package mockmatchmaking
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+)
+
+// Server emulates the matchmaking registration endpoints
+type Server struct {
+	// URL is the base url of the running server
+	URL string
+
+	server        *httptest.Server
+	lock          sync.Mutex
+	registrations map[string]interface{}
+	unreachable   map[string]string
+	requests      []string
+}
+
+// NewServer starts a mock matchmaking server with no registered chains
+func NewServer() *Server {
+	mock := &Server{
+		registrations: map[string]interface{}{},
+		unreachable:   map[string]string{},
+	}
+	mock.server = httptest.NewServer(http.HandlerFunc(mock.handle))
+	mock.URL = mock.server.URL
+	return mock
+}
+
+// Close shuts down the server
+func (mock *Server) Close() {
+	mock.server.Close()
+}
+
+// Register adds a chain's registration, which is returned as json from /registration/<pubID>. The chain is reachable unless
+// SetUnreachable is called
+func (mock *Server) Register(pubID string, registration interface{}) {
+	mock.lock.Lock()
+	defer mock.lock.Unlock()
+	mock.registrations[pubID] = registration
+}
+
+// Unregister removes a chain's registration
+func (mock *Server) Unregister(pubID string) {
+	mock.lock.Lock()
+	defer mock.lock.Unlock()
+	delete(mock.registrations, pubID)
+}
+
+// SetUnreachable makes /registration/verify/<pubID> fail with reason, as if matchmaking could not connect to the chain
+func (mock *Server) SetUnreachable(pubID string, reason string) {
+	mock.lock.Lock()
+	defer mock.lock.Unlock()
+	mock.unreachable[pubID] = reason
+}
+
+// SetReachable makes /registration/verify/<pubID> succeed again (i.e. from a port forwarder once it has "forwarded" the port)
+func (mock *Server) SetReachable(pubID string) {
+	mock.lock.Lock()
+	defer mock.lock.Unlock()
+	delete(mock.unreachable, pubID)
+}
+
+// Requests gets the paths (including query strings) of all requests made to the server so far
+func (mock *Server) Requests() []string {
+	mock.lock.Lock()
+	defer mock.lock.Unlock()
+	return append([]string{}, mock.requests...)
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+func (mock *Server) handle(w http.ResponseWriter, r *http.Request) {
+	mock.lock.Lock()
+	defer mock.lock.Unlock()
+	mock.requests = append(mock.requests, r.URL.RequestURI())
+	if r.Method != "GET" {
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "Method not allowed"})
+		return
+	}
+	verify := strings.HasPrefix(r.URL.Path, "/registration/verify/")
+	pubID := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, "/registration/verify/"), "/registration/")
+	if pubID == "" || strings.Contains(pubID, "/") || !strings.HasPrefix(r.URL.Path, "/registration/") {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "Not found"})
+		return
+	}
+	registration, registered := mock.registrations[pubID]
+	if !registered {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "Registration not found for " + pubID})
+		return
+	}
+	if !verify {
+		writeJSON(w, http.StatusOK, registration)
+		return
+	}
+	if reason, unreachable := mock.unreachable[pubID]; unreachable {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": reason})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"success": "Chain " + pubID + " is reachable"})
+}
@@ -0,0 +1,58 @@
+package dragonnet
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/dragonchain/dragonchain-installer/internal/configuration"
+)
+
+// Normalize an endpoint url for comparison (scheme and host are case insensitive, and a trailing slash doesn't matter)
+func normalizeEndpoint(endpoint string) string {
+	parsed, err := url.Parse(strings.TrimSpace(endpoint))
+	if err != nil || parsed.Host == "" {
+		return strings.TrimSuffix(strings.ToLower(strings.TrimSpace(endpoint)), "/")
+	}
+	host := strings.ToLower(parsed.Host)
+	if ip := net.ParseIP(parsed.Hostname()); ip != nil {
+		// The same ipv6 address can be written in several ways
+		host = configuration.EndpointHost(ip.String())
+		if parsed.Port() != "" {
+			host += ":" + parsed.Port()
+		}
+	}
+	return strings.ToLower(parsed.Scheme) + "://" + host + strings.TrimSuffix(parsed.Path, "/")
+}
+
+// EndpointMismatch checks whether the registered endpoint differs from the chain's configured endpoint
+func (report *RegistrationReport) EndpointMismatch(configuredEndpoint string) bool {
+	return normalizeEndpoint(report.Registration.URL) != normalizeEndpoint(configuredEndpoint)
+}
+
+// Print displays the details of the registration, warning if the registered endpoint is not the configured one
+func (report *RegistrationReport) Print(configuredEndpoint string) {
+	valueOrUnknown := func(value string) string {
+		if value == "" {
+			return "(unknown)"
+		}
+		return value
+	}
+	fmt.Print("Dragon Net registration:\n")
+	fmt.Print("  Chain id:      " + valueOrUnknown(report.Registration.DcID) + "\n")
+	fmt.Print("  Level:         " + strconv.Itoa(report.Registration.Level) + "\n")
+	fmt.Print("  Version:       " + valueOrUnknown(report.Registration.Version) + "\n")
+	fmt.Print("  Endpoint:      " + valueOrUnknown(report.Registration.URL) + "\n")
+	fmt.Print("  Broadcast url: " + valueOrUnknown(report.Registration.BroadcastURL) + "\n")
+	if report.Reachable {
+		fmt.Print("  Reachable:     yes\n")
+	} else {
+		fmt.Print("  Reachable:     no (" + valueOrUnknown(report.FailureReason) + ")\n")
+	}
+	if report.EndpointMismatch(configuredEndpoint) {
+		fmt.Print("WARNING: The chain is registered with endpoint " + valueOrUnknown(report.Registration.URL) + ", but is configured with endpoint " + configuredEndpoint + "\n")
+		fmt.Print("Dragon net will try to reach the chain at the registered endpoint. The chain may need to be restarted to register its new endpoint\n")
+	}
+}
@@ -0,0 +1,126 @@
+package dragonnet
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dragonchain/dragonchain-installer/internal/checkresult"
+	"github.com/dragonchain/dragonchain-installer/internal/configuration"
+)
+
+// Client for probing the chain; these should answer quickly if they are going to answer at all
+var probeClient = &http.Client{Timeout: 5 * time.Second}
+
+// SelfTest is the result of checking the chain's reachability from this machine, step by step from the cluster outwards
+type SelfTest struct {
+	Steps     []checkresult.Result
+	Diagnosis string
+}
+
+// Check that something answers http requests at endpoint (any response means the port is forwarded to a listener)
+func probe(ctx context.Context, endpoint string) error {
+	req, err := http.NewRequest("GET", strings.TrimSuffix(endpoint, "/")+"/health", nil)
+	if err != nil {
+		return err
+	}
+	resp, err := probeClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// Check that the endpoint's hostname resolves to the public ip
+func checkEndpointDNS(ctx context.Context, endpoint string, publicIP string) checkresult.Result {
+	step := checkresult.Result{Name: "Endpoint resolves to this machine's public ip"}
+	parsed, err := url.Parse(endpoint)
+	if err != nil || parsed.Hostname() == "" {
+		step.Status = checkresult.Fail
+		step.Details = "Could not parse endpoint " + endpoint
+		return step
+	}
+	host := parsed.Hostname()
+	if publicIP == "" {
+		step.Status = checkresult.Skip
+		step.Details = "Public ip could not be detected"
+		return step
+	}
+	addresses := []string{host}
+	if net.ParseIP(host) == nil {
+		addresses, err = net.DefaultResolver.LookupHost(ctx, host)
+		if err != nil {
+			step.Status = checkresult.Fail
+			step.Details = "Could not resolve " + host + ": " + err.Error()
+			return step
+		}
+	}
+	for _, address := range addresses {
+		if net.ParseIP(address).Equal(net.ParseIP(publicIP)) {
+			step.Status = checkresult.Pass
+			step.Details = host + " resolves to " + publicIP
+			return step
+		}
+	}
+	step.Status = checkresult.Fail
+	step.Details = host + " resolves to " + strings.Join(addresses, ", ") + ", but this machine's public ip is " + publicIP
+	return step
+}
+
+// RunReachabilitySelfTest checks whether the chain is reachable at each hop between the cluster and its public endpoint:
+// the node port on the cluster, the virtualbox port forward (VM only), the endpoint's DNS, and the endpoint itself through
+// the router (which only works from this machine if the router supports hairpin NAT)
+func RunReachabilitySelfTest(ctx context.Context, config *configuration.Configuration, clusterIP string, publicIP string) *SelfTest {
+	test := &SelfTest{}
+	port := strconv.Itoa(config.Port)
+	nodePort := checkresult.Result{Name: "Chain answers on its node port", Status: checkresult.Pass, Details: clusterIP + ":" + port}
+	if err := probe(ctx, "http://"+net.JoinHostPort(clusterIP, port)); err != nil {
+		nodePort.Status = checkresult.Fail
+		nodePort.Details = err.Error()
+	}
+	test.Steps = append(test.Steps, nodePort)
+	forward := checkresult.Result{Name: "Virtualbox port forward answers on localhost", Status: checkresult.Skip, Details: "Not using a VM"}
+	if config.UseVM {
+		forward.Status = checkresult.Pass
+		forward.Details = "localhost:" + port
+		if err := probe(ctx, "http://localhost:"+port); err != nil {
+			forward.Status = checkresult.Fail
+			forward.Details = err.Error()
+		}
+	}
+	test.Steps = append(test.Steps, forward)
+	dns := checkEndpointDNS(ctx, config.EndpointURL, publicIP)
+	test.Steps = append(test.Steps, dns)
+	hairpin := checkresult.Result{Name: "Endpoint answers through the router (hairpin NAT)", Status: checkresult.Pass, Details: config.EndpointURL}
+	if err := probe(ctx, config.EndpointURL); err != nil {
+		// Many routers don't support hairpin NAT, so this failing doesn't necessarily mean the chain is unreachable
+		hairpin.Status = checkresult.Warn
+		hairpin.Details = err.Error()
+	}
+	test.Steps = append(test.Steps, hairpin)
+	switch {
+	case nodePort.Status == checkresult.Fail:
+		test.Diagnosis = "The chain is not answering on its node port. Check that its pods are running with 'kubectl get pods -n dragonchain --context=" + configuration.MinikubeContext + "'"
+	case forward.Status == checkresult.Fail:
+		test.Diagnosis = "The virtualbox port forward is not working. Check that port " + port + " is not already in use on this machine, then run the installer again to recreate the forward"
+	case dns.Status == checkresult.Fail:
+		test.Diagnosis = "DNS mismatch: " + dns.Details + ". Update the DNS record (or the chain's endpoint) so dragon net can find the chain"
+	case hairpin.Status == checkresult.Pass:
+		test.Diagnosis = "The chain is reachable through its public endpoint"
+	default:
+		test.Diagnosis = "The chain works locally, but could not be reached through its public endpoint. Either the router is missing a port forward of TCP port " + port + " to this machine, or the router doesn't support hairpin NAT (in which case this machine can't check it)"
+	}
+	return test
+}
+
+// Print displays the result of each step and the diagnosis
+func (test *SelfTest) Print() {
+	checkresult.Print("Reachability self-test", test.Steps)
+	fmt.Print("Diagnosis: " + test.Diagnosis + "\n")
+}
@@ -0,0 +1,74 @@
+package dyndns
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dragonchain/dragonchain-installer/internal/configuration"
+)
+
+// Provider is a dynamic dns service which supports the dyndns2 update protocol (i.e. dyndns, no-ip, google domains)
+type Provider struct {
+	// Base url of the provider's update api (i.e. https://members.dyndns.org)
+	Server   string
+	Hostname string
+	Username string
+	Password string
+}
+
+var httpClient = &http.Client{Timeout: 30 * time.Second}
+
+// Explanations of the dyndns2 return codes which mean the update failed
+var failureCodes = map[string]string{
+	"badauth":  "username or password is incorrect",
+	"badagent": "the provider blocked this client",
+	"!donator": "the update requires a paid account",
+	"notfqdn":  "hostname is not a fully qualified domain name",
+	"nohost":   "hostname does not exist in this account",
+	"numhost":  "too many hosts in the update",
+	"abuse":    "hostname is blocked for abuse",
+	"badsys":   "invalid system parameter",
+	"dnserr":   "dns error at the provider",
+	"911":      "problem at the provider, try again later",
+}
+
+// Update points the provider's hostname at ip, returning an error if the provider did not accept it
+func (provider *Provider) Update(ctx context.Context, ip string) error {
+	query := url.Values{}
+	query.Set("hostname", provider.Hostname)
+	query.Set("myip", ip)
+	req, err := http.NewRequest("GET", strings.TrimSuffix(provider.Server, "/")+"/nic/update?"+query.Encode(), nil)
+	if err != nil {
+		return err
+	}
+	req.SetBasicAuth(provider.Username, provider.Password)
+	// The protocol requires a user agent identifying the client
+	req.Header.Set("User-Agent", "dragonchain-installer/"+configuration.Version)
+	resp, err := httpClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return errors.New("Error communicating with dynamic dns provider:\n" + err.Error())
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return errors.New("Error reading dynamic dns provider response:\n" + err.Error())
+	}
+	result := strings.Fields(string(body))
+	if resp.StatusCode != 200 || len(result) == 0 {
+		return errors.New("Dynamic dns provider responded with status " + strconv.Itoa(resp.StatusCode) + ":\n" + string(body))
+	}
+	switch result[0] {
+	case "good", "nochg":
+		return nil
+	}
+	if reason, known := failureCodes[result[0]]; known {
+		return errors.New("Dynamic dns update of " + provider.Hostname + " failed: " + result[0] + " (" + reason + ")")
+	}
+	return errors.New("Unexpected response from dynamic dns provider:\n" + string(body))
+}
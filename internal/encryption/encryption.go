@@ -0,0 +1,84 @@
+package encryption
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+// Identifies encrypted files written by this installer (and the version of the format)
+var fileHeader = []byte("DCINSTALLER-ENCRYPTED-V1\n")
+
+// scrypt parameters recommended for interactive use
+const scryptN = 32768
+const scryptR = 8
+const scryptP = 1
+
+const saltLength = 16
+
+func deriveKey(passphrase string, salt []byte) ([]byte, error) {
+	return scrypt.Key([]byte(passphrase), salt, scryptN, scryptR, scryptP, 32)
+}
+
+// Encrypt encrypts data with a key derived from passphrase using scrypt and AES-256-GCM
+func Encrypt(data []byte, passphrase string) ([]byte, error) {
+	salt := make([]byte, saltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, errors.New("Error generating salt:\n" + err.Error())
+	}
+	key, err := deriveKey(passphrase, salt)
+	if err != nil {
+		return nil, errors.New("Error deriving encryption key:\n" + err.Error())
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, errors.New("Error generating nonce:\n" + err.Error())
+	}
+	// File is header | salt | nonce | ciphertext, with the header authenticated as additional data
+	output := append(append(append([]byte{}, fileHeader...), salt...), nonce...)
+	return gcm.Seal(output, nonce, data, fileHeader), nil
+}
+
+// Decrypt decrypts data previously encrypted with Encrypt
+func Decrypt(data []byte, passphrase string) ([]byte, error) {
+	if !bytes.HasPrefix(data, fileHeader) {
+		return nil, errors.New("File is not an encrypted dragonchain installer file")
+	}
+	data = data[len(fileHeader):]
+	if len(data) < saltLength {
+		return nil, errors.New("Encrypted file is truncated")
+	}
+	key, err := deriveKey(passphrase, data[:saltLength])
+	if err != nil {
+		return nil, errors.New("Error deriving encryption key:\n" + err.Error())
+	}
+	data = data[saltLength:]
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < gcm.NonceSize() {
+		return nil, errors.New("Encrypted file is truncated")
+	}
+	plaintext, err := gcm.Open(nil, data[:gcm.NonceSize()], data[gcm.NonceSize():], fileHeader)
+	if err != nil {
+		return nil, errors.New("Could not decrypt file (is the passphrase correct?)")
+	}
+	return plaintext, nil
+}
@@ -1,15 +1,16 @@
 package helm
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"os"
 	"os/exec"
 	"strings"
-	"time"
 
 	"github.com/dragonchain/dragonchain-installer/internal/configuration"
+	"github.com/dragonchain/dragonchain-installer/internal/wait"
 )
 
 type kubectlPodJSONList struct {
@@ -22,35 +23,36 @@ type kubectlPodJSONList struct {
 	}) `json:"items"`
 }
 
-func waitForTillerToBeReady() error {
-	// Wait up to 60 seconds-ish for tiller pod to be ready before erroring
-	for i := 0; i < 60; i++ {
-		// Wait before checking
-		time.Sleep(1 * time.Second)
-		cmd := exec.Command("kubectl", "get", "pod", "-n", "kube-system", "-l", "name=tiller", "-o", "json", "--context="+configuration.MinikubeContext)
+func waitForTillerToBeReady(ctx context.Context) error {
+	err := wait.Poll(ctx, configuration.TillerReadyTimeout, configuration.PollInterval, func() (bool, error) {
+		cmd := exec.CommandContext(ctx, "kubectl", "get", "pod", "-n", "kube-system", "-l", "name=tiller", "-o", "json", "--context="+configuration.MinikubeContext)
 		cmd.Stderr = os.Stderr
 		output, err := cmd.Output()
 		if err != nil {
-			return errors.New("Error checking for tiller pod " + err.Error())
+			return false, errors.New("Error checking for tiller pod " + err.Error())
 		}
 		var podList kubectlPodJSONList
 		if err := json.Unmarshal(output, &podList); err != nil {
-			return errors.New("Failed to parse pod list from kubectl:\n" + err.Error())
+			return false, errors.New("Failed to parse pod list from kubectl:\n" + err.Error())
 		}
 		for _, items := range podList.Items {
 			for _, status := range items.Status.ContainerStatuses {
 				if status.Ready {
-					return nil
+					return true, nil
 				}
 			}
 		}
+		return false, nil
+	})
+	if err == wait.ErrTimeout {
+		return errors.New("Tiller pod failed to become ready")
 	}
-	return errors.New("Tiller pod failed to become ready")
+	return err
 }
 
 // GetHelmMajorVersion gets the major version of helm (either 2 or 3)
-func GetHelmMajorVersion() (int, error) {
-	cmd := exec.Command("helm", "version", "-c", "--short")
+func GetHelmMajorVersion(ctx context.Context) (int, error) {
+	cmd := exec.CommandContext(ctx, "helm", "version", "-c", "--short")
 	cmd.Stderr = os.Stderr
 	out, err := cmd.Output()
 	if err != nil {
@@ -66,46 +68,45 @@ func GetHelmMajorVersion() (int, error) {
 }
 
 // InitializeHelm intializes helm both locally, and in the minikube cluster
-func InitializeHelm() error {
+func InitializeHelm(ctx context.Context) error {
 	fmt.Println("Configuring helm")
-	helmVersion, err := GetHelmMajorVersion()
+	helmVersion, err := GetHelmMajorVersion(ctx)
 	if err != nil {
 		return err
 	}
 	// Only helm v2 requires tiller initialization
 	if helmVersion == 2 {
-		cmd := exec.Command("helm", "init", "--upgrade", "--kube-context", configuration.MinikubeContext)
+		cmd := exec.CommandContext(ctx, "helm", "init", "--upgrade", "--kube-context", configuration.MinikubeContext)
 		cmd.Stderr = os.Stderr
 		if err := cmd.Run(); err != nil {
 			return errors.New("Initializing helm failed:\n" + err.Error())
 		}
 	}
-	cmd := exec.Command("helm", "repo", "add", "dragonchain", "https://dragonchain-charts.s3.amazonaws.com")
+	cmd := exec.CommandContext(ctx, "helm", "repo", "add", "dragonchain", "https://dragonchain-charts.s3.amazonaws.com")
 	cmd.Stderr = os.Stderr
 	if err := cmd.Run(); err != nil {
 		return errors.New("Adding dragonchain helm repo failed:\n" + err.Error())
 	}
-	cmd = exec.Command("helm", "repo", "add", "openfaas", "https://openfaas.github.io/faas-netes/")
+	cmd = exec.CommandContext(ctx, "helm", "repo", "add", "openfaas", "https://openfaas.github.io/faas-netes/")
 	cmd.Stderr = os.Stderr
 	if err := cmd.Run(); err != nil {
 		return errors.New("Adding openfaas helm repo failed:\n" + err.Error())
 	}
 	if helmVersion >= 3 {
 		// Stable repository is not added by default in helm 3+
-		cmd = exec.Command("helm", "repo", "add", "stable", "https://charts.helm.sh/stable")
+		cmd = exec.CommandContext(ctx, "helm", "repo", "add", "stable", "https://charts.helm.sh/stable")
 		cmd.Stderr = os.Stderr
 		if err := cmd.Run(); err != nil {
 			return errors.New("Adding stable helm repo failed:\n" + err.Error())
 		}
 	}
-	cmd = exec.Command("helm", "repo", "update")
+	cmd = exec.CommandContext(ctx, "helm", "repo", "update")
 	cmd.Stderr = os.Stderr
 	if err := cmd.Run(); err != nil {
 		return errors.New("Updating helm repo failed (are you connected to the internet?):\n" + err.Error())
 	}
 	if helmVersion == 2 {
-		time.Sleep(3 * time.Second)
-		if err := waitForTillerToBeReady(); err != nil {
+		if err := waitForTillerToBeReady(ctx); err != nil {
 			return err
 		}
 	}
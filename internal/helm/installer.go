@@ -1,6 +1,7 @@
 package helm
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"io/ioutil"
@@ -13,13 +14,13 @@ import (
 	"github.com/dragonchain/dragonchain-installer/internal/downloader"
 )
 
-func helmIsInstalled() bool {
-	return exec.Command("helm").Run() == nil
+func helmIsInstalled(ctx context.Context) bool {
+	return exec.CommandContext(ctx, "helm").Run() == nil
 }
 
 // InstallHelmIfNecessary checks if helm is already installed, and installs it if necessary
-func InstallHelmIfNecessary() error {
-	if helmIsInstalled() {
+func InstallHelmIfNecessary(ctx context.Context) error {
+	if helmIsInstalled(ctx) {
 		fmt.Println("helm appears to already be installed")
 		return nil
 	}
@@ -35,11 +36,11 @@ func InstallHelmIfNecessary() error {
 			return errors.New("Environment variable 'SYSTEMROOT' does not exist")
 		}
 		helmZip := filepath.Join(tempDir, "helm.zip")
-		if err := downloader.DownloadFile(helmZip, configuration.WindowsHelmLink); err != nil {
+		if err := downloader.DownloadFile(ctx, helmZip, configuration.WindowsHelmLink); err != nil {
 			return err
 		}
 		// Extract the zip file
-		cmd := exec.Command("powershell", "-nologo", "-noprofile", "-command", "& { Add-Type -A 'System.IO.Compression.FileSystem'; [IO.Compression.ZipFile]::ExtractToDirectory('"+helmZip+"', '"+tempDir+"'); }")
+		cmd := exec.CommandContext(ctx, "powershell", "-nologo", "-noprofile", "-command", "& { Add-Type -A 'System.IO.Compression.FileSystem'; [IO.Compression.ZipFile]::ExtractToDirectory('"+helmZip+"', '"+tempDir+"'); }")
 		cmd.Stderr = os.Stderr
 		if err := cmd.Run(); err != nil {
 			return err
@@ -61,11 +62,11 @@ func InstallHelmIfNecessary() error {
 		}
 		unixInstallPath := filepath.Join("/", "usr", "local", "bin", "helm")
 		// Download the helm gzip package
-		if err := downloader.DownloadFile(tempZip, downloadLink); err != nil {
+		if err := downloader.DownloadFile(ctx, tempZip, downloadLink); err != nil {
 			return err
 		}
 		// Extract the package
-		cmd := exec.Command("tar", "-xzf", tempZip, "-C", tempDir)
+		cmd := exec.CommandContext(ctx, "tar", "-xzf", tempZip, "-C", tempDir)
 		cmd.Stderr = os.Stderr
 		if err := cmd.Run(); err != nil {
 			return err
@@ -77,7 +78,7 @@ func InstallHelmIfNecessary() error {
 			}
 		} else {
 			// Move helm executable into /usr/local/bin (require sudo on linux)
-			cmd := exec.Command("sudo", "mv", filepath.Join(tempDir, extractedFolder, "helm"), unixInstallPath)
+			cmd := exec.CommandContext(ctx, "sudo", "mv", filepath.Join(tempDir, extractedFolder, "helm"), unixInstallPath)
 			cmd.Stderr = os.Stderr
 			cmd.Stdout = os.Stdout
 			cmd.Stdin = os.Stdin
@@ -89,7 +90,7 @@ func InstallHelmIfNecessary() error {
 		log.Fatal("Unsupported operating system")
 	}
 	// Should be installed at this point; if not, something is wrong
-	if !helmIsInstalled() {
+	if !helmIsInstalled(ctx) {
 		return errors.New("Helm failed to install")
 	}
 	return nil
@@ -0,0 +1,127 @@
+package helm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/dragonchain/dragonchain-installer/internal/configuration"
+)
+
+// Release is a helm release in the cluster
+type Release struct {
+	Name      string
+	Namespace string
+	Status    string
+	Chart     string
+}
+
+// Output of `helm list -o json` with helm 3
+type helm3ReleaseList []struct {
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
+	Status    string `json:"status"`
+	Chart     string `json:"chart"`
+}
+
+// Output of `helm list --output json` with helm 2
+type helm2ReleaseList struct {
+	Releases []struct {
+		Name      string `json:"Name"`
+		Namespace string `json:"Namespace"`
+		Status    string `json:"Status"`
+		Chart     string `json:"Chart"`
+	} `json:"Releases"`
+}
+
+// FailedReleases lists the releases which failed or are stuck pending an install, upgrade or rollback. The installer skips
+// deployments whose release already exists, so these are never repaired by running it again
+func FailedReleases(ctx context.Context) ([]Release, error) {
+	helmVersion, err := GetHelmMajorVersion(ctx)
+	if err != nil {
+		return nil, err
+	}
+	cmd := exec.CommandContext(ctx, "helm", "list", "--failed", "--pending", "--output", "json", "--kube-context", configuration.MinikubeContext)
+	if helmVersion > 2 {
+		cmd = exec.CommandContext(ctx, "helm", "list", "--all-namespaces", "--failed", "--pending", "--output", "json", "--kube-context", configuration.MinikubeContext)
+	}
+	cmd.Stderr = os.Stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, errors.New("Couldn't list helm releases:\n" + err.Error())
+	}
+	var releases []Release
+	if strings.TrimSpace(string(out)) == "" {
+		// Helm 2 prints nothing at all when there are no releases
+		return releases, nil
+	}
+	if helmVersion > 2 {
+		var list helm3ReleaseList
+		if err := json.Unmarshal(out, &list); err != nil {
+			return nil, errors.New("Failed to parse release list from helm:\n" + err.Error())
+		}
+		for _, release := range list {
+			releases = append(releases, Release{Name: release.Name, Namespace: release.Namespace, Status: release.Status, Chart: release.Chart})
+		}
+		return releases, nil
+	}
+	var list helm2ReleaseList
+	if err := json.Unmarshal(out, &list); err != nil {
+		return nil, errors.New("Failed to parse release list from helm:\n" + err.Error())
+	}
+	for _, release := range list.Releases {
+		releases = append(releases, Release{Name: release.Name, Namespace: release.Namespace, Status: release.Status, Chart: release.Chart})
+	}
+	return releases, nil
+}
+
+// UninstallRelease removes a helm release, so that the installer deploys it again from scratch
+func UninstallRelease(ctx context.Context, release Release) error {
+	helmVersion, err := GetHelmMajorVersion(ctx)
+	if err != nil {
+		return err
+	}
+	cmd := exec.CommandContext(ctx, "helm", "delete", "--purge", release.Name, "--kube-context", configuration.MinikubeContext)
+	if helmVersion > 2 {
+		cmd = exec.CommandContext(ctx, "helm", "uninstall", release.Name, "-n", release.Namespace, "--kube-context", configuration.MinikubeContext)
+	}
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return errors.New("Error removing helm release " + release.Name + ":\n" + err.Error())
+	}
+	return nil
+}
+
+// MissingRepos lists which of the chart repositories used by the installer haven't been added to helm
+func MissingRepos(ctx context.Context) ([]string, error) {
+	helmVersion, err := GetHelmMajorVersion(ctx)
+	if err != nil {
+		return nil, err
+	}
+	wanted := []string{"dragonchain", "openfaas"}
+	if helmVersion > 2 {
+		wanted = append(wanted, "stable")
+	}
+	cmd := exec.CommandContext(ctx, "helm", "repo", "list")
+	out, err := cmd.Output()
+	if err != nil {
+		// Helm 3 errors when no repositories have been added
+		out = nil
+	}
+	added := map[string]bool{}
+	for _, line := range strings.Split(string(out), "\n") {
+		if fields := strings.Fields(line); len(fields) > 0 {
+			added[fields[0]] = true
+		}
+	}
+	var missing []string
+	for _, repo := range wanted {
+		if !added[repo] {
+			missing = append(missing, repo)
+		}
+	}
+	return missing, nil
+}
@@ -0,0 +1,98 @@
+package interrupt
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+)
+
+type cleanupAction struct {
+	id          int
+	description string
+	action      func()
+}
+
+var mutex sync.Mutex
+var interrupted bool
+var currentStep string
+var cleanupActions []cleanupAction
+var nextID int
+
+// WithSignals returns a context which is cancelled when SIGINT or SIGTERM is received
+func WithSignals(parent context.Context) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(parent)
+	signals := make(chan os.Signal, 2)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		select {
+		case <-signals:
+		case <-ctx.Done():
+			signal.Stop(signals)
+			return
+		}
+		mutex.Lock()
+		interrupted = true
+		mutex.Unlock()
+		fmt.Print("\nInterrupt received, stopping. Press Ctrl-C again to quit immediately without cleaning up\n")
+		cancel()
+		// A second signal means the user doesn't want to wait for cleanup
+		<-signals
+		os.Exit(130)
+	}()
+	return ctx, cancel
+}
+
+// Interrupted returns true if SIGINT or SIGTERM has been received
+func Interrupted() bool {
+	mutex.Lock()
+	defer mutex.Unlock()
+	return interrupted
+}
+
+// SetStep records the step currently in progress so it can be reported if interrupted
+func SetStep(step string) {
+	mutex.Lock()
+	defer mutex.Unlock()
+	currentStep = step
+}
+
+// CurrentStep returns the step currently in progress
+func CurrentStep() string {
+	mutex.Lock()
+	defer mutex.Unlock()
+	return currentStep
+}
+
+// OnInterrupt registers a cleanup action to run if interrupted before the returned done function is called
+func OnInterrupt(description string, action func()) (done func()) {
+	mutex.Lock()
+	defer mutex.Unlock()
+	id := nextID
+	nextID++
+	cleanupActions = append(cleanupActions, cleanupAction{id, description, action})
+	return func() {
+		mutex.Lock()
+		defer mutex.Unlock()
+		for i, cleanup := range cleanupActions {
+			if cleanup.id == id {
+				cleanupActions = append(cleanupActions[:i], cleanupActions[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+// RunCleanups runs all registered cleanup actions, most recently registered first
+func RunCleanups() {
+	mutex.Lock()
+	actions := cleanupActions
+	cleanupActions = nil
+	mutex.Unlock()
+	for i := len(actions) - 1; i >= 0; i-- {
+		fmt.Println("Cleaning up: " + actions[i].description)
+		actions[i].action()
+	}
+}
@@ -0,0 +1,107 @@
+package keyring
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+
+	"github.com/dragonchain/dragonchain-installer/internal/encryption"
+)
+
+const fileStoreName = "encrypted-file"
+
+// Stores secrets in a passphrase-encrypted file, for machines without a usable OS keyring
+type fileStore struct {
+	path       string
+	passphrase func() (string, error)
+	// Cached after first use so the user is only asked once
+	unlocked string
+}
+
+func (store *fileStore) Name() string {
+	return fileStoreName
+}
+
+func (store *fileStore) getPassphrase() (string, error) {
+	if store.unlocked == "" {
+		passphrase, err := store.passphrase()
+		if err != nil {
+			return "", err
+		}
+		store.unlocked = passphrase
+	}
+	return store.unlocked, nil
+}
+
+func (store *fileStore) read() (map[string]string, error) {
+	secrets := map[string]string{}
+	encrypted, err := ioutil.ReadFile(store.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return secrets, nil
+		}
+		return nil, errors.New("Error reading keyring file " + store.path + ":\n" + err.Error())
+	}
+	passphrase, err := store.getPassphrase()
+	if err != nil {
+		return nil, err
+	}
+	contents, err := encryption.Decrypt(encrypted, passphrase)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(contents, &secrets); err != nil {
+		return nil, errors.New("Error parsing keyring file " + store.path + ":\n" + err.Error())
+	}
+	return secrets, nil
+}
+
+func (store *fileStore) write(secrets map[string]string) error {
+	contents, err := json.Marshal(secrets)
+	if err != nil {
+		return err
+	}
+	passphrase, err := store.getPassphrase()
+	if err != nil {
+		return err
+	}
+	encrypted, err := encryption.Encrypt(contents, passphrase)
+	if err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(store.path, encrypted, 0600); err != nil {
+		return errors.New("Error writing keyring file " + store.path + ":\n" + err.Error())
+	}
+	return nil
+}
+
+func (store *fileStore) Set(account string, secret string) error {
+	secrets, err := store.read()
+	if err != nil {
+		return err
+	}
+	secrets[account] = secret
+	return store.write(secrets)
+}
+
+func (store *fileStore) Get(account string) (string, error) {
+	secrets, err := store.read()
+	if err != nil {
+		return "", err
+	}
+	secret, exists := secrets[account]
+	if !exists {
+		return "", ErrNotFound
+	}
+	return secret, nil
+}
+
+func (store *fileStore) Delete(account string) error {
+	secrets, err := store.read()
+	if err != nil {
+		return err
+	}
+	delete(secrets, account)
+	return store.write(secrets)
+}
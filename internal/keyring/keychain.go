@@ -0,0 +1,48 @@
+package keyring
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+const keychainName = "keychain"
+
+// Stores secrets in the macOS login keychain using the security tool
+type keychainStore struct{}
+
+func (store *keychainStore) Name() string {
+	return keychainName
+}
+
+func (store *keychainStore) Set(account string, secret string) error {
+	// Run the command in interactive mode, reading it from stdin, so the secret isn't visible in the process list
+	cmd := exec.Command("security", "-i")
+	cmd.Stdin = strings.NewReader("add-generic-password -U -s " + strconv.Quote(Service) + " -a " + strconv.Quote(account) + " -w " + strconv.Quote(secret) + "\n")
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return errors.New("Error storing secret in keychain:\n" + err.Error())
+	}
+	return nil
+}
+
+func (store *keychainStore) Get(account string) (string, error) {
+	output, err := exec.Command("security", "find-generic-password", "-s", Service, "-a", account, "-w").Output()
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok && exitErr.ExitCode() == 44 {
+			// Exit code for item not found
+			return "", ErrNotFound
+		}
+		return "", errors.New("Error looking up secret in keychain:\n" + err.Error())
+	}
+	return strings.TrimSuffix(string(output), "\n"), nil
+}
+
+func (store *keychainStore) Delete(account string) error {
+	if err := exec.Command("security", "delete-generic-password", "-s", Service, "-a", account).Run(); err != nil {
+		return errors.New("Error removing secret from keychain:\n" + err.Error())
+	}
+	return nil
+}
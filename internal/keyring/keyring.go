@@ -0,0 +1,62 @@
+package keyring
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"runtime"
+)
+
+// Service is the name that secrets are stored under in the OS keyring
+const Service = "dragonchain"
+
+// ErrNotFound is returned when a secret does not exist in a store
+var ErrNotFound = errors.New("Secret not found in keyring")
+
+// Store keeps secrets for accounts (i.e. chain ids) outside of the plain text credentials file
+type Store interface {
+	// Name identifies the store, so that the secret can be found again with ByName
+	Name() string
+	Set(account string, secret string) error
+	Get(account string) (string, error)
+	Delete(account string) error
+}
+
+// Open gets the best store available on this machine: the OS keyring if usable, otherwise an encrypted file at
+// fallbackPath which is unlocked with the passphrase returned by passphrase
+func Open(fallbackPath string, passphrase func() (string, error)) Store {
+	if runtime.GOOS == "linux" && secretServiceAvailable() {
+		return &secretServiceStore{}
+	}
+	if runtime.GOOS == "darwin" && keychainAvailable() {
+		return &keychainStore{}
+	}
+	return &fileStore{path: fallbackPath, passphrase: passphrase}
+}
+
+// ByName gets the store with the given name (as returned by Store.Name)
+func ByName(name string, fallbackPath string, passphrase func() (string, error)) (Store, error) {
+	switch name {
+	case secretServiceName:
+		return &secretServiceStore{}, nil
+	case keychainName:
+		return &keychainStore{}, nil
+	case fileStoreName:
+		return &fileStore{path: fallbackPath, passphrase: passphrase}, nil
+	}
+	return nil, errors.New("Unknown keyring '" + name + "'")
+}
+
+// The secret service is reached over the D-Bus session bus, which doesn't exist in i.e. ssh sessions without a desktop
+func secretServiceAvailable() bool {
+	if _, err := exec.LookPath("secret-tool"); err != nil {
+		return false
+	}
+	_, exists := os.LookupEnv("DBUS_SESSION_BUS_ADDRESS")
+	return exists
+}
+
+func keychainAvailable() bool {
+	_, err := exec.LookPath("security")
+	return err == nil
+}
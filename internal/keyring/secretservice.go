@@ -0,0 +1,53 @@
+package keyring
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+const secretServiceName = "secret-service"
+
+// Stores secrets with the freedesktop secret service (i.e. gnome-keyring or kwallet) using libsecret's secret-tool
+type secretServiceStore struct{}
+
+func (store *secretServiceStore) Name() string {
+	return secretServiceName
+}
+
+func (store *secretServiceStore) Set(account string, secret string) error {
+	cmd := exec.Command("secret-tool", "store", "--label=Dragonchain "+account, "service", Service, "account", account)
+	// Read from stdin so the secret isn't visible in the process list
+	cmd.Stdin = strings.NewReader(secret)
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return errors.New("Error storing secret with secret-tool:\n" + err.Error())
+	}
+	return nil
+}
+
+func (store *secretServiceStore) Get(account string) (string, error) {
+	var stderr bytes.Buffer
+	cmd := exec.Command("secret-tool", "lookup", "service", Service, "account", account)
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		if stderr.Len() == 0 {
+			// secret-tool exits unsuccessfully without any message when nothing matches
+			return "", ErrNotFound
+		}
+		return "", errors.New("Error looking up secret with secret-tool:\n" + err.Error() + "\n" + stderr.String())
+	}
+	return string(output), nil
+}
+
+func (store *secretServiceStore) Delete(account string) error {
+	cmd := exec.Command("secret-tool", "clear", "service", Service, "account", account)
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return errors.New("Error removing secret with secret-tool:\n" + err.Error())
+	}
+	return nil
+}
@@ -1,6 +1,7 @@
 package kubectl
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"io/ioutil"
@@ -13,13 +14,13 @@ import (
 	"github.com/dragonchain/dragonchain-installer/internal/downloader"
 )
 
-func kubectlIsInstalled() bool {
-	return exec.Command("kubectl").Run() == nil
+func kubectlIsInstalled(ctx context.Context) bool {
+	return exec.CommandContext(ctx, "kubectl").Run() == nil
 }
 
 // InstallKubectlIfNecessary checks if kubectl is already installed, and installs it if necessary
-func InstallKubectlIfNecessary() error {
-	if kubectlIsInstalled() {
+func InstallKubectlIfNecessary(ctx context.Context) error {
+	if kubectlIsInstalled(ctx) {
 		fmt.Println("kubectl appears to already be installed")
 		return nil
 	}
@@ -29,7 +30,7 @@ func InstallKubectlIfNecessary() error {
 		if !exists {
 			return errors.New("Environment variable 'SYSTEMROOT' does not exist")
 		}
-		if err := downloader.DownloadFile(filepath.Join(systemRoot, "kubectl.exe"), configuration.WindowsKubectlLink); err != nil {
+		if err := downloader.DownloadFile(ctx, filepath.Join(systemRoot, "kubectl.exe"), configuration.WindowsKubectlLink); err != nil {
 			return err
 		}
 	} else if configuration.Macos || configuration.Linux {
@@ -47,7 +48,7 @@ func InstallKubectlIfNecessary() error {
 		} else if configuration.Macos {
 			downloadLink = configuration.MacosKubectlLink
 		}
-		if err := downloader.DownloadFile(tempPath, downloadLink); err != nil {
+		if err := downloader.DownloadFile(ctx, tempPath, downloadLink); err != nil {
 			return err
 		}
 		if err := os.Chmod(tempPath, allowExecute); err != nil {
@@ -60,7 +61,7 @@ func InstallKubectlIfNecessary() error {
 			}
 		} else {
 			// Move kubectl executable into /usr/local/bin (require sudo on linux)
-			cmd := exec.Command("sudo", "mv", tempPath, unixBinaryPath)
+			cmd := exec.CommandContext(ctx, "sudo", "mv", tempPath, unixBinaryPath)
 			cmd.Stderr = os.Stderr
 			cmd.Stdout = os.Stdout
 			cmd.Stdin = os.Stdin
@@ -72,7 +73,7 @@ func InstallKubectlIfNecessary() error {
 		log.Fatal("Unsupported operating system")
 	}
 	// Should be installed at this point; if not, something is wrong
-	if !kubectlIsInstalled() {
+	if !kubectlIsInstalled(ctx) {
 		return errors.New("Kubectl failed to install")
 	}
 	return nil
@@ -0,0 +1,178 @@
+package migrate
+
+import (
+	"archive/tar"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+)
+
+const formatVersion = 1
+const manifestEntry = "manifest.json"
+const identityEntry = "identity.enc"
+const volumeEntryPrefix = "volumes/"
+
+// Manifest describes the contents of a migration archive
+type Manifest struct {
+	FormatVersion    int      `json:"FormatVersion"`
+	InstallerVersion string   `json:"InstallerVersion"`
+	PublicID         string   `json:"PublicID"`
+	InternalID       string   `json:"InternalID"`
+	Created          string   `json:"Created"`
+	Volumes          []Volume `json:"Volumes"`
+}
+
+// Volume describes the archived data of one of the chain's persistent volume claims
+type Volume struct {
+	Claim  string `json:"Claim"`
+	Entry  string `json:"Entry"`
+	Size   int64  `json:"Size"`
+	SHA256 string `json:"SHA256"`
+}
+
+func writeEntry(archive *tar.Writer, name string, size int64, contents io.Reader) error {
+	header := &tar.Header{Name: name, Mode: 0600, Size: size, ModTime: time.Now()}
+	if err := archive.WriteHeader(header); err != nil {
+		return err
+	}
+	_, err := io.Copy(archive, contents)
+	return err
+}
+
+// Writes the archive: manifest first, then the encrypted identity, then each volume's data from the files in volumeFiles (by claim)
+func writeArchive(path string, manifest *Manifest, identity []byte, volumeFiles map[string]string) (err error) {
+	manifestJSON, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	out, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return errors.New("Error creating file " + path + ":\n" + err.Error())
+	}
+	defer out.Close()
+	// Don't leave an incomplete archive behind
+	defer func() {
+		if err != nil {
+			out.Close()
+			os.Remove(path)
+		}
+	}()
+	archive := tar.NewWriter(out)
+	if err := writeEntry(archive, manifestEntry, int64(len(manifestJSON)), bytes.NewReader(manifestJSON)); err != nil {
+		return errors.New("Error writing migration archive:\n" + err.Error())
+	}
+	if err := writeEntry(archive, identityEntry, int64(len(identity)), bytes.NewReader(identity)); err != nil {
+		return errors.New("Error writing migration archive:\n" + err.Error())
+	}
+	for _, volume := range manifest.Volumes {
+		file, err := os.Open(volumeFiles[volume.Claim])
+		if err != nil {
+			return err
+		}
+		err = writeEntry(archive, volume.Entry, volume.Size, file)
+		file.Close()
+		if err != nil {
+			return errors.New("Error writing volume " + volume.Claim + " to migration archive:\n" + err.Error())
+		}
+	}
+	if err := archive.Close(); err != nil {
+		return errors.New("Error writing migration archive:\n" + err.Error())
+	}
+	return nil
+}
+
+// ReadArchive reads the manifest and encrypted identity from a migration archive
+func ReadArchive(path string) (*Manifest, []byte, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, nil, errors.New("Error opening migration archive " + path + ":\n" + err.Error())
+	}
+	defer file.Close()
+	var manifest *Manifest
+	var identity []byte
+	archive := tar.NewReader(file)
+	for manifest == nil || identity == nil {
+		header, err := archive.Next()
+		if err == io.EOF {
+			return nil, nil, errors.New("Migration archive is missing its manifest or identity")
+		} else if err != nil {
+			return nil, nil, errors.New("Error reading migration archive:\n" + err.Error())
+		}
+		contents, err := ioutil.ReadAll(archive)
+		if err != nil {
+			return nil, nil, errors.New("Error reading migration archive:\n" + err.Error())
+		}
+		if header.Name == manifestEntry {
+			manifest = new(Manifest)
+			if err := json.Unmarshal(contents, manifest); err != nil {
+				return nil, nil, errors.New("Error parsing migration archive manifest:\n" + err.Error())
+			}
+		} else if header.Name == identityEntry {
+			identity = contents
+		}
+	}
+	if manifest.FormatVersion != formatVersion {
+		return nil, nil, errors.New("Migration archive format is not supported by this version of the installer")
+	}
+	return manifest, identity, nil
+}
+
+// Checks that the archive has the data of each of the manifest's volumes, matching its checksum
+func verifyVolumes(path string, manifest *Manifest) error {
+	checksums := map[string]string{}
+	for _, volume := range manifest.Volumes {
+		checksums[volume.Entry] = volume.SHA256
+	}
+	err := forEachVolume(path, func(entry string, contents io.Reader) error {
+		checksum, exists := checksums[entry]
+		if !exists {
+			return nil
+		}
+		hash := sha256.New()
+		if _, err := io.Copy(hash, contents); err != nil {
+			return errors.New("Error reading migration archive:\n" + err.Error())
+		}
+		if hex.EncodeToString(hash.Sum(nil)) != checksum {
+			return errors.New("Data for " + entry + " in migration archive is corrupt (checksum mismatch)")
+		}
+		delete(checksums, entry)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if len(checksums) > 0 {
+		return errors.New("Migration archive is missing data for some of its volumes")
+	}
+	return nil
+}
+
+// Calls restore with the contents of each volume entry in the archive
+func forEachVolume(path string, restore func(name string, contents io.Reader) error) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return errors.New("Error opening migration archive " + path + ":\n" + err.Error())
+	}
+	defer file.Close()
+	archive := tar.NewReader(file)
+	for {
+		header, err := archive.Next()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return errors.New("Error reading migration archive:\n" + err.Error())
+		}
+		if strings.HasPrefix(header.Name, volumeEntryPrefix) {
+			if err := restore(header.Name, archive); err != nil {
+				return err
+			}
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package migrate
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// A gzipped tar with the given files
+func volumeData(t *testing.T, files map[string]string) []byte {
+	var buffer bytes.Buffer
+	compressed := gzip.NewWriter(&buffer)
+	archive := tar.NewWriter(compressed)
+	for name, contents := range files {
+		if err := archive.WriteHeader(&tar.Header{Name: name, Mode: 0600, Size: int64(len(contents)), Typeflag: tar.TypeReg}); err != nil {
+			t.Fatal(err)
+		}
+		archive.Write([]byte(contents))
+	}
+	archive.Close()
+	compressed.Close()
+	return buffer.Bytes()
+}
+
+// Write a migration archive with one volume holding data, whose manifest has checksum (or the data's checksum if empty)
+func testArchive(t *testing.T, folder string, data []byte, checksum string) (string, *Manifest) {
+	volumeFile := filepath.Join(folder, "volume.tar.gz")
+	if err := ioutil.WriteFile(volumeFile, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if checksum == "" {
+		hash := sha256.Sum256(data)
+		checksum = hex.EncodeToString(hash[:])
+	}
+	manifest := &Manifest{FormatVersion: formatVersion, InternalID: "test", Volumes: []Volume{
+		{Claim: "d-test-redis", Entry: volumeEntryPrefix + "d-test-redis.tar.gz", Size: int64(len(data)), SHA256: checksum},
+	}}
+	path := filepath.Join(folder, "migration.tar")
+	if err := writeArchive(path, manifest, []byte("identity"), map[string]string{"d-test-redis": volumeFile}); err != nil {
+		t.Fatal(err)
+	}
+	return path, manifest
+}
+
+func TestVerifyVolumes(t *testing.T) {
+	folder, err := ioutil.TempDir("", "migrate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(folder)
+	data := volumeData(t, map[string]string{"dump.rdb": "ledger"})
+
+	path, manifest := testArchive(t, folder, data, "")
+	if err := verifyVolumes(path, manifest); err != nil {
+		t.Errorf("Expected an intact archive to verify, got %v", err)
+	}
+
+	path, manifest = testArchive(t, folder, data[:len(data)/2], hex.EncodeToString(make([]byte, 32)))
+	if err := verifyVolumes(path, manifest); err == nil || !strings.Contains(err.Error(), "checksum mismatch") {
+		t.Errorf("Expected a truncated volume to fail verification, got %v", err)
+	}
+
+	path, manifest = testArchive(t, folder, data, "")
+	manifest.Volumes = append(manifest.Volumes, Volume{Claim: "d-test-webserver", Entry: volumeEntryPrefix + "d-test-webserver.tar.gz"})
+	if err := verifyVolumes(path, manifest); err == nil || !strings.Contains(err.Error(), "missing data") {
+		t.Errorf("Expected a missing volume to fail verification, got %v", err)
+	}
+}
+
+// Run a helper pod script locally against a folder standing in for a mounted volume
+func runScript(t *testing.T, script string, input []byte) error {
+	cmd := exec.Command("sh", "-c", script)
+	cmd.Stdin = bytes.NewReader(input)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return errors.New(string(output))
+	}
+	return nil
+}
+
+func TestStageAndCommitScripts(t *testing.T) {
+	if _, err := exec.LookPath("tar"); err != nil {
+		t.Skip("tar is not available")
+	}
+	mount, err := ioutil.TempDir("", "volume")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(mount)
+	if err := ioutil.WriteFile(filepath.Join(mount, "old.rdb"), []byte("old ledger"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	// A truncated archive fails to extract, leaving the volume's data alone
+	data := volumeData(t, map[string]string{"dump.rdb": strings.Repeat("ledger", 10000), ".hidden": "config"})
+	if err := runScript(t, stageScript(mount), data[:len(data)/2]); err == nil {
+		t.Error("Expected a truncated archive to fail")
+	}
+	if contents, err := ioutil.ReadFile(filepath.Join(mount, "old.rdb")); err != nil || string(contents) != "old ledger" {
+		t.Errorf("Expected the volume's data to be untouched, got %q (%v)", contents, err)
+	}
+
+	if err := runScript(t, stageScript(mount), data); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(mount, "old.rdb")); err != nil {
+		t.Errorf("Expected the volume's data to be kept while staging: %v", err)
+	}
+	if err := runScript(t, commitScript(mount), nil); err != nil {
+		t.Fatal(err)
+	}
+	entries, err := ioutil.ReadDir(mount)
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := []string{}
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	if strings.Join(names, ",") != ".hidden,dump.rdb" {
+		t.Errorf("Expected only the restored files in the volume, got %v", names)
+	}
+}
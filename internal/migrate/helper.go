@@ -0,0 +1,154 @@
+package migrate
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+
+	"github.com/dragonchain/dragonchain-installer/internal/configuration"
+	"github.com/dragonchain/dragonchain-installer/internal/wait"
+)
+
+type kubectlPodJSON struct {
+	Status (struct {
+		Phase string `json:"phase"`
+	}) `json:"status"`
+}
+
+func helperPodName(config *configuration.Configuration) string {
+	return "d-" + config.InternalID + "-migrate"
+}
+
+// Directory in the helper pod where a volume claim is mounted
+func mountPath(claim string) string {
+	return "/data/" + claim
+}
+
+// Starts a pod with all of the chain's volume claims mounted in order to copy data in and out of them
+func startHelperPod(ctx context.Context, config *configuration.Configuration, claims []string) error {
+	volumes := []map[string]interface{}{}
+	mounts := []map[string]interface{}{}
+	for i, claim := range claims {
+		name := "volume-" + strconv.Itoa(i)
+		volumes = append(volumes, map[string]interface{}{"name": name, "persistentVolumeClaim": map[string]interface{}{"claimName": claim}})
+		mounts = append(mounts, map[string]interface{}{"name": name, "mountPath": mountPath(claim)})
+	}
+	pod := map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "Pod",
+		"metadata":   map[string]interface{}{"name": helperPodName(config), "namespace": "dragonchain"},
+		"spec": map[string]interface{}{
+			"restartPolicy": "Never",
+			"containers": []map[string]interface{}{{
+				"name":         "migrate",
+				"image":        configuration.MigrationHelperImage,
+				"command":      []string{"sleep", "86400"},
+				"volumeMounts": mounts,
+			}},
+			"volumes": volumes,
+		},
+	}
+	podJSON, err := json.Marshal(pod)
+	if err != nil {
+		return err
+	}
+	cmd := exec.CommandContext(ctx, "kubectl", "apply", "--context="+configuration.MinikubeContext, "-f", "-")
+	cmd.Stderr = os.Stderr
+	cmd.Stdin = bytes.NewBuffer(podJSON)
+	if err := cmd.Run(); err != nil {
+		return errors.New("Error creating migration helper pod:\n" + err.Error())
+	}
+	err = wait.Poll(ctx, configuration.DragonchainReadyTimeout, configuration.PollInterval, func() (bool, error) {
+		cmd := exec.CommandContext(ctx, "kubectl", "get", "pod", helperPodName(config), "-n", "dragonchain", "-o", "json", "--context="+configuration.MinikubeContext)
+		cmd.Stderr = os.Stderr
+		output, err := cmd.Output()
+		if err != nil {
+			return false, errors.New("Error checking migration helper pod:\n" + err.Error())
+		}
+		var helperPod kubectlPodJSON
+		if err := json.Unmarshal(output, &helperPod); err != nil {
+			return false, errors.New("Failed to parse pod from kubectl:\n" + err.Error())
+		}
+		return helperPod.Status.Phase == "Running", nil
+	})
+	if err == wait.ErrTimeout {
+		return errors.New("Migration helper pod failed to start. Check kubernetes cluster for more information")
+	}
+	return err
+}
+
+// Deletes the helper pod. Also used when cleaning up after an interrupt, so deliberately not bound to the installer context
+func deleteHelperPod(config *configuration.Configuration) error {
+	cmd := exec.Command("kubectl", "delete", "pod", helperPodName(config), "-n", "dragonchain", "--ignore-not-found", "--wait=false", "--context="+configuration.MinikubeContext)
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return errors.New("Error deleting migration helper pod:\n" + err.Error())
+	}
+	return nil
+}
+
+// Writes a gzipped tar of a volume claim's contents to output
+func copyVolumeOut(ctx context.Context, config *configuration.Configuration, claim string, output io.Writer) error {
+	cmd := exec.CommandContext(ctx, "kubectl", "exec", helperPodName(config), "-n", "dragonchain", "--context="+configuration.MinikubeContext, "--", "tar", "-C", mountPath(claim), "-czf", "-", ".")
+	cmd.Stderr = os.Stderr
+	cmd.Stdout = output
+	if err := cmd.Run(); err != nil {
+		return errors.New("Error copying data out of volume " + claim + ":\n" + err.Error())
+	}
+	return nil
+}
+
+// Name of the directory in a mounted volume where restored data is extracted before it replaces the volume's contents.
+// It is on the same filesystem as the volume, so the data can be moved into place without copying it again
+const stagingDir = ".dc-installer-restore"
+
+// Runs a shell script in the helper pod, with input (if not nil) as its stdin
+func runInHelperPod(ctx context.Context, config *configuration.Configuration, script string, input io.Reader) error {
+	cmd := exec.CommandContext(ctx, "kubectl", "exec", "-i", helperPodName(config), "-n", "dragonchain", "--context="+configuration.MinikubeContext, "--", "sh", "-c", script)
+	cmd.Stderr = os.Stderr
+	cmd.Stdin = input
+	return cmd.Run()
+}
+
+// Script extracting a gzipped tar from stdin into the staging directory of the volume mounted at mount
+func stageScript(mount string) string {
+	staging := mount + "/" + stagingDir
+	return "rm -rf " + staging + " && mkdir " + staging + " && tar -C " + staging + " -xzf -"
+}
+
+// Script replacing the contents of the volume mounted at mount with its staging directory's contents
+func commitScript(mount string) string {
+	staging := mount + "/" + stagingDir
+	return "find " + mount + " -mindepth 1 -maxdepth 1 ! -name " + stagingDir + " -exec rm -rf {} + && " +
+		"find " + staging + " -mindepth 1 -maxdepth 1 -exec mv {} " + mount + "/ \\; && rmdir " + staging
+}
+
+// Extracts the gzipped tar read from input into the volume claim's staging directory, leaving its current contents alone
+func stageVolumeIn(ctx context.Context, config *configuration.Configuration, claim string, input io.Reader) error {
+	if err := runInHelperPod(ctx, config, stageScript(mountPath(claim)), input); err != nil {
+		return errors.New("Error copying data into volume " + claim + ":\n" + err.Error())
+	}
+	return nil
+}
+
+// Replaces the volume claim's contents with the data extracted into its staging directory
+func commitStagedVolume(ctx context.Context, config *configuration.Configuration, claim string) error {
+	if err := runInHelperPod(ctx, config, commitScript(mountPath(claim)), nil); err != nil {
+		return errors.New("Error replacing data of volume " + claim + ":\n" + err.Error())
+	}
+	return nil
+}
+
+// Removes data extracted into the volume claim's staging directory which won't be used. Not bound to the installer context,
+// since it cleans up after failures
+func discardStagedVolume(config *configuration.Configuration, claim string) {
+	if err := runInHelperPod(context.Background(), config, "rm -rf "+mountPath(claim)+"/"+stagingDir, nil); err != nil {
+		fmt.Println("Failed to remove partially restored data from volume " + claim + ":\n" + err.Error())
+	}
+}
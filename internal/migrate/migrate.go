@@ -0,0 +1,211 @@
+package migrate
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/dragonchain/dragonchain-installer/internal/configuration"
+	"github.com/dragonchain/dragonchain-installer/internal/dragonchain"
+	"github.com/dragonchain/dragonchain-installer/internal/interrupt"
+)
+
+// Copies a volume's data into a local file, returning its description for the manifest
+func copyVolumeToFile(ctx context.Context, config *configuration.Configuration, claim string, path string) (*Volume, error) {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return nil, errors.New("Error creating file " + path + ":\n" + err.Error())
+	}
+	defer file.Close()
+	hash := sha256.New()
+	counter := &countingWriter{}
+	if err := copyVolumeOut(ctx, config, claim, io.MultiWriter(file, hash, counter)); err != nil {
+		return nil, err
+	}
+	volume := new(Volume)
+	volume.Claim = claim
+	volume.Entry = volumeEntryPrefix + claim + ".tar.gz"
+	volume.Size = counter.count
+	volume.SHA256 = hex.EncodeToString(hash.Sum(nil))
+	return volume, nil
+}
+
+type countingWriter struct {
+	count int64
+}
+
+func (writer *countingWriter) Write(p []byte) (int, error) {
+	writer.count += int64(len(p))
+	return len(p), nil
+}
+
+// Scales down the chain and starts the helper pod, registering cleanup to bring the chain back up if interrupted
+func stopChainForCopy(ctx context.Context, config *configuration.Configuration, claims []string) (workloads []dragonchain.Workload, done func(), err error) {
+	fmt.Println("Stopping chain to copy its data")
+	workloads, err = dragonchain.ScaleDownDragonchain(ctx, config)
+	done = interrupt.OnInterrupt("restarting chain", func() {
+		deleteHelperPod(config)
+		dragonchain.ScaleUpDragonchain(context.Background(), config, workloads)
+	})
+	if err != nil {
+		return workloads, done, err
+	}
+	if err := startHelperPod(ctx, config, claims); err != nil {
+		return workloads, done, err
+	}
+	return workloads, done, nil
+}
+
+// Removes the helper pod and brings the chain back up after copying failed, so that an error doesn't leave the chain stopped.
+// Deliberately not bound to the installer context, which may have been cancelled
+func abortCopy(config *configuration.Configuration, workloads []dragonchain.Workload, done func()) {
+	done()
+	fmt.Println("Restarting chain after failure")
+	deleteHelperPod(config)
+	if err := dragonchain.ScaleUpDragonchain(context.Background(), config, workloads); err != nil {
+		fmt.Println("Failed to restart chain:\n" + err.Error())
+	}
+	fmt.Print("\n")
+}
+
+// Removes the helper pod and brings the chain back up. The chain's data is already copied, so failing to remove the helper pod
+// doesn't stop the chain from being started
+func restartChainAfterCopy(ctx context.Context, config *configuration.Configuration, workloads []dragonchain.Workload, done func()) error {
+	if err := deleteHelperPod(config); err != nil {
+		fmt.Println(err.Error() + "\nRemove it with 'kubectl delete pod " + helperPodName(config) + " -n dragonchain --context=" + configuration.MinikubeContext + "'")
+	}
+	fmt.Println("Restarting chain")
+	done()
+	err := dragonchain.ScaleUpDragonchain(ctx, config, workloads)
+	fmt.Print("\n")
+	return err
+}
+
+// Export copies a chain's volume data and encrypted identity into a migration archive at path.
+// The chain is stopped while its data is copied, and started again afterwards
+func Export(ctx context.Context, config *configuration.Configuration, pubID string, identity []byte, path string) error {
+	claims, err := dragonchain.ListDragonchainVolumes(ctx, config)
+	if err != nil {
+		return err
+	}
+	tempDir, err := ioutil.TempDir("", "dcinstaller")
+	if err != nil {
+		return errors.New("Creating temporary directory failed:\n" + err.Error())
+	}
+	defer os.RemoveAll(tempDir)
+	workloads, done, err := stopChainForCopy(ctx, config, claims)
+	restarted := false
+	defer func() {
+		if !restarted {
+			abortCopy(config, workloads, done)
+		}
+	}()
+	if err != nil {
+		return err
+	}
+	manifest := new(Manifest)
+	manifest.FormatVersion = formatVersion
+	manifest.InstallerVersion = configuration.Version
+	manifest.PublicID = pubID
+	manifest.InternalID = config.InternalID
+	manifest.Created = time.Now().UTC().Format(time.RFC3339)
+	volumeFiles := map[string]string{}
+	for _, claim := range claims {
+		fmt.Println("Copying data from volume " + claim)
+		volumeFiles[claim] = filepath.Join(tempDir, claim+".tar.gz")
+		volume, err := copyVolumeToFile(ctx, config, claim, volumeFiles[claim])
+		if err != nil {
+			return err
+		}
+		manifest.Volumes = append(manifest.Volumes, *volume)
+	}
+	if err := restartChainAfterCopy(ctx, config, workloads, done); err != nil {
+		return err
+	}
+	restarted = true
+	fmt.Println("Writing migration archive " + path)
+	return writeArchive(path, manifest, identity, volumeFiles)
+}
+
+// RestoreVolumes replaces the data of a newly deployed chain's volumes with the data from a migration archive
+func RestoreVolumes(ctx context.Context, config *configuration.Configuration, path string) error {
+	manifest, _, err := ReadArchive(path)
+	if err != nil {
+		return err
+	}
+	if manifest.InternalID != config.InternalID {
+		return errors.New("Migration archive is for chain ID " + manifest.InternalID + ", not " + config.InternalID)
+	}
+	claims, err := dragonchain.ListDragonchainVolumes(ctx, config)
+	if err != nil {
+		return err
+	}
+	volumes := map[string]Volume{}
+	archivedClaims := []string{}
+	for _, volume := range manifest.Volumes {
+		found := false
+		for _, claim := range claims {
+			found = found || claim == volume.Claim
+		}
+		if !found {
+			return errors.New("Volume " + volume.Claim + " from migration archive does not exist in the new deployment")
+		}
+		volumes[volume.Entry] = volume
+		archivedClaims = append(archivedClaims, volume.Claim)
+	}
+	// Check all of the data before the chain is stopped, so that a corrupt archive doesn't touch its volumes
+	fmt.Println("Verifying migration archive " + path)
+	if err := verifyVolumes(path, manifest); err != nil {
+		return err
+	}
+	workloads, done, err := stopChainForCopy(ctx, config, archivedClaims)
+	restarted := false
+	defer func() {
+		if !restarted {
+			abortCopy(config, workloads, done)
+		}
+	}()
+	if err != nil {
+		return err
+	}
+	err = forEachVolume(path, func(entry string, contents io.Reader) error {
+		volume, exists := volumes[entry]
+		if !exists {
+			return nil
+		}
+		fmt.Println("Restoring data to volume " + volume.Claim)
+		// The volume's current data is only replaced once the data copied in is known to be complete and intact
+		hash := sha256.New()
+		if err := stageVolumeIn(ctx, config, volume.Claim, io.TeeReader(contents, hash)); err != nil {
+			discardStagedVolume(config, volume.Claim)
+			return err
+		}
+		if hex.EncodeToString(hash.Sum(nil)) != volume.SHA256 {
+			discardStagedVolume(config, volume.Claim)
+			return errors.New("Data for volume " + volume.Claim + " in migration archive is corrupt (checksum mismatch)")
+		}
+		if err := commitStagedVolume(ctx, config, volume.Claim); err != nil {
+			return err
+		}
+		delete(volumes, entry)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if len(volumes) > 0 {
+		return errors.New("Migration archive is missing data for some of its volumes")
+	}
+	if err := restartChainAfterCopy(ctx, config, workloads, done); err != nil {
+		return err
+	}
+	restarted = true
+	return nil
+}
@@ -1,6 +1,7 @@
 package minikube
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -19,21 +20,33 @@ type minikubeProfileList struct {
 	}) `json:"valid"`
 }
 
-func existingMinikubeClusterExists(useVM bool) (bool, error) {
+// Home gets the folder where minikube keeps its profiles, VMs and caches (MINIKUBE_HOME, or ~/.minikube by default)
+func Home() (string, error) {
+	if home, exists := os.LookupEnv("MINIKUBE_HOME"); exists {
+		return home, nil
+	}
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", errors.New("Error getting home dir:\n" + err.Error())
+	}
+	return filepath.Join(homeDir, ".minikube"), nil
+}
+
+func existingMinikubeClusterExists(ctx context.Context, useVM bool) (bool, error) {
 	if !useVM {
 		// When using vmdriver none, we cannot use minikube profiles, and start/resume command is the same
 		return true, nil
 	}
 	// Make sure minikube profiles folder exists or minikube can unexpectedly fail: https://github.com/kubernetes/minikube/issues/5898
-	homeDir, err := os.UserHomeDir()
+	home, err := Home()
 	if err != nil {
-		return false, errors.New("Error getting home dir:\n" + err.Error())
+		return false, err
 	}
-	if err := os.MkdirAll(filepath.Join(homeDir, ".minikube", "profiles"), os.ModePerm); err != nil {
+	if err := os.MkdirAll(filepath.Join(home, "profiles"), os.ModePerm); err != nil {
 		return false, errors.New("Failed to confirm or create minikube profiles folder:\n" + err.Error())
 	}
 	// Get profile list from minikube
-	cmd := exec.Command("minikube", "profile", "list", "-o", "json")
+	cmd := exec.CommandContext(ctx, "minikube", "profile", "list", "-o", "json")
 	cmd.Stderr = os.Stderr
 	out, err := cmd.Output()
 	if err != nil {
@@ -51,6 +64,20 @@ func existingMinikubeClusterExists(useVM bool) (bool, error) {
 	return false, nil
 }
 
+// ClusterCreated checks whether minikube has already created the cluster, without needing minikube to be installed
+func ClusterCreated(useVM bool) bool {
+	profile := configuration.MinikubeContext
+	if !useVM {
+		profile = "minikube"
+	}
+	home, err := Home()
+	if err != nil {
+		return false
+	}
+	_, err = os.Stat(filepath.Join(home, "profiles", profile, "config.json"))
+	return err == nil
+}
+
 // FriendlyStartStopCommand returns the strings of the start/stop commands that a user can use to start stop minikube (and thus the dragonchain)
 func FriendlyStartStopCommand(useVM bool) (startCommand string, stopCommand string) {
 	if useVM {
@@ -63,8 +90,32 @@ func FriendlyStartStopCommand(useVM bool) (startCommand string, stopCommand stri
 	return
 }
 
+// SetKubeContext sets the kubernetes context used to talk to the cluster, which differs when not using a VM
+func SetKubeContext(useVM bool) {
+	if !useVM {
+		// Minikube profiles (and thus custom context names) are not supported with vmdriver none
+		configuration.MinikubeContext = "minikube"
+	}
+}
+
+// GetClusterIP gets the ip that the cluster's node ports are reachable on from this machine
+func GetClusterIP(ctx context.Context, useVM bool) (string, error) {
+	if !useVM {
+		// With vmdriver none, the cluster runs directly on this machine
+		return "127.0.0.1", nil
+	}
+	cmd := exec.CommandContext(ctx, "minikube", "ip", "-p", configuration.MinikubeContext)
+	cmd.Stderr = os.Stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", errors.New("Couldn't get minikube VM ip:\n" + err.Error())
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
 // StartMinikubeCluster starts (or creates and starts) the minikube cluster with a configured profile
-func StartMinikubeCluster(useVM bool) error {
+func StartMinikubeCluster(ctx context.Context, config *configuration.Configuration) error {
+	useVM := config.UseVM
 	// Switch current directory to the systemroot on C:\ if running on windows to avoid minikube bug: https://github.com/kubernetes/minikube/issues/1574
 	if configuration.Windows {
 		systemRoot, exists := os.LookupEnv("SYSTEMROOT")
@@ -75,7 +126,7 @@ func StartMinikubeCluster(useVM bool) error {
 			return errors.New("Error switching directory:\n" + err.Error())
 		}
 	}
-	exists, err := existingMinikubeClusterExists(useVM)
+	exists, err := existingMinikubeClusterExists(ctx, useVM)
 	if err != nil {
 		return err
 	}
@@ -83,17 +134,18 @@ func StartMinikubeCluster(useVM bool) error {
 	var minikubeStartCmd *exec.Cmd
 	if !useVM {
 		fmt.Println("\nStarting minikube cluster; This can take a while")
-		minikubeStartCmd = exec.Command("sudo", "-E", "minikube", "start", "--kubernetes-version="+configuration.KubernetesVersion, "--vm-driver=none")
-		configuration.MinikubeContext = "minikube"
+		minikubeStartCmd = exec.CommandContext(ctx, "sudo", "-E", "minikube", "start", "--kubernetes-version="+configuration.KubernetesVersion, "--vm-driver=none")
 	} else {
 		if exists {
 			fmt.Println("\nStarting existing minikube cluster '" + configuration.MinikubeContext + "'; This can take a while")
-			minikubeStartCmd = exec.Command("minikube", "start", "-p", configuration.MinikubeContext, "--kubernetes-version="+configuration.KubernetesVersion)
+			minikubeStartCmd = exec.CommandContext(ctx, "minikube", "start", "-p", configuration.MinikubeContext, "--kubernetes-version="+configuration.KubernetesVersion)
 		} else {
 			fmt.Println("\nStarting new minikube cluster '" + configuration.MinikubeContext + "'; This can take a while")
-			minikubeStartCmd = exec.Command("minikube", "start", "-p", configuration.MinikubeContext, "--kubernetes-version="+configuration.KubernetesVersion, "--vm-driver=virtualbox", "--memory="+configuration.MinikubeVMMemory, "--cpus="+strconv.Itoa(configuration.MinikubeCpus))
+			memory, cpus, diskSize := configuration.VMResources(config)
+			minikubeStartCmd = exec.CommandContext(ctx, "minikube", "start", "-p", configuration.MinikubeContext, "--kubernetes-version="+configuration.KubernetesVersion, "--vm-driver=virtualbox", "--memory="+memory, "--cpus="+strconv.Itoa(cpus), "--disk-size="+diskSize)
 		}
 	}
+	SetKubeContext(useVM)
 	minikubeStartCmd.Stdout = os.Stdout
 	minikubeStartCmd.Stderr = os.Stderr
 	minikubeStartCmd.Stdin = os.Stdin
@@ -102,13 +154,13 @@ func StartMinikubeCluster(useVM bool) error {
 	}
 	if !useVM {
 		// Minikube with no vm driver writes kube configs as root; we need to fix that
-		cmd := exec.Command("id", "-u")
+		cmd := exec.CommandContext(ctx, "id", "-u")
 		cmd.Stderr = os.Stderr
 		userIDBytes, err := cmd.Output()
 		if err != nil {
 			return errors.New("Couldn't get current user id:\n" + err.Error())
 		}
-		cmd = exec.Command("id", "-g")
+		cmd = exec.CommandContext(ctx, "id", "-g")
 		cmd.Stderr = os.Stderr
 		groupIDBytes, err := cmd.Output()
 		if err != nil {
@@ -120,7 +172,7 @@ func StartMinikubeCluster(useVM bool) error {
 		if !exists {
 			return errors.New("Couldn't find home directory (no HOME env var)")
 		}
-		cmd = exec.Command("sudo", "chown", "-R", userIDString+":"+groupIDString, filepath.Join(home, ".kube"), filepath.Join(home, ".minikube"))
+		cmd = exec.CommandContext(ctx, "sudo", "chown", "-R", userIDString+":"+groupIDString, filepath.Join(home, ".kube"), filepath.Join(home, ".minikube"))
 		cmd.Stderr = os.Stderr
 		cmd.Stdin = os.Stdin
 		if err := cmd.Run(); err != nil {
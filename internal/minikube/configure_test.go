@@ -0,0 +1,47 @@
+package minikube
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/dragonchain/dragonchain-installer/internal/configuration"
+)
+
+func TestClusterCreatedUsesMinikubeHome(t *testing.T) {
+	folder, err := ioutil.TempDir("", "minikube")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(folder)
+	previous, existed := os.LookupEnv("MINIKUBE_HOME")
+	defer func() {
+		if existed {
+			os.Setenv("MINIKUBE_HOME", previous)
+		} else {
+			os.Unsetenv("MINIKUBE_HOME")
+		}
+	}()
+	os.Setenv("MINIKUBE_HOME", folder)
+
+	if home, err := Home(); err != nil || home != folder {
+		t.Errorf("Expected MINIKUBE_HOME %s, got %s (%v)", folder, home, err)
+	}
+	if ClusterCreated(true) {
+		t.Error("Expected no cluster without a profile")
+	}
+	profile := filepath.Join(folder, "profiles", configuration.MinikubeContext)
+	if err := os.MkdirAll(profile, 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(profile, "config.json"), []byte("{}"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if !ClusterCreated(true) {
+		t.Error("Expected the cluster with a profile in MINIKUBE_HOME to be created")
+	}
+	if ClusterCreated(false) {
+		t.Error("Expected no native docker cluster without its profile")
+	}
+}
@@ -1,6 +1,7 @@
 package minikube
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"io/ioutil"
@@ -13,13 +14,13 @@ import (
 	"github.com/dragonchain/dragonchain-installer/internal/downloader"
 )
 
-func minikubeIsInstalled() bool {
-	return exec.Command("minikube").Run() == nil
+func minikubeIsInstalled(ctx context.Context) bool {
+	return exec.CommandContext(ctx, "minikube").Run() == nil
 }
 
 // InstallMinikubeIfNecessary checks if minikube is already installed, and installs it if necessary
-func InstallMinikubeIfNecessary() error {
-	if minikubeIsInstalled() {
+func InstallMinikubeIfNecessary(ctx context.Context) error {
+	if minikubeIsInstalled(ctx) {
 		fmt.Println("minikube appears to already be installed")
 		return nil
 	}
@@ -29,7 +30,7 @@ func InstallMinikubeIfNecessary() error {
 		if !exists {
 			return errors.New("Environment variable 'SYSTEMROOT' does not exist")
 		}
-		if err := downloader.DownloadFile(filepath.Join(systemRoot, "minikube.exe"), configuration.WindowsMinikubeLink); err != nil {
+		if err := downloader.DownloadFile(ctx, filepath.Join(systemRoot, "minikube.exe"), configuration.WindowsMinikubeLink); err != nil {
 			return err
 		}
 	} else if configuration.Macos || configuration.Linux {
@@ -47,7 +48,7 @@ func InstallMinikubeIfNecessary() error {
 		} else if configuration.Macos {
 			downloadLink = configuration.MacosMinikubeLink
 		}
-		if err := downloader.DownloadFile(tempPath, downloadLink); err != nil {
+		if err := downloader.DownloadFile(ctx, tempPath, downloadLink); err != nil {
 			return err
 		}
 		if err := os.Chmod(tempPath, allowExecute); err != nil {
@@ -60,7 +61,7 @@ func InstallMinikubeIfNecessary() error {
 			}
 		} else {
 			// Move minikube executable into /usr/local/bin (require sudo on linux)
-			cmd := exec.Command("sudo", "mv", tempPath, unixBinaryPath)
+			cmd := exec.CommandContext(ctx, "sudo", "mv", tempPath, unixBinaryPath)
 			cmd.Stderr = os.Stderr
 			cmd.Stdout = os.Stdout
 			cmd.Stdin = os.Stdin
@@ -72,7 +73,7 @@ func InstallMinikubeIfNecessary() error {
 		log.Fatal("Unsupported operating system")
 	}
 	// Should be installed at this point; if not, something is wrong
-	if !minikubeIsInstalled() {
+	if !minikubeIsInstalled(ctx) {
 		return errors.New("Minikube failed to install")
 	}
 	return nil
@@ -0,0 +1,60 @@
+package minikube
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	"github.com/dragonchain/dragonchain-installer/internal/configuration"
+)
+
+// StopMinikubeCluster stops the minikube VM of the configured profile
+func StopMinikubeCluster(ctx context.Context) error {
+	fmt.Println("\nStopping minikube cluster '" + configuration.MinikubeContext + "'")
+	cmd := exec.CommandContext(ctx, "minikube", "stop", "-p", configuration.MinikubeContext)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return errors.New("Failed to stop minikube:\n" + err.Error())
+	}
+	return nil
+}
+
+// SetProfileResources records the memory (in megabytes) and cpus of the VM in the minikube profile, so that minikube reports
+// (and recreates the VM with) the same size that the VM was changed to
+func SetProfileResources(memoryMB int, cpus int) error {
+	home, err := Home()
+	if err != nil {
+		return err
+	}
+	profileFile := filepath.Join(home, "profiles", configuration.MinikubeContext, "config.json")
+	contents, err := ioutil.ReadFile(profileFile)
+	if err != nil {
+		return errors.New("Error reading minikube profile " + profileFile + ":\n" + err.Error())
+	}
+	// Keep the rest of the profile as it is
+	var profile map[string]interface{}
+	if err := json.Unmarshal(contents, &profile); err != nil {
+		return errors.New("Error parsing minikube profile " + profileFile + ":\n" + err.Error())
+	}
+	machineConfig := profile
+	if nested, ok := profile["MachineConfig"].(map[string]interface{}); ok {
+		// Older versions of minikube keep the VM settings in a nested object
+		machineConfig = nested
+	}
+	machineConfig["Memory"] = memoryMB
+	machineConfig["CPUs"] = cpus
+	contents, err = json.MarshalIndent(profile, "", "    ")
+	if err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(profileFile, contents, 0644); err != nil {
+		return errors.New("Error writing minikube profile " + profileFile + ":\n" + err.Error())
+	}
+	return nil
+}
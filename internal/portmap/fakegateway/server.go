@@ -0,0 +1,206 @@
+// Package fakegateway is a local stand-in for a router's NAT-PMP or PCP server, for exercising the portmap mechanisms
+// (and the fallback between them) without a real router.
+// Set portmap.NATPMP.Gateway or portmap.PCP.Gateway to Server.Addr to use it
+package fakegateway
+
+import (
+	"encoding/binary"
+	"net"
+	"sync"
+)
+
+// Protocols a fake gateway can speak
+const (
+	NATPMP = "natpmp"
+	PCP    = "pcp"
+)
+
+// Mapping is a port mapping created on the fake gateway
+type Mapping struct {
+	Protocol     string
+	InternalIP   net.IP
+	InternalPort int
+	ExternalPort int
+	Lifetime     uint32
+}
+
+// Server emulates a NAT-PMP or PCP server. Requests for the other protocol are answered with "unsupported version", like real servers do
+type Server struct {
+	// Addr is the host:port the server listens on
+	Addr string
+
+	protocol   string
+	conn       net.PacketConn
+	lock       sync.Mutex
+	resultCode int
+	silent     bool
+	reassigned map[int]int
+	mappings   []Mapping
+	requests   int
+}
+
+// NewServer starts a fake gateway speaking protocol (NATPMP or PCP) on a random local udp port
+func NewServer(protocol string) (*Server, error) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	fake := &Server{
+		Addr:       conn.LocalAddr().String(),
+		protocol:   protocol,
+		conn:       conn,
+		reassigned: map[int]int{},
+	}
+	go fake.serve()
+	return fake, nil
+}
+
+// Close shuts down the server
+func (fake *Server) Close() {
+	fake.conn.Close()
+}
+
+// SetResultCode makes mapping requests fail with a protocol result code (0 to succeed again)
+func (fake *Server) SetResultCode(code int) {
+	fake.lock.Lock()
+	defer fake.lock.Unlock()
+	fake.resultCode = code
+}
+
+// SetSilent makes the server ignore all requests, as if the gateway doesn't run the protocol but drops packets
+func (fake *Server) SetSilent(silent bool) {
+	fake.lock.Lock()
+	defer fake.lock.Unlock()
+	fake.silent = silent
+}
+
+// Reassign makes the server grant external port instead of port when asked to map port, as if port were already mapped elsewhere
+func (fake *Server) Reassign(port int, external int) {
+	fake.lock.Lock()
+	defer fake.lock.Unlock()
+	fake.reassigned[port] = external
+}
+
+// Mappings returns the mappings created so far
+func (fake *Server) Mappings() []Mapping {
+	fake.lock.Lock()
+	defer fake.lock.Unlock()
+	return append([]Mapping(nil), fake.mappings...)
+}
+
+// Requests returns the number of requests received, including ignored ones
+func (fake *Server) Requests() int {
+	fake.lock.Lock()
+	defer fake.lock.Unlock()
+	return fake.requests
+}
+
+func (fake *Server) serve() {
+	buf := make([]byte, 1100)
+	for {
+		n, addr, err := fake.conn.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		if response := fake.handle(buf[:n], addr); response != nil {
+			fake.conn.WriteTo(response, addr)
+		}
+	}
+}
+
+func (fake *Server) handle(request []byte, addr net.Addr) []byte {
+	fake.lock.Lock()
+	defer fake.lock.Unlock()
+	fake.requests++
+	if fake.silent || len(request) < 2 {
+		return nil
+	}
+	version := request[0]
+	if fake.protocol == NATPMP {
+		if version != 0 {
+			return natpmpResponse(request[1], 1)
+		}
+		return fake.natpmpMap(request, addr)
+	}
+	if version != 2 {
+		return pcpResponse(request[1], 1, 0)
+	}
+	return fake.pcpMap(request)
+}
+
+func (fake *Server) externalPort(port int) int {
+	if external, ok := fake.reassigned[port]; ok {
+		return external
+	}
+	return port
+}
+
+func natpmpResponse(opcode byte, result uint16) []byte {
+	response := make([]byte, 16)
+	response[1] = 128 + opcode
+	binary.BigEndian.PutUint16(response[2:4], result)
+	return response
+}
+
+func (fake *Server) natpmpMap(request []byte, addr net.Addr) []byte {
+	opcode := request[1]
+	if opcode != 1 && opcode != 2 {
+		return natpmpResponse(opcode, 5)
+	}
+	if len(request) < 12 || fake.resultCode != 0 {
+		return natpmpResponse(opcode, uint16(fake.resultCode))
+	}
+	internal := int(binary.BigEndian.Uint16(request[4:6]))
+	mapping := Mapping{
+		Protocol:     NATPMP,
+		InternalIP:   addr.(*net.UDPAddr).IP,
+		InternalPort: internal,
+		ExternalPort: fake.externalPort(internal),
+		Lifetime:     binary.BigEndian.Uint32(request[8:12]),
+	}
+	fake.mappings = append(fake.mappings, mapping)
+	response := natpmpResponse(opcode, 0)
+	binary.BigEndian.PutUint16(response[8:10], uint16(mapping.InternalPort))
+	binary.BigEndian.PutUint16(response[10:12], uint16(mapping.ExternalPort))
+	binary.BigEndian.PutUint32(response[12:16], mapping.Lifetime)
+	return response
+}
+
+func pcpResponse(opcode byte, result byte, lifetime uint32) []byte {
+	response := make([]byte, 60)
+	response[0] = 2
+	response[1] = 128 + opcode
+	response[3] = result
+	binary.BigEndian.PutUint32(response[4:8], lifetime)
+	return response
+}
+
+func (fake *Server) pcpMap(request []byte) []byte {
+	opcode := request[1]
+	if opcode != 1 {
+		return pcpResponse(opcode, 4, 0)
+	}
+	if len(request) < 60 {
+		return pcpResponse(opcode, 3, 0)
+	}
+	if fake.resultCode != 0 {
+		response := pcpResponse(opcode, byte(fake.resultCode), 0)
+		copy(response[24:], request[24:60])
+		return response
+	}
+	internal := int(binary.BigEndian.Uint16(request[40:42]))
+	mapping := Mapping{
+		Protocol:     PCP,
+		InternalIP:   net.IP(append([]byte(nil), request[8:24]...)),
+		InternalPort: internal,
+		ExternalPort: fake.externalPort(internal),
+		Lifetime:     binary.BigEndian.Uint32(request[4:8]),
+	}
+	fake.mappings = append(fake.mappings, mapping)
+	response := pcpResponse(opcode, 0, mapping.Lifetime)
+	// The MAP response echoes the request's nonce, protocol and internal port
+	copy(response[24:], request[24:60])
+	binary.BigEndian.PutUint16(response[42:44], uint16(mapping.ExternalPort))
+	copy(response[44:60], net.IPv4(127, 0, 0, 1).To16())
+	return response
+}
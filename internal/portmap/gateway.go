@@ -0,0 +1,90 @@
+package portmap
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"net"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// DefaultGateway finds the ipv4 address of the default gateway (the router)
+func DefaultGateway() (net.IP, error) {
+	var ip net.IP
+	var err error
+	switch runtime.GOOS {
+	case "linux":
+		ip, err = linuxDefaultGateway()
+	case "windows":
+		ip, err = windowsDefaultGateway()
+	default:
+		ip, err = bsdDefaultGateway()
+	}
+	if err != nil {
+		return nil, errors.New("Could not find default gateway:\n" + err.Error())
+	}
+	if ip == nil {
+		return nil, errors.New("Could not find default gateway")
+	}
+	return ip, nil
+}
+
+// /proc/net/route lists routes with hex encoded (little endian) addresses; the default route has destination 0
+func linuxDefaultGateway() (net.IP, error) {
+	file, err := os.Open("/proc/net/route")
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 3 || fields[1] != "00000000" {
+			continue
+		}
+		gateway, err := hex.DecodeString(fields[2])
+		if err != nil || len(gateway) != 4 {
+			continue
+		}
+		ip := make(net.IP, 4)
+		binary.BigEndian.PutUint32(ip, binary.LittleEndian.Uint32(gateway))
+		return ip, nil
+	}
+	return nil, scanner.Err()
+}
+
+// `route -n get default` (macos/bsd) prints a line like "gateway: 192.168.1.1"
+func bsdDefaultGateway() (net.IP, error) {
+	out, err := exec.Command("route", "-n", "get", "default").Output()
+	if err != nil {
+		return nil, err
+	}
+	for _, line := range strings.Split(string(out), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 2 && fields[0] == "gateway:" {
+			return net.ParseIP(fields[1]).To4(), nil
+		}
+	}
+	return nil, nil
+}
+
+// `route print` (windows) lists the default route as "0.0.0.0  0.0.0.0  <gateway>  <interface>  <metric>"
+func windowsDefaultGateway() (net.IP, error) {
+	out, err := exec.Command("route", "print", "0.0.0.0").Output()
+	if err != nil {
+		return nil, err
+	}
+	for _, line := range strings.Split(string(out), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) >= 3 && fields[0] == "0.0.0.0" && fields[1] == "0.0.0.0" {
+			if ip := net.ParseIP(fields[2]).To4(); ip != nil {
+				return ip, nil
+			}
+		}
+	}
+	return nil, nil
+}
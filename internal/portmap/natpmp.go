@@ -0,0 +1,72 @@
+package portmap
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"strconv"
+)
+
+// NAT-PMP (RFC 6886) constants needed for a TCP mapping request
+const (
+	natpmpVersion      = 0
+	natpmpOpMapTCP     = 2
+	natpmpResponseBit  = 128
+	natpmpRequestSize  = 12
+	natpmpResponseSize = 16
+)
+
+var natpmpResultCodes = map[uint16]string{
+	1: "unsupported version",
+	2: "not authorized/refused",
+	3: "network failure",
+	4: "out of resources",
+	5: "unsupported opcode",
+}
+
+// NATPMP maps ports with NAT-PMP (most Apple routers and some open source firewalls)
+type NATPMP struct {
+	// Address (host:port) of the NAT-PMP server; the default gateway if empty
+	Gateway string
+}
+
+// Name is "natpmp"
+func (natpmp *NATPMP) Name() string {
+	return "natpmp"
+}
+
+// AddPortMapping asks the NAT-PMP server to map the external TCP port to the same port on this machine
+func (natpmp *NATPMP) AddPortMapping(ctx context.Context, port int) error {
+	gateway, err := gatewayAddress(natpmp.Gateway)
+	if err != nil {
+		return err
+	}
+	request := make([]byte, natpmpRequestSize)
+	request[0] = natpmpVersion
+	request[1] = natpmpOpMapTCP
+	binary.BigEndian.PutUint16(request[4:6], uint16(port))
+	binary.BigEndian.PutUint16(request[6:8], uint16(port))
+	binary.BigEndian.PutUint32(request[8:12], lifetimeSeconds())
+	return exchange(ctx, gateway, request, func(response []byte) (bool, error) {
+		if len(response) < 4 || response[1] != natpmpResponseBit+natpmpOpMapTCP {
+			return false, nil
+		}
+		if result := binary.BigEndian.Uint16(response[2:4]); result != 0 {
+			return true, errors.New("NAT-PMP server refused mapping: " + resultDescription(natpmpResultCodes, result))
+		}
+		if len(response) < natpmpResponseSize || int(binary.BigEndian.Uint16(response[8:10])) != port {
+			return false, nil
+		}
+		if external := int(binary.BigEndian.Uint16(response[10:12])); external != port {
+			return true, errors.New("NAT-PMP server mapped external port " + strconv.Itoa(external) + " instead of " + strconv.Itoa(port) + " (is the port already mapped to another machine?)")
+		}
+		return true, nil
+	})
+}
+
+func resultDescription(codes map[uint16]string, result uint16) string {
+	if description, ok := codes[result]; ok {
+		return description
+	}
+	return "result code " + strconv.Itoa(int(result))
+}
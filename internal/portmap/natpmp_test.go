@@ -0,0 +1,81 @@
+package portmap
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dragonchain/dragonchain-installer/internal/portmap/fakegateway"
+)
+
+func newGateway(t *testing.T, protocol string) *fakegateway.Server {
+	gateway, err := fakegateway.NewServer(protocol)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return gateway
+}
+
+func TestNATPMPMapping(t *testing.T) {
+	gateway := newGateway(t, fakegateway.NATPMP)
+	defer gateway.Close()
+
+	mapper := &NATPMP{Gateway: gateway.Addr}
+	if err := mapper.AddPortMapping(context.Background(), 30000); err != nil {
+		t.Fatal(err)
+	}
+	mappings := gateway.Mappings()
+	if len(mappings) != 1 {
+		t.Fatalf("Expected 1 mapping, got %+v", mappings)
+	}
+	mapping := mappings[0]
+	if mapping.InternalPort != 30000 || mapping.ExternalPort != 30000 || !mapping.InternalIP.Equal(net.IPv4(127, 0, 0, 1)) {
+		t.Errorf("Unexpected mapping %+v", mapping)
+	}
+	if mapping.Lifetime != uint32(Lifetime/time.Second) {
+		t.Errorf("Expected a lifetime of %d seconds, got %d", uint32(Lifetime/time.Second), mapping.Lifetime)
+	}
+}
+
+func TestNATPMPRefused(t *testing.T) {
+	cases := map[int]string{
+		2:  "not authorized/refused",
+		4:  "out of resources",
+		99: "result code 99",
+	}
+	for code, description := range cases {
+		gateway := newGateway(t, fakegateway.NATPMP)
+		gateway.SetResultCode(code)
+		err := (&NATPMP{Gateway: gateway.Addr}).AddPortMapping(context.Background(), 30000)
+		gateway.Close()
+		if err == nil || !strings.Contains(err.Error(), "refused mapping: "+description) {
+			t.Errorf("Expected result code %d to be reported as %q, got %v", code, description, err)
+		}
+	}
+}
+
+func TestNATPMPReassignedPort(t *testing.T) {
+	gateway := newGateway(t, fakegateway.NATPMP)
+	defer gateway.Close()
+	gateway.Reassign(30000, 30001)
+
+	err := (&NATPMP{Gateway: gateway.Addr}).AddPortMapping(context.Background(), 30000)
+	if err == nil || !strings.Contains(err.Error(), "mapped external port 30001 instead of 30000") {
+		t.Errorf("Expected the reassigned port to be an error, got %v", err)
+	}
+}
+
+func TestNATPMPToPCPServer(t *testing.T) {
+	gateway := newGateway(t, fakegateway.PCP)
+	defer gateway.Close()
+
+	err := (&NATPMP{Gateway: gateway.Addr}).AddPortMapping(context.Background(), 30000)
+	if err == nil || !strings.Contains(err.Error(), "unsupported version") {
+		t.Errorf("Expected an unsupported version error, got %v", err)
+	}
+	if gateway.Requests() != 1 {
+		t.Errorf("Expected no retries after an answer, got %d requests", gateway.Requests())
+	}
+}
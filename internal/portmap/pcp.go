@@ -0,0 +1,95 @@
+package portmap
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"net"
+	"strconv"
+)
+
+// PCP (RFC 6887) constants needed for a MAP request
+const (
+	pcpVersion       = 2
+	pcpOpMap         = 1
+	pcpResponseBit   = 128
+	pcpProtocolTCP   = 6
+	pcpHeaderSize    = 24
+	pcpMapSize       = 36
+	pcpNonceSize     = 12
+	pcpResultSuccess = 0
+)
+
+var pcpResultCodes = map[uint16]string{
+	1:  "unsupported version",
+	2:  "not authorized",
+	3:  "malformed request",
+	4:  "unsupported opcode",
+	5:  "unsupported option",
+	6:  "malformed option",
+	7:  "network failure",
+	8:  "no resources",
+	9:  "unsupported protocol",
+	10: "user exceeded quota",
+	11: "cannot provide external port",
+	12: "address mismatch (is this machine behind another NAT?)",
+	13: "excessive remote peers",
+}
+
+// PCP maps ports with the Port Control Protocol (the successor of NAT-PMP)
+type PCP struct {
+	// Address (host:port) of the PCP server; the default gateway if empty
+	Gateway string
+}
+
+// Name is "pcp"
+func (pcp *PCP) Name() string {
+	return "pcp"
+}
+
+// AddPortMapping asks the PCP server to map the external TCP port to the same port on this machine
+func (pcp *PCP) AddPortMapping(ctx context.Context, port int) error {
+	gateway, err := gatewayAddress(pcp.Gateway)
+	if err != nil {
+		return err
+	}
+	clientIP, err := localAddress(ctx, gateway)
+	if err != nil {
+		return err
+	}
+	request := make([]byte, pcpHeaderSize+pcpMapSize)
+	request[0] = pcpVersion
+	request[1] = pcpOpMap
+	binary.BigEndian.PutUint32(request[4:8], lifetimeSeconds())
+	copy(request[8:24], clientIP.To16())
+	nonce := request[24 : 24+pcpNonceSize]
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+	request[36] = pcpProtocolTCP
+	binary.BigEndian.PutUint16(request[40:42], uint16(port))
+	binary.BigEndian.PutUint16(request[42:44], uint16(port))
+	// Suggested external address; the ipv4-mapped unspecified address lets the server pick its external ipv4 address
+	copy(request[44:60], net.IPv4zero.To16())
+	return exchange(ctx, gateway, request, func(response []byte) (bool, error) {
+		if len(response) >= 4 && response[0] != pcpVersion {
+			// A NAT-PMP only server answers with its own version and an "unsupported version" result
+			return true, errors.New("Gateway does not support PCP (version " + strconv.Itoa(int(response[0])) + " response)")
+		}
+		if len(response) < pcpHeaderSize || response[1] != pcpResponseBit+pcpOpMap {
+			return false, nil
+		}
+		if result := uint16(response[3]); result != pcpResultSuccess {
+			return true, errors.New("PCP server refused mapping: " + resultDescription(pcpResultCodes, result))
+		}
+		if len(response) < pcpHeaderSize+pcpMapSize || !bytes.Equal(response[24:24+pcpNonceSize], nonce) {
+			return false, nil
+		}
+		if external := int(binary.BigEndian.Uint16(response[42:44])); external != port {
+			return true, errors.New("PCP server mapped external port " + strconv.Itoa(external) + " instead of " + strconv.Itoa(port) + " (is the port already mapped to another machine?)")
+		}
+		return true, nil
+	})
+}
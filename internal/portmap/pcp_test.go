@@ -0,0 +1,98 @@
+package portmap
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dragonchain/dragonchain-installer/internal/portmap/fakegateway"
+)
+
+func TestPCPMapping(t *testing.T) {
+	gateway := newGateway(t, fakegateway.PCP)
+	defer gateway.Close()
+
+	mapper := &PCP{Gateway: gateway.Addr}
+	if err := mapper.AddPortMapping(context.Background(), 30000); err != nil {
+		t.Fatal(err)
+	}
+	mappings := gateway.Mappings()
+	if len(mappings) != 1 {
+		t.Fatalf("Expected 1 mapping, got %+v", mappings)
+	}
+	mapping := mappings[0]
+	if mapping.InternalPort != 30000 || mapping.ExternalPort != 30000 {
+		t.Errorf("Unexpected mapping %+v", mapping)
+	}
+	// PCP carries the client's address, as an ipv4-mapped ipv6 address
+	if len(mapping.InternalIP) != net.IPv6len || !mapping.InternalIP.Equal(net.IPv4(127, 0, 0, 1)) {
+		t.Errorf("Expected client address ::ffff:127.0.0.1, got %s", mapping.InternalIP)
+	}
+	if mapping.Lifetime != uint32(Lifetime/time.Second) {
+		t.Errorf("Expected a lifetime of %d seconds, got %d", uint32(Lifetime/time.Second), mapping.Lifetime)
+	}
+}
+
+func TestPCPRefused(t *testing.T) {
+	cases := map[int]string{
+		2:  "not authorized",
+		11: "cannot provide external port",
+		12: "address mismatch",
+		99: "result code 99",
+	}
+	for code, description := range cases {
+		gateway := newGateway(t, fakegateway.PCP)
+		gateway.SetResultCode(code)
+		err := (&PCP{Gateway: gateway.Addr}).AddPortMapping(context.Background(), 30000)
+		gateway.Close()
+		if err == nil || !strings.Contains(err.Error(), "refused mapping: "+description) {
+			t.Errorf("Expected result code %d to be reported as %q, got %v", code, description, err)
+		}
+	}
+}
+
+func TestPCPReassignedPort(t *testing.T) {
+	gateway := newGateway(t, fakegateway.PCP)
+	defer gateway.Close()
+	gateway.Reassign(30000, 30001)
+
+	err := (&PCP{Gateway: gateway.Addr}).AddPortMapping(context.Background(), 30000)
+	if err == nil || !strings.Contains(err.Error(), "mapped external port 30001 instead of 30000") {
+		t.Errorf("Expected the reassigned port to be an error, got %v", err)
+	}
+}
+
+func TestPCPToNATPMPServer(t *testing.T) {
+	gateway := newGateway(t, fakegateway.NATPMP)
+	defer gateway.Close()
+
+	err := (&PCP{Gateway: gateway.Addr}).AddPortMapping(context.Background(), 30000)
+	if err == nil || !strings.Contains(err.Error(), "does not support PCP") {
+		t.Errorf("Expected PCP to be reported as unsupported, got %v", err)
+	}
+	if gateway.Requests() != 1 {
+		t.Errorf("Expected no retries after an answer, got %d requests", gateway.Requests())
+	}
+}
+
+func TestForwardFallsBackToPCP(t *testing.T) {
+	natpmp := newGateway(t, fakegateway.PCP)
+	defer natpmp.Close()
+	pcp := newGateway(t, fakegateway.PCP)
+	defer pcp.Close()
+
+	// NAT-PMP is preferred, but its gateway only speaks PCP
+	mappers := []Mapper{&NATPMP{Gateway: natpmp.Addr}, &PCP{Gateway: pcp.Addr}}
+	name, err := Forward(context.Background(), 30000, "natpmp", mappers)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name != "pcp" {
+		t.Errorf("Expected pcp to work, got %s", name)
+	}
+	if natpmp.Requests() != 1 || len(pcp.Mappings()) != 1 {
+		t.Errorf("Expected one NAT-PMP attempt and one PCP mapping, got %d requests and %+v", natpmp.Requests(), pcp.Mappings())
+	}
+}
@@ -0,0 +1,131 @@
+// Package portmap forwards a port on the router to this machine, using whichever of UPnP, NAT-PMP or PCP the router supports
+package portmap
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strconv"
+	"time"
+)
+
+// Lifetime requested for NAT-PMP and PCP mappings (routers may grant less). Both protocols require a finite lifetime;
+// mappings are recreated by the installer/watch command whenever the chain stops being reachable
+var Lifetime = 7 * 24 * time.Hour
+
+// Port that NAT-PMP and PCP servers listen on at the gateway
+const gatewayPort = 5351
+
+// Mapper creates a TCP port mapping on the router, with the same external and internal port
+type Mapper interface {
+	// Name of the mechanism, as recorded in the installation config
+	Name() string
+	AddPortMapping(ctx context.Context, port int) error
+}
+
+// Mappers returns the supported mechanisms in the order they should be tried
+func Mappers() []Mapper {
+	return []Mapper{&UPnP{}, &NATPMP{}, &PCP{}}
+}
+
+// Forward tries each mapper in turn until one of them creates a mapping for port, and returns the name of the mechanism that worked.
+// If preferred is the name of a mechanism (i.e. one which worked before), it is tried first
+func Forward(ctx context.Context, port int, preferred string, mappers []Mapper) (string, error) {
+	ordered := make([]Mapper, 0, len(mappers))
+	for _, mapper := range mappers {
+		if mapper.Name() == preferred {
+			ordered = append(ordered, mapper)
+		}
+	}
+	for _, mapper := range mappers {
+		if mapper.Name() != preferred {
+			ordered = append(ordered, mapper)
+		}
+	}
+	failures := ""
+	for _, mapper := range ordered {
+		err := mapper.AddPortMapping(ctx, port)
+		if err == nil {
+			return mapper.Name(), nil
+		}
+		if ctx.Err() != nil {
+			return "", ctx.Err()
+		}
+		failures += "\n" + mapper.Name() + ": " + err.Error()
+	}
+	return "", errors.New("Router did not accept a port forward with any mechanism:" + failures)
+}
+
+// Get the address of a NAT-PMP/PCP server, which is the default gateway unless overridden
+func gatewayAddress(gateway string) (string, error) {
+	if gateway != "" {
+		return gateway, nil
+	}
+	ip, err := DefaultGateway()
+	if err != nil {
+		return "", err
+	}
+	return net.JoinHostPort(ip.String(), strconv.Itoa(gatewayPort)), nil
+}
+
+// Send a request to a NAT-PMP/PCP server, retrying with a doubling timeout (as both protocols specify) until handle accepts a response.
+// handle returns false for responses which should be ignored (i.e. not for this request)
+func exchange(ctx context.Context, gateway string, request []byte, handle func(response []byte) (bool, error)) error {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "udp", gateway)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	timeout := 250 * time.Millisecond
+	response := make([]byte, 1100)
+	for attempt := 0; attempt < 4; attempt++ {
+		if _, err := conn.Write(request); err != nil {
+			return err
+		}
+		deadline := time.Now().Add(timeout)
+		ctxDeadline, hasDeadline := ctx.Deadline()
+		if hasDeadline && ctxDeadline.Before(deadline) {
+			deadline = ctxDeadline
+		}
+		conn.SetReadDeadline(deadline)
+		for {
+			n, err := conn.Read(response)
+			if err != nil {
+				if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+					break
+				}
+				// i.e. ICMP port unreachable when nothing is listening on the gateway
+				return err
+			}
+			done, err := handle(response[:n])
+			if err != nil || done {
+				return err
+			}
+		}
+		if hasDeadline && !time.Now().Before(ctxDeadline) {
+			// The read timed out at ctx's deadline, which ctx may not have noticed yet
+			<-ctx.Done()
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		timeout *= 2
+	}
+	return errors.New("No response from " + gateway)
+}
+
+// Get the local address used to reach the gateway, which is the internal address for the mapping
+func localAddress(ctx context.Context, gateway string) (net.IP, error) {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "udp", gateway)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	return conn.LocalAddr().(*net.UDPAddr).IP, nil
+}
+
+func lifetimeSeconds() uint32 {
+	return uint32(Lifetime / time.Second)
+}
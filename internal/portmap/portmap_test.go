@@ -0,0 +1,200 @@
+package portmap
+
+import (
+	"context"
+	"errors"
+	"net"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type fakeMapper struct {
+	name  string
+	err   error
+	calls *[]string
+}
+
+func (mapper *fakeMapper) Name() string {
+	return mapper.name
+}
+
+func (mapper *fakeMapper) AddPortMapping(ctx context.Context, port int) error {
+	*mapper.calls = append(*mapper.calls, mapper.name)
+	return mapper.err
+}
+
+func TestForwardOrder(t *testing.T) {
+	refused := errors.New("refused")
+	cases := []struct {
+		name      string
+		preferred string
+		failing   map[string]bool
+		calls     []string
+		result    string
+	}{
+		{"first works", "", nil, []string{"upnp"}, "upnp"},
+		{"falls back", "", map[string]bool{"upnp": true}, []string{"upnp", "natpmp"}, "natpmp"},
+		{"preferred first", "pcp", nil, []string{"pcp"}, "pcp"},
+		{"preferred fails", "pcp", map[string]bool{"pcp": true}, []string{"pcp", "upnp"}, "upnp"},
+		{"unknown preferred", "other", map[string]bool{"upnp": true, "natpmp": true}, []string{"upnp", "natpmp", "pcp"}, "pcp"},
+		{"all fail", "natpmp", map[string]bool{"upnp": true, "natpmp": true, "pcp": true}, []string{"natpmp", "upnp", "pcp"}, ""},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var calls []string
+			var mappers []Mapper
+			for _, name := range []string{"upnp", "natpmp", "pcp"} {
+				mapper := &fakeMapper{name: name, calls: &calls}
+				if c.failing[name] {
+					mapper.err = refused
+				}
+				mappers = append(mappers, mapper)
+			}
+			result, err := Forward(context.Background(), 30000, c.preferred, mappers)
+			if !reflect.DeepEqual(calls, c.calls) {
+				t.Errorf("Expected mappers to be tried in order %v, got %v", c.calls, calls)
+			}
+			if result != c.result {
+				t.Errorf("Expected %q to work, got %q", c.result, result)
+			}
+			if c.result == "" {
+				if err == nil || !strings.Contains(err.Error(), "pcp: refused") {
+					t.Errorf("Expected an error listing each failure, got %v", err)
+				}
+			} else if err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestForwardStopsWhenCancelled(t *testing.T) {
+	var calls []string
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	mappers := []Mapper{&fakeMapper{name: "upnp", err: errors.New("refused"), calls: &calls}, &fakeMapper{name: "natpmp", calls: &calls}}
+	if _, err := Forward(ctx, 30000, "", mappers); err != context.Canceled {
+		t.Errorf("Expected the context error, got %v", err)
+	}
+	if len(calls) != 1 {
+		t.Errorf("Expected no more mappers to be tried after cancellation, got %v", calls)
+	}
+}
+
+// A udp server which answers each request with respond, and counts requests
+type udpResponder struct {
+	conn     net.PacketConn
+	lock     sync.Mutex
+	requests int
+}
+
+func newUDPResponder(t *testing.T, respond func(request int) [][]byte) *udpResponder {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	responder := &udpResponder{conn: conn}
+	go func() {
+		buf := make([]byte, 1100)
+		for {
+			_, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			responder.lock.Lock()
+			responder.requests++
+			request := responder.requests
+			responder.lock.Unlock()
+			for _, response := range respond(request) {
+				conn.WriteTo(response, addr)
+			}
+		}
+	}()
+	return responder
+}
+
+func (responder *udpResponder) count() int {
+	responder.lock.Lock()
+	defer responder.lock.Unlock()
+	return responder.requests
+}
+
+func TestExchangeRetries(t *testing.T) {
+	// Drop the first two requests, then send an unrelated response before the real one
+	responder := newUDPResponder(t, func(request int) [][]byte {
+		if request < 3 {
+			return nil
+		}
+		return [][]byte{[]byte("ignored"), []byte("answer")}
+	})
+	defer responder.conn.Close()
+
+	var handled []string
+	err := exchange(context.Background(), responder.conn.LocalAddr().String(), []byte("request"), func(response []byte) (bool, error) {
+		handled = append(handled, string(response))
+		return string(response) == "answer", nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if responder.count() != 3 {
+		t.Errorf("Expected 3 attempts, got %d", responder.count())
+	}
+	if !reflect.DeepEqual(handled, []string{"ignored", "answer"}) {
+		t.Errorf("Expected the unrelated response to be skipped, got %v", handled)
+	}
+}
+
+func TestExchangeHandlerError(t *testing.T) {
+	responder := newUDPResponder(t, func(request int) [][]byte {
+		return [][]byte{[]byte("refused")}
+	})
+	defer responder.conn.Close()
+
+	refused := errors.New("refused")
+	err := exchange(context.Background(), responder.conn.LocalAddr().String(), []byte("request"), func(response []byte) (bool, error) {
+		return true, refused
+	})
+	if err != refused {
+		t.Errorf("Expected the handler's error, got %v", err)
+	}
+	if responder.count() != 1 {
+		t.Errorf("Expected no retries after an answer, got %d attempts", responder.count())
+	}
+}
+
+func TestExchangeTimeout(t *testing.T) {
+	responder := newUDPResponder(t, func(request int) [][]byte {
+		return nil
+	})
+	defer responder.conn.Close()
+	handle := func(response []byte) (bool, error) {
+		return true, nil
+	}
+
+	start := time.Now()
+	err := exchange(context.Background(), responder.conn.LocalAddr().String(), []byte("request"), handle)
+	if err == nil || !strings.Contains(err.Error(), "No response") {
+		t.Errorf("Expected no response, got %v", err)
+	}
+	// 250ms + 500ms + 1s + 2s
+	if elapsed := time.Since(start); elapsed < 3700*time.Millisecond || elapsed > 5*time.Second {
+		t.Errorf("Expected to give up after about 3.75s, took %s", elapsed)
+	}
+	if responder.count() != 4 {
+		t.Errorf("Expected 4 attempts, got %d", responder.count())
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 400*time.Millisecond)
+	defer cancel()
+	start = time.Now()
+	if err := exchange(ctx, responder.conn.LocalAddr().String(), []byte("request"), handle); err != context.DeadlineExceeded {
+		t.Errorf("Expected the context deadline to be exceeded, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Expected to stop at the context deadline, took %s", elapsed)
+	}
+}
@@ -0,0 +1,72 @@
+package portmap
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"strconv"
+	"time"
+)
+
+// How long to wait for a connection to the chain, or when checking whether something accepts ipv6 connections
+const relayDialTimeout = 5 * time.Second
+
+// AcceptsIPv6 checks whether anything on this machine accepts ipv6 connections on the TCP port
+func AcceptsIPv6(port int) bool {
+	conn, err := net.DialTimeout("tcp6", net.JoinHostPort("::1", strconv.Itoa(port)), relayDialTimeout)
+	if err != nil {
+		return false
+	}
+	conn.Close()
+	return true
+}
+
+// RelayIPv6 accepts ipv6 connections on the TCP port and relays them to target (the chain's ipv4 node port, as host:port)
+// in the background, until ctx is done. The node port on the cluster (and virtualbox's port forward to it) only accept
+// ipv4, so without this nothing would receive connections arriving through an ipv6 pinhole.
+// Does nothing if something already accepts ipv6 connections on the port (i.e. a relay started earlier)
+func RelayIPv6(ctx context.Context, port int, target string) error {
+	if AcceptsIPv6(port) {
+		return nil
+	}
+	// tcp6 only listens on ipv6, so it doesn't conflict with the ipv4 port forward to the chain on the same port
+	listener, err := net.Listen("tcp6", net.JoinHostPort("::", strconv.Itoa(port)))
+	if err != nil {
+		return errors.New("Couldn't listen for ipv6 connections on port " + strconv.Itoa(port) + ":\n" + err.Error())
+	}
+	go func() {
+		<-ctx.Done()
+		listener.Close()
+	}()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go relay(conn, target)
+		}
+	}()
+	return nil
+}
+
+// Copy data both ways between conn and target until either side closes its connection
+func relay(conn net.Conn, target string) {
+	defer conn.Close()
+	upstream, err := net.DialTimeout("tcp", target, relayDialTimeout)
+	if err != nil {
+		return
+	}
+	defer upstream.Close()
+	done := make(chan struct{}, 2)
+	go func() {
+		io.Copy(upstream, conn)
+		done <- struct{}{}
+	}()
+	go func() {
+		io.Copy(conn, upstream)
+		done <- struct{}{}
+	}()
+	<-done
+}
@@ -0,0 +1,116 @@
+package portmap
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dragonchain/dragonchain-installer/internal/upnp"
+	"github.com/dragonchain/dragonchain-installer/internal/upnp/fakegateway"
+)
+
+// Start a stand-in for the chain, which (like its node port) only accepts ipv4 connections
+func newIPv4Chain(t *testing.T) *httptest.Server {
+	chain := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "chain "+r.URL.Path)
+	}))
+	if !strings.HasPrefix(chain.URL, "http://127.0.0.1:") {
+		chain.Close()
+		t.Skip("Test server isn't listening on ipv4")
+	}
+	return chain
+}
+
+// Find a TCP port which is free for ipv6, skipping the test if this machine has no ipv6 loopback
+func freeIPv6Port(t *testing.T) int {
+	listener, err := net.Listen("tcp6", "[::1]:0")
+	if err != nil {
+		t.Skip("ipv6 isn't available: " + err.Error())
+	}
+	defer listener.Close()
+	return listener.Addr().(*net.TCPAddr).Port
+}
+
+// Use a fake upnp gateway with an ipv6 firewall that allows pinholes, until the returned function is called
+func usePinholeGateway(t *testing.T) (*fakegateway.Server, func()) {
+	gateway, err := fakegateway.NewServer(fakegateway.Options{Version: 2, NATEnabled: true, IPv6Firewall: true, FirewallEnabled: true, InboundPinholeAllowed: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	previous := upnp.DefaultTransport
+	upnp.DefaultTransport = gateway.Transport()
+	return gateway, func() {
+		upnp.DefaultTransport = previous
+		gateway.Close()
+	}
+}
+
+func TestPinholeReachesChain(t *testing.T) {
+	chain := newIPv4Chain(t)
+	defer chain.Close()
+	port := freeIPv6Port(t)
+	gateway, done := usePinholeGateway(t)
+	defer done()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	if err := RelayIPv6(ctx, port, strings.TrimPrefix(chain.URL, "http://")); err != nil {
+		t.Fatal(err)
+	}
+	// Starting it again (i.e. when the watchdog repairs the forward) leaves the running relay alone
+	if err := RelayIPv6(ctx, port, strings.TrimPrefix(chain.URL, "http://")); err != nil {
+		t.Fatal(err)
+	}
+	mechanism, err := Forward(ctx, port, "", []Mapper{&UPnPIPv6Pinhole{Address: "2001:db8::2"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mechanism != "upnp-pinhole" {
+		t.Errorf("Expected upnp-pinhole, got %s", mechanism)
+	}
+	pinholes := gateway.Pinholes()
+	if len(pinholes) != 1 || pinholes[0].InternalClient != "2001:db8::2" || pinholes[0].InternalPort != port {
+		t.Errorf("Expected a pinhole to port %d, got %+v", port, pinholes)
+	}
+
+	// Connections through the pinhole arrive at this machine's ipv6 address on the port, and reach the ipv4-only chain
+	resp, err := http.Get("http://[::1]:" + strconv.Itoa(port) + "/health")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(body) != "chain /health" {
+		t.Errorf("Expected the chain's response, got %q", body)
+	}
+
+	cancel()
+	deadline := time.Now().Add(time.Second)
+	for AcceptsIPv6(port) {
+		if time.Now().After(deadline) {
+			t.Fatal("Expected the relay to stop with its context")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestPinholeWithoutIPv6Listener(t *testing.T) {
+	port := freeIPv6Port(t)
+	gateway, done := usePinholeGateway(t)
+	defer done()
+
+	err := (&UPnPIPv6Pinhole{Address: "2001:db8::2"}).AddPortMapping(context.Background(), port)
+	if err == nil || !strings.Contains(err.Error(), "Nothing on this machine accepts ipv6 connections") {
+		t.Errorf("Expected the pinhole to be refused without an ipv6 listener, got %v", err)
+	}
+	if pinholes := gateway.Pinholes(); len(pinholes) != 0 {
+		t.Errorf("Expected no pinhole, got %+v", pinholes)
+	}
+}
@@ -0,0 +1,42 @@
+package portmap
+
+import (
+	"context"
+	"errors"
+	"strconv"
+
+	"github.com/dragonchain/dragonchain-installer/internal/upnp"
+)
+
+// UPnP maps ports with UPnP IGD v1/v2
+type UPnP struct{}
+
+// Name is "upnp"
+func (*UPnP) Name() string {
+	return "upnp"
+}
+
+// AddPortMapping asks the UPnP router to map the external TCP port to the same port on this machine
+func (*UPnP) AddPortMapping(ctx context.Context, port int) error {
+	return upnp.AddUPNPPortMapping(ctx, port)
+}
+
+// UPnPIPv6Pinhole opens the port through the UPnP router's ipv6 firewall, for chains which are only reachable over ipv6
+type UPnPIPv6Pinhole struct {
+	// This machine's (public) ipv6 address
+	Address string
+}
+
+// Name is "upnp-pinhole"
+func (*UPnPIPv6Pinhole) Name() string {
+	return "upnp-pinhole"
+}
+
+// AddPortMapping asks the UPnP router to allow inbound connections to the TCP port on this machine's ipv6 address.
+// Fails without opening the pinhole if nothing on this machine accepts ipv6 connections on the port (see RelayIPv6)
+func (pinhole *UPnPIPv6Pinhole) AddPortMapping(ctx context.Context, port int) error {
+	if !AcceptsIPv6(port) {
+		return errors.New("Nothing on this machine accepts ipv6 connections on port " + strconv.Itoa(port) + ", so a pinhole wouldn't reach the chain")
+	}
+	return upnp.AddIPv6Pinhole(ctx, port, pinhole.Address)
+}
@@ -0,0 +1,202 @@
+// Package preflight checks that this machine can run the chain's kubernetes cluster before anything is installed
+package preflight
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/dragonchain/dragonchain-installer/internal/checkresult"
+	"github.com/dragonchain/dragonchain-installer/internal/configuration"
+	"github.com/dragonchain/dragonchain-installer/internal/minikube"
+	"github.com/dragonchain/dragonchain-installer/internal/virtualbox"
+)
+
+// Resources needed to run kubernetes with native docker (minikube's own minimums)
+const (
+	nativeMemoryMB = 2000
+	nativeCpus     = 2
+)
+
+// Space needed in the minikube home regardless of the VM's disk size, for the minikube ISO, VM image and cached images
+const minFreeDiskMB = 5000
+
+// Memory left for this machine's own use on top of the VM's
+const hostMemoryHeadroomMB = 1000
+
+// Result is the result of all of the preflight checks
+type Result struct {
+	Checks []checkresult.Result
+}
+
+// Resources a cluster created with config needs, in megabytes
+func requirements(config *configuration.Configuration) (memoryMB int, cpus int, diskMB int, err error) {
+	if !config.UseVM {
+		return nativeMemoryMB, nativeCpus, minFreeDiskMB, nil
+	}
+	memory, cpus, diskSize := configuration.VMResources(config)
+	if memoryMB, err = configuration.ParseSizeMB(memory); err != nil {
+		return
+	}
+	diskMB, err = configuration.ParseSizeMB(diskSize)
+	return
+}
+
+// Folder where minikube keeps its VMs and caches (or the closest existing parent, before minikube has been run)
+func minikubeHome() (string, error) {
+	home, err := minikube.Home()
+	if err != nil {
+		return "", err
+	}
+	for {
+		if _, err := os.Stat(home); err == nil || filepath.Dir(home) == home {
+			return home, nil
+		}
+		home = filepath.Dir(home)
+	}
+}
+
+func checkMemory(memoryMB int, useVM bool) checkresult.Result {
+	check := checkresult.Result{Name: "Available memory", Status: checkresult.Pass}
+	available, err := availableMemoryMB()
+	if err != nil {
+		check.Status = checkresult.Warn
+		check.Details = "Couldn't find available memory: " + err.Error()
+		return check
+	}
+	check.Details = strconv.Itoa(available) + "mb available, " + strconv.Itoa(memoryMB) + "mb needed"
+	if useVM {
+		check.Details += " for the VM"
+	}
+	if available < memoryMB {
+		check.Status = checkresult.Fail
+		check.Details += ". Close other programs or choose less memory for the VM"
+	} else if useVM && available < memoryMB+hostMemoryHeadroomMB {
+		check.Status = checkresult.Warn
+		check.Details += ", leaving less than " + strconv.Itoa(hostMemoryHeadroomMB) + "mb for everything else on this machine"
+	}
+	return check
+}
+
+func checkCpus(cpus int, useVM bool) checkresult.Result {
+	check := checkresult.Result{Name: "CPUs", Status: checkresult.Pass, Details: strconv.Itoa(runtime.NumCPU()) + " available, " + strconv.Itoa(cpus) + " needed"}
+	if runtime.NumCPU() < cpus {
+		check.Status = checkresult.Fail
+		if useVM {
+			check.Details += ". Choose fewer cpus for the VM"
+		}
+	}
+	return check
+}
+
+func checkDisk(diskMB int, useVM bool) checkresult.Result {
+	check := checkresult.Result{Name: "Free disk in the minikube home", Status: checkresult.Pass}
+	home, err := minikubeHome()
+	if err != nil {
+		check.Status = checkresult.Warn
+		check.Details = err.Error()
+		return check
+	}
+	free, err := freeDiskMB(home)
+	if err != nil {
+		check.Status = checkresult.Warn
+		check.Details = "Couldn't find free disk space of " + home + ": " + err.Error()
+		return check
+	}
+	check.Details = strconv.Itoa(free) + "mb free in " + home
+	if free < minFreeDiskMB {
+		check.Status = checkresult.Fail
+		check.Details += ", at least " + strconv.Itoa(minFreeDiskMB) + "mb needed"
+	} else if useVM && free < diskMB {
+		// The VM's disk only takes up space as it is used
+		check.Status = checkresult.Warn
+		check.Details += ", less than the VM's " + strconv.Itoa(diskMB) + "mb disk could grow to"
+	}
+	return check
+}
+
+// Check that the cpu supports hardware virtualization (linux only)
+func checkVirtualizationFlags() checkresult.Result {
+	check := checkresult.Result{Name: "Hardware virtualization support", Status: checkresult.Pass, Details: "Not checked on this OS"}
+	if !configuration.Linux {
+		return check
+	}
+	cpuinfo, err := ioutil.ReadFile("/proc/cpuinfo")
+	if err != nil {
+		check.Status = checkresult.Warn
+		check.Details = "Couldn't read /proc/cpuinfo: " + err.Error()
+		return check
+	}
+	for _, line := range strings.Split(string(cpuinfo), "\n") {
+		if !strings.HasPrefix(line, "flags") {
+			continue
+		}
+		for _, flag := range strings.Fields(line) {
+			if flag == "vmx" || flag == "svm" {
+				check.Details = "cpu has " + flag
+				return check
+			}
+		}
+	}
+	check.Status = checkresult.Fail
+	check.Details = "cpu doesn't have vmx or svm; it doesn't support virtualization (or this machine is itself a VM without nested virtualization). Use native docker instead of a VM"
+	return check
+}
+
+// Check that virtualization is enabled in the firmware (/dev/kvm only exists if it is) and that virtualbox's kernel
+// module is loaded if virtualbox is already installed (linux only)
+func checkKernelModules(ctx context.Context) []checkresult.Result {
+	if !configuration.Linux {
+		return nil
+	}
+	kvm := checkresult.Result{Name: "Virtualization enabled (/dev/kvm)", Status: checkresult.Pass, Details: "/dev/kvm exists"}
+	if _, err := os.Stat("/dev/kvm"); err != nil {
+		kvm.Status = checkresult.Warn
+		kvm.Details = "/dev/kvm doesn't exist; virtualization may be disabled in the BIOS/UEFI settings (or the kvm module isn't loaded)"
+	}
+	checks := []checkresult.Result{kvm}
+	if _, err := virtualbox.Version(ctx); err != nil {
+		// Virtualbox isn't installed yet; the installer sets it up
+		return checks
+	}
+	vboxdrv := checkresult.Result{Name: "Virtualbox kernel module (vboxdrv)", Status: checkresult.Pass, Details: "loaded"}
+	modules, err := ioutil.ReadFile("/proc/modules")
+	if err != nil {
+		vboxdrv.Status = checkresult.Warn
+		vboxdrv.Details = "Couldn't read /proc/modules: " + err.Error()
+	} else if !strings.Contains("\n"+string(modules), "\nvboxdrv ") {
+		vboxdrv.Status = checkresult.Fail
+		vboxdrv.Details = "Virtualbox is installed but vboxdrv isn't loaded. Run 'sudo /sbin/vboxconfig' (on machines with secure boot, the module also has to be signed)"
+	}
+	return append(checks, vboxdrv)
+}
+
+// Run checks that this machine has the memory, cpus and disk for a cluster created with config, and (when using a VM)
+// that it can run virtualbox VMs
+func Run(ctx context.Context, config *configuration.Configuration) (*Result, error) {
+	memoryMB, cpus, diskMB, err := requirements(config)
+	if err != nil {
+		return nil, err
+	}
+	result := &Result{}
+	result.Checks = append(result.Checks, checkMemory(memoryMB, config.UseVM), checkCpus(cpus, config.UseVM), checkDisk(diskMB, config.UseVM))
+	if config.UseVM {
+		result.Checks = append(result.Checks, checkVirtualizationFlags())
+		result.Checks = append(result.Checks, checkKernelModules(ctx)...)
+	}
+	return result, nil
+}
+
+// Failed returns whether any check failed
+func (result *Result) Failed() bool {
+	return checkresult.Failed(result.Checks)
+}
+
+// Print displays the result of each check
+func (result *Result) Print() {
+	checkresult.Print("Preflight checks", result.Checks)
+}
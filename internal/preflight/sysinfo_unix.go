@@ -0,0 +1,60 @@
+//go:build !windows
+// +build !windows
+
+package preflight
+
+import (
+	"bufio"
+	"errors"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/dragonchain/dragonchain-installer/internal/configuration"
+)
+
+func freeDiskMB(path string) (int, error) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(path, &stat); err != nil {
+		return -1, err
+	}
+	return int(uint64(stat.Bavail) * uint64(stat.Bsize) / 1024 / 1024), nil
+}
+
+func availableMemoryMB() (int, error) {
+	if configuration.Macos {
+		// macos uses otherwise free memory for caches, so the total is the closest useful number
+		out, err := exec.Command("sysctl", "-n", "hw.memsize").Output()
+		if err != nil {
+			return -1, err
+		}
+		bytes, err := strconv.ParseInt(strings.TrimSpace(string(out)), 10, 64)
+		if err != nil {
+			return -1, err
+		}
+		return int(bytes / 1024 / 1024), nil
+	}
+	file, err := os.Open("/proc/meminfo")
+	if err != nil {
+		return -1, err
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		// MemAvailable:    8012345 kB
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 3 && fields[0] == "MemAvailable:" {
+			kilobytes, err := strconv.Atoi(fields[1])
+			if err != nil {
+				return -1, err
+			}
+			return kilobytes / 1024, nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return -1, err
+	}
+	return -1, errors.New("No MemAvailable in /proc/meminfo")
+}
@@ -0,0 +1,44 @@
+package preflight
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+var kernel32 = syscall.NewLazyDLL("kernel32.dll")
+
+// MEMORYSTATUSEX
+type memoryStatus struct {
+	length               uint32
+	memoryLoad           uint32
+	totalPhys            uint64
+	availPhys            uint64
+	totalPageFile        uint64
+	availPageFile        uint64
+	totalVirtual         uint64
+	availVirtual         uint64
+	availExtendedVirtual uint64
+}
+
+func freeDiskMB(path string) (int, error) {
+	pathPtr, err := syscall.UTF16PtrFromString(path)
+	if err != nil {
+		return -1, err
+	}
+	var freeBytes uint64
+	result, _, err := kernel32.NewProc("GetDiskFreeSpaceExW").Call(uintptr(unsafe.Pointer(pathPtr)), uintptr(unsafe.Pointer(&freeBytes)), 0, 0)
+	if result == 0 {
+		return -1, err
+	}
+	return int(freeBytes / 1024 / 1024), nil
+}
+
+func availableMemoryMB() (int, error) {
+	status := memoryStatus{}
+	status.length = uint32(unsafe.Sizeof(status))
+	result, _, err := kernel32.NewProc("GlobalMemoryStatusEx").Call(uintptr(unsafe.Pointer(&status)))
+	if result == 0 {
+		return -1, err
+	}
+	return int(status.availPhys / 1024 / 1024), nil
+}
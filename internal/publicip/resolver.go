@@ -0,0 +1,211 @@
+package publicip
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Resolver finds this machine's public ip by asking several sources and cross-checking their answers
+type Resolver struct {
+	// Urls which respond with the requesting ip in plain text
+	HTTPProviders []string
+	// STUN servers (host:port)
+	STUNServers []string
+	// Asks the router for its external address (i.e. with upnp), if not nil
+	Gateway func(ctx context.Context) (string, error)
+	// How long to wait for any one source
+	Timeout time.Duration
+}
+
+// Result is the answer from one source
+type Result struct {
+	Source string
+	IP     net.IP
+	Err    error
+}
+
+// Networks which are never a public address (as a gateway reports with double NAT or carrier grade NAT)
+var nonPublicNetworks = []string{"0.0.0.0/8", "10.0.0.0/8", "100.64.0.0/10", "127.0.0.0/8", "169.254.0.0/16", "172.16.0.0/12", "192.168.0.0/16", "::1/128", "fc00::/7", "fe80::/10"}
+
+// IsPublic checks if ip is a globally routable address
+func IsPublic(ip net.IP) bool {
+	if ip == nil || ip.IsUnspecified() || ip.IsMulticast() {
+		return false
+	}
+	for _, cidr := range nonPublicNetworks {
+		_, network, _ := net.ParseCIDR(cidr)
+		if network.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// Parse and validate an address returned by a source
+func parseIP(text string) (net.IP, error) {
+	ip := net.ParseIP(strings.TrimSpace(text))
+	if ip == nil {
+		return nil, errors.New("Response is not an ip address: " + strconv.Quote(strings.TrimSpace(text)))
+	}
+	if !IsPublic(ip) {
+		return nil, errors.New(ip.String() + " is not a public address")
+	}
+	return ip, nil
+}
+
+// Ask a provider over network (tcp4 or tcp6), which decides which of the public addresses it sees
+func (resolver *Resolver) httpLookup(ctx context.Context, provider string, network string) (net.IP, error) {
+	req, err := http.NewRequest("GET", provider, nil)
+	if err != nil {
+		return nil, err
+	}
+	// Some providers only respond in plain text to command line clients
+	req.Header.Set("User-Agent", "curl/7.68.0")
+	dialer := &net.Dialer{}
+	transport := &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: func(ctx context.Context, _ string, address string) (net.Conn, error) {
+			return dialer.DialContext(ctx, network, address)
+		},
+	}
+	defer transport.CloseIdleConnections()
+	resp, err := (&http.Client{Timeout: resolver.Timeout, Transport: transport}).Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	// An ip is short; don't read an unexpected html page
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, 256))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != 200 {
+		return nil, errors.New("Responded with status " + strconv.Itoa(resp.StatusCode))
+	}
+	return parseIP(string(body))
+}
+
+// Query all of the sources concurrently. Results are in a fixed order, which decides ties between sources:
+// the gateway, then the http providers and STUN servers in the order they are configured
+func (resolver *Resolver) query(ctx context.Context) []Result {
+	type lookup struct {
+		source string
+		run    func() (net.IP, error)
+	}
+	var lookups []lookup
+	if resolver.Gateway != nil {
+		lookups = append(lookups, lookup{"gateway", func() (net.IP, error) {
+			gatewayCtx, cancel := context.WithTimeout(ctx, resolver.Timeout)
+			defer cancel()
+			ip, err := resolver.Gateway(gatewayCtx)
+			if err != nil {
+				return nil, err
+			}
+			return parseIP(ip)
+		}})
+	}
+	// Ask every http provider and STUN server over both ipv4 and ipv6, to find the public address of each
+	for _, family := range []string{"4", "6"} {
+		family := family
+		suffix := ""
+		if family == "6" {
+			suffix = " (ipv6)"
+		}
+		for _, provider := range resolver.HTTPProviders {
+			provider := provider
+			lookups = append(lookups, lookup{provider + suffix, func() (net.IP, error) { return resolver.httpLookup(ctx, provider, "tcp"+family) }})
+		}
+		for _, server := range resolver.STUNServers {
+			server := server
+			lookups = append(lookups, lookup{"stun:" + server + suffix, func() (net.IP, error) {
+				ip, err := stunLookup(ctx, "udp"+family, server, resolver.Timeout)
+				if err != nil {
+					return nil, err
+				}
+				return parseIP(ip.String())
+			}})
+		}
+	}
+	results := make([]Result, len(lookups))
+	var group sync.WaitGroup
+	for i, lookup := range lookups {
+		group.Add(1)
+		go func(i int, source string, run func() (net.IP, error)) {
+			defer group.Done()
+			ip, err := run()
+			results[i] = Result{Source: source, IP: ip, Err: err}
+		}(i, lookup.source, lookup.run)
+	}
+	group.Wait()
+	return results
+}
+
+// Pick the address most sources agree on (the earliest source's address if there is a tie), warning about any disagreement
+func vote(results []Result) net.IP {
+	counts := map[string]int{}
+	for _, result := range results {
+		counts[result.IP.String()]++
+	}
+	var best string
+	for _, result := range results {
+		if best == "" || counts[result.IP.String()] > counts[best] {
+			best = result.IP.String()
+		}
+	}
+	if len(counts) > 1 {
+		fmt.Println("WARNING: Sources disagree about the public ip; using " + best + " which most of them reported")
+		for _, result := range results {
+			fmt.Println("  " + result.Source + ": " + result.IP.String())
+		}
+	}
+	return net.ParseIP(best)
+}
+
+// Resolve finds the public ipv4 and ipv6 addresses (either may be nil, but not both)
+func (resolver *Resolver) Resolve(ctx context.Context) (ipv4 net.IP, ipv6 net.IP, err error) {
+	var v4Results, v6Results []Result
+	var failures []string
+	for _, result := range resolver.query(ctx) {
+		if result.Err != nil {
+			failures = append(failures, result.Source+": "+result.Err.Error())
+		} else if result.IP.To4() != nil {
+			v4Results = append(v4Results, result)
+		} else {
+			v6Results = append(v6Results, result)
+		}
+	}
+	if len(v4Results) == 0 && len(v6Results) == 0 {
+		if ctx.Err() != nil {
+			return nil, nil, ctx.Err()
+		}
+		return nil, nil, errors.New("Could not get public ip from any source:\n" + strings.Join(failures, "\n"))
+	}
+	if len(v4Results) > 0 {
+		ipv4 = vote(v4Results)
+	}
+	if len(v6Results) > 0 {
+		ipv6 = vote(v6Results)
+	}
+	return ipv4, ipv6, nil
+}
+
+// ResolvePreferIPv4 finds the public ipv4 address, or the ipv6 address if there is no public ipv4 address
+func (resolver *Resolver) ResolvePreferIPv4(ctx context.Context) (net.IP, error) {
+	ipv4, ipv6, err := resolver.Resolve(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if ipv4 != nil {
+		return ipv4, nil
+	}
+	return ipv6, nil
+}
@@ -0,0 +1,215 @@
+package publicip
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Start an http provider which responds with status and body
+func newProvider(t *testing.T, status int, body string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+		io.WriteString(w, body)
+	}))
+}
+
+func TestIsPublic(t *testing.T) {
+	public := []string{"203.0.113.7", "8.8.8.8", "2001:db8::1", "2606:4700::1111"}
+	private := []string{"10.1.2.3", "172.16.0.1", "192.168.1.1", "100.64.0.1", "127.0.0.1", "169.254.1.1", "0.0.0.0", "224.0.0.1", "::1", "fd00::1", "fe80::1", "::"}
+	for _, address := range public {
+		if !IsPublic(net.ParseIP(address)) {
+			t.Errorf("Expected %s to be public", address)
+		}
+	}
+	for _, address := range private {
+		if IsPublic(net.ParseIP(address)) {
+			t.Errorf("Expected %s not to be public", address)
+		}
+	}
+	if IsPublic(nil) {
+		t.Error("Expected nil not to be public")
+	}
+}
+
+func TestHTTPLookup(t *testing.T) {
+	cases := []struct {
+		name     string
+		status   int
+		body     string
+		expected string
+	}{
+		{"ipv4", 200, "203.0.113.7\n", "203.0.113.7"},
+		{"ipv6", 200, "2001:db8::7", "2001:db8::7"},
+		{"not an ip", 200, "<html>203.0.113.7</html>", ""},
+		{"private address", 200, "192.168.1.7", ""},
+		{"carrier grade nat address", 200, "100.64.1.7", ""},
+		{"error status", 503, "203.0.113.7", ""},
+		{"not found", 404, "", ""},
+	}
+	resolver := &Resolver{Timeout: time.Second}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			provider := newProvider(t, c.status, c.body)
+			defer provider.Close()
+			ip, err := resolver.httpLookup(context.Background(), provider.URL, "tcp4")
+			if c.expected == "" {
+				if err == nil {
+					t.Errorf("Expected an error, got %s", ip)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !ip.Equal(net.ParseIP(c.expected)) {
+				t.Errorf("Expected %s, got %s", c.expected, ip)
+			}
+		})
+	}
+}
+
+func TestResolve(t *testing.T) {
+	v4Provider := newProvider(t, 200, "203.0.113.7")
+	defer v4Provider.Close()
+	v6Provider := newProvider(t, 200, "2001:db8::7")
+	defer v6Provider.Close()
+	failing := newProvider(t, 500, "")
+	defer failing.Close()
+	resolver := &Resolver{
+		HTTPProviders: []string{v4Provider.URL, v6Provider.URL, failing.URL},
+		STUNServers:   []string{mappingStunServer(t, net.ParseIP("203.0.113.7"))},
+		Gateway: func(ctx context.Context) (string, error) {
+			return "203.0.113.7", nil
+		},
+		Timeout: time.Second,
+	}
+
+	ipv4, ipv6, err := resolver.Resolve(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ipv4.Equal(net.ParseIP("203.0.113.7")) {
+		t.Errorf("Expected ipv4 203.0.113.7, got %s", ipv4)
+	}
+	if !ipv6.Equal(net.ParseIP("2001:db8::7")) {
+		t.Errorf("Expected ipv6 2001:db8::7, got %s", ipv6)
+	}
+	ip, err := resolver.ResolvePreferIPv4(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ip.Equal(ipv4) {
+		t.Errorf("Expected the ipv4 address to be preferred, got %s", ip)
+	}
+}
+
+func TestResolveIPv6Only(t *testing.T) {
+	provider := newProvider(t, 200, "2001:db8::7")
+	defer provider.Close()
+	resolver := &Resolver{HTTPProviders: []string{provider.URL}, Timeout: time.Second}
+
+	ipv4, ipv6, err := resolver.Resolve(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ipv4 != nil || !ipv6.Equal(net.ParseIP("2001:db8::7")) {
+		t.Errorf("Expected only ipv6 2001:db8::7, got %s and %s", ipv4, ipv6)
+	}
+	ip, err := resolver.ResolvePreferIPv4(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ip.Equal(ipv6) {
+		t.Errorf("Expected the ipv6 address without an ipv4 address, got %s", ip)
+	}
+}
+
+func TestResolveNoSources(t *testing.T) {
+	failing := newProvider(t, 500, "")
+	defer failing.Close()
+	private := newProvider(t, 200, "10.0.0.7")
+	defer private.Close()
+	resolver := &Resolver{
+		HTTPProviders: []string{failing.URL, private.URL},
+		Gateway: func(ctx context.Context) (string, error) {
+			return "", errors.New("no router")
+		},
+		Timeout: time.Second,
+	}
+
+	_, _, err := resolver.Resolve(context.Background())
+	if err == nil {
+		t.Fatal("Expected an error")
+	}
+	for _, failure := range []string{"gateway: no router", failing.URL + ": Responded with status 500", private.URL + ": 10.0.0.7 is not a public address"} {
+		if !strings.Contains(err.Error(), failure) {
+			t.Errorf("Expected the error to include %q, got %v", failure, err)
+		}
+	}
+}
+
+func TestQueryOrder(t *testing.T) {
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+		io.WriteString(w, "203.0.113.1")
+	}))
+	defer slow.Close()
+	fast := newProvider(t, 200, "203.0.113.2")
+	defer fast.Close()
+	stun := mappingStunServer(t, net.ParseIP("203.0.113.3"))
+	resolver := &Resolver{
+		HTTPProviders: []string{slow.URL, fast.URL},
+		STUNServers:   []string{stun},
+		Gateway: func(ctx context.Context) (string, error) {
+			time.Sleep(300 * time.Millisecond)
+			return "203.0.113.4", nil
+		},
+		Timeout: time.Second,
+	}
+
+	results := resolver.query(context.Background())
+	expected := []string{"gateway", slow.URL, fast.URL, "stun:" + stun, slow.URL + " (ipv6)", fast.URL + " (ipv6)", "stun:" + stun + " (ipv6)"}
+	if len(results) != len(expected) {
+		t.Fatalf("Expected %d results, got %+v", len(expected), results)
+	}
+	for i, result := range results {
+		if result.Source != expected[i] {
+			t.Errorf("Expected result %d to be from %s, got %s", i, expected[i], result.Source)
+		}
+	}
+	// The slowest sources are first, so a tie goes to the gateway whichever answers first
+	if ip := vote(results[:4]); !ip.Equal(net.ParseIP("203.0.113.4")) {
+		t.Errorf("Expected the tie to go to the gateway's 203.0.113.4, got %s", ip)
+	}
+}
+
+func TestVote(t *testing.T) {
+	result := func(source string, ip string) Result {
+		return Result{Source: source, IP: net.ParseIP(ip)}
+	}
+	cases := []struct {
+		name     string
+		results  []Result
+		expected string
+	}{
+		{"agree", []Result{result("gateway", "203.0.113.1"), result("http", "203.0.113.1")}, "203.0.113.1"},
+		{"majority", []Result{result("gateway", "203.0.113.1"), result("http1", "203.0.113.2"), result("http2", "203.0.113.2")}, "203.0.113.2"},
+		{"majority after a tie", []Result{result("a", "203.0.113.1"), result("b", "203.0.113.2"), result("c", "203.0.113.2"), result("d", "203.0.113.1"), result("e", "203.0.113.2")}, "203.0.113.2"},
+		{"tie goes to the first source", []Result{result("gateway", "203.0.113.1"), result("http", "203.0.113.2")}, "203.0.113.1"},
+		{"tie of several goes to the first source", []Result{result("a", "203.0.113.2"), result("b", "203.0.113.1"), result("c", "203.0.113.1"), result("d", "203.0.113.2")}, "203.0.113.2"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if ip := vote(c.results); !ip.Equal(net.ParseIP(c.expected)) {
+				t.Errorf("Expected %s, got %s", c.expected, ip)
+			}
+		})
+	}
+}
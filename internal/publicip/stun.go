@@ -0,0 +1,121 @@
+package publicip
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"net"
+	"time"
+)
+
+// STUN (RFC 5389) constants needed for a binding request
+const (
+	stunBindingRequest   = 0x0001
+	stunBindingResponse  = 0x0101
+	stunMagicCookie      = 0x2112A442
+	stunMappedAddress    = 0x0001
+	stunXorMappedAddress = 0x0020
+	stunHeaderLength     = 20
+)
+
+// Ask a STUN server over network (udp4 or udp6) which address our requests come from
+func stunLookup(ctx context.Context, network string, server string, timeout time.Duration) (net.IP, error) {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, network, server)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	deadline := time.Now().Add(timeout)
+	if ctxDeadline, ok := ctx.Deadline(); ok && ctxDeadline.Before(deadline) {
+		deadline = ctxDeadline
+	}
+	conn.SetDeadline(deadline)
+	request := make([]byte, stunHeaderLength)
+	binary.BigEndian.PutUint16(request[0:2], stunBindingRequest)
+	binary.BigEndian.PutUint32(request[4:8], stunMagicCookie)
+	transactionID := request[8:20]
+	if _, err := rand.Read(transactionID); err != nil {
+		return nil, err
+	}
+	if _, err := conn.Write(request); err != nil {
+		return nil, err
+	}
+	response := make([]byte, 1024)
+	n, err := conn.Read(response)
+	if err != nil {
+		return nil, err
+	}
+	return parseStunResponse(response[:n], transactionID)
+}
+
+func parseStunResponse(response []byte, transactionID []byte) (net.IP, error) {
+	if len(response) < stunHeaderLength || binary.BigEndian.Uint16(response[0:2]) != stunBindingResponse {
+		return nil, errors.New("Not a STUN binding response")
+	}
+	if binary.BigEndian.Uint32(response[4:8]) != stunMagicCookie || !bytes.Equal(response[8:20], transactionID) {
+		return nil, errors.New("STUN response does not match the request")
+	}
+	length := int(binary.BigEndian.Uint16(response[2:4]))
+	if stunHeaderLength+length > len(response) {
+		return nil, errors.New("STUN response is truncated")
+	}
+	attributes := response[stunHeaderLength : stunHeaderLength+length]
+	var mapped net.IP
+	for len(attributes) >= 4 {
+		attrType := binary.BigEndian.Uint16(attributes[0:2])
+		attrLength := int(binary.BigEndian.Uint16(attributes[2:4]))
+		if 4+attrLength > len(attributes) {
+			break
+		}
+		value := attributes[4 : 4+attrLength]
+		switch attrType {
+		case stunXorMappedAddress:
+			// The xor'd form is preferred, since some NATs rewrite addresses they find in packets
+			if ip := parseStunAddress(value, response[4:20]); ip != nil {
+				return ip, nil
+			}
+		case stunMappedAddress:
+			mapped = parseStunAddress(value, nil)
+		}
+		// Attributes are padded to a multiple of 4 bytes
+		next := 4 + (attrLength+3)/4*4
+		if next > len(attributes) {
+			break
+		}
+		attributes = attributes[next:]
+	}
+	if mapped == nil {
+		return nil, errors.New("STUN response has no mapped address")
+	}
+	return mapped, nil
+}
+
+// Parse a (XOR-)MAPPED-ADDRESS value. xorKey is the magic cookie and transaction id for XOR-MAPPED-ADDRESS, or nil
+func parseStunAddress(value []byte, xorKey []byte) net.IP {
+	if len(value) < 4 {
+		return nil
+	}
+	var ipLength int
+	switch value[1] {
+	case 0x01:
+		ipLength = net.IPv4len
+	case 0x02:
+		ipLength = net.IPv6len
+	default:
+		return nil
+	}
+	if len(value) < 4+ipLength {
+		return nil
+	}
+	ip := make(net.IP, ipLength)
+	copy(ip, value[4:4+ipLength])
+	if xorKey != nil {
+		for i := range ip {
+			ip[i] ^= xorKey[i]
+		}
+	}
+	return ip
+}
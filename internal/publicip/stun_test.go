@@ -0,0 +1,178 @@
+package publicip
+
+import (
+	"context"
+	"encoding/binary"
+	"net"
+	"testing"
+	"time"
+)
+
+var testTransactionID = []byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12}
+
+// Encode a (XOR-)MAPPED-ADDRESS attribute for ip and port
+func stunAddressAttribute(attrType uint16, ip net.IP, port int, transactionID []byte) []byte {
+	family := byte(0x02)
+	if ip.To4() != nil {
+		ip = ip.To4()
+		family = 0x01
+	}
+	value := make([]byte, 4+len(ip))
+	value[1] = family
+	binary.BigEndian.PutUint16(value[2:4], uint16(port))
+	copy(value[4:], ip)
+	if attrType == stunXorMappedAddress {
+		key := make([]byte, 16)
+		binary.BigEndian.PutUint32(key[0:4], stunMagicCookie)
+		copy(key[4:], transactionID)
+		binary.BigEndian.PutUint16(value[2:4], uint16(port)^uint16(stunMagicCookie>>16))
+		for i := range ip {
+			value[4+i] ^= key[i]
+		}
+	}
+	attribute := make([]byte, 4, 4+len(value))
+	binary.BigEndian.PutUint16(attribute[0:2], attrType)
+	binary.BigEndian.PutUint16(attribute[2:4], uint16(len(value)))
+	return append(attribute, value...)
+}
+
+// Encode a binding response with the given attributes
+func stunResponse(transactionID []byte, attributes ...[]byte) []byte {
+	response := make([]byte, stunHeaderLength)
+	binary.BigEndian.PutUint16(response[0:2], stunBindingResponse)
+	binary.BigEndian.PutUint32(response[4:8], stunMagicCookie)
+	copy(response[8:20], transactionID)
+	for _, attribute := range attributes {
+		response = append(response, attribute...)
+	}
+	binary.BigEndian.PutUint16(response[2:4], uint16(len(response)-stunHeaderLength))
+	return response
+}
+
+func TestParseStunResponse(t *testing.T) {
+	v4 := net.ParseIP("203.0.113.7")
+	v6 := net.ParseIP("2001:db8::7")
+	other := net.ParseIP("198.51.100.1")
+	// An attribute the parser doesn't know, with a length which needs padding
+	unknown := []byte{0x80, 0x22, 0x00, 0x05, 'a', 'b', 'c', 'd', 'e', 0, 0, 0}
+	cases := []struct {
+		name     string
+		response []byte
+		expected net.IP
+	}{
+		{"xor-mapped ipv4", stunResponse(testTransactionID, stunAddressAttribute(stunXorMappedAddress, v4, 5000, testTransactionID)), v4},
+		{"xor-mapped ipv6", stunResponse(testTransactionID, stunAddressAttribute(stunXorMappedAddress, v6, 5000, testTransactionID)), v6},
+		{"mapped ipv4", stunResponse(testTransactionID, stunAddressAttribute(stunMappedAddress, v4, 5000, nil)), v4},
+		{"mapped ipv6", stunResponse(testTransactionID, stunAddressAttribute(stunMappedAddress, v6, 5000, nil)), v6},
+		{"xor-mapped preferred", stunResponse(testTransactionID, stunAddressAttribute(stunMappedAddress, other, 5000, nil), stunAddressAttribute(stunXorMappedAddress, v4, 5000, testTransactionID)), v4},
+		{"after padded attribute", stunResponse(testTransactionID, unknown, stunAddressAttribute(stunXorMappedAddress, v4, 5000, testTransactionID)), v4},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			ip, err := parseStunResponse(c.response, testTransactionID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !ip.Equal(c.expected) {
+				t.Errorf("Expected %s, got %s", c.expected, ip)
+			}
+		})
+	}
+}
+
+func TestParseStunResponseErrors(t *testing.T) {
+	ip := net.ParseIP("203.0.113.7")
+	valid := stunResponse(testTransactionID, stunAddressAttribute(stunXorMappedAddress, ip, 5000, testTransactionID))
+	wrongType := append([]byte(nil), valid...)
+	binary.BigEndian.PutUint16(wrongType[0:2], stunBindingRequest)
+	wrongCookie := append([]byte(nil), valid...)
+	wrongCookie[4] ^= 0xff
+	// The last attribute claims more padding than the response has
+	unpadded := stunResponse(testTransactionID, []byte{0x80, 0x22, 0x00, 0x05, 'a', 'b', 'c', 'd', 'e'})
+	cases := []struct {
+		name          string
+		response      []byte
+		transactionID []byte
+	}{
+		{"too short", valid[:10], testTransactionID},
+		{"truncated attributes", valid[:len(valid)-4], testTransactionID},
+		{"not a binding response", wrongType, testTransactionID},
+		{"wrong magic cookie", wrongCookie, testTransactionID},
+		{"mismatched transaction id", valid, []byte{12, 11, 10, 9, 8, 7, 6, 5, 4, 3, 2, 1}},
+		{"no address", stunResponse(testTransactionID), testTransactionID},
+		{"unknown address family", stunResponse(testTransactionID, []byte{0x00, 0x20, 0x00, 0x08, 0, 0x03, 0, 0, 1, 2, 3, 4}), testTransactionID},
+		{"missing padding", unpadded, testTransactionID},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if ip, err := parseStunResponse(c.response, c.transactionID); err == nil {
+				t.Errorf("Expected an error, got %s", ip)
+			}
+		})
+	}
+}
+
+// Start a STUN server on a local udp port, which answers with respond(request)
+func newStunServer(t *testing.T, respond func(request []byte) []byte) string {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		buf := make([]byte, 1024)
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			if response := respond(buf[:n]); response != nil {
+				conn.WriteTo(response, addr)
+			}
+		}
+	}()
+	return conn.LocalAddr().String()
+}
+
+// A STUN server which answers every binding request with ip as the mapped address
+func mappingStunServer(t *testing.T, ip net.IP) string {
+	return newStunServer(t, func(request []byte) []byte {
+		if len(request) != stunHeaderLength || binary.BigEndian.Uint16(request[0:2]) != stunBindingRequest || binary.BigEndian.Uint32(request[4:8]) != stunMagicCookie {
+			return nil
+		}
+		transactionID := request[8:20]
+		return stunResponse(transactionID, stunAddressAttribute(stunXorMappedAddress, ip, 5000, transactionID))
+	})
+}
+
+func TestStunLookup(t *testing.T) {
+	server := mappingStunServer(t, net.ParseIP("203.0.113.7"))
+	ip, err := stunLookup(context.Background(), "udp4", server, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ip.Equal(net.ParseIP("203.0.113.7")) {
+		t.Errorf("Expected 203.0.113.7, got %s", ip)
+	}
+}
+
+func TestStunLookupMismatchedTransaction(t *testing.T) {
+	server := newStunServer(t, func(request []byte) []byte {
+		return stunResponse(testTransactionID, stunAddressAttribute(stunXorMappedAddress, net.ParseIP("203.0.113.7"), 5000, testTransactionID))
+	})
+	if ip, err := stunLookup(context.Background(), "udp4", server, time.Second); err == nil {
+		t.Errorf("Expected a response for another transaction to be rejected, got %s", ip)
+	}
+}
+
+func TestStunLookupTimeout(t *testing.T) {
+	server := newStunServer(t, func(request []byte) []byte {
+		return nil
+	})
+	start := time.Now()
+	if _, err := stunLookup(context.Background(), "udp4", server, 200*time.Millisecond); err == nil {
+		t.Error("Expected a timeout")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Expected to give up after the timeout, took %s", elapsed)
+	}
+}
@@ -0,0 +1,261 @@
+// Package supportbundle collects diagnostics of an installation into one archive to share when asking for help
+package supportbundle
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/dragonchain/dragonchain-installer/internal/configuration"
+	"github.com/dragonchain/dragonchain-installer/internal/dragonchain"
+	"github.com/dragonchain/dragonchain-installer/internal/helm"
+	"github.com/dragonchain/dragonchain-installer/internal/virtualbox"
+)
+
+// CommandTimeout for each command collected into the bundle, so that one stuck command (i.e. with the cluster down)
+// doesn't stop the rest from being collected
+var CommandTimeout = time.Minute
+
+// Lines of logs to collect from each pod and from minikube
+const logLines = "500"
+
+// Namespaces the installer deploys into
+var namespaces = []string{"dragonchain", "openfaas", "openfaas-fn", "registry"}
+
+// Folder in the archive that all entries are in
+const rootFolder = "dragonchain-support/"
+
+type bundle struct {
+	archive *tar.Writer
+	secrets []string
+}
+
+// Redact contents and add it to the archive
+func (b *bundle) add(name string, contents []byte) error {
+	contents = redact(contents, b.secrets)
+	header := &tar.Header{Name: rootFolder + name, Mode: 0600, Size: int64(len(contents)), ModTime: time.Now()}
+	if err := b.archive.WriteHeader(header); err != nil {
+		return err
+	}
+	_, err := b.archive.Write(contents)
+	return err
+}
+
+// Run collect and add its output to the archive, including how it failed if it did (failures are diagnostics too)
+func (b *bundle) addOutput(ctx context.Context, name string, collect func(ctx context.Context) ([]byte, error)) error {
+	fmt.Println("Collecting " + name)
+	ctx, cancel := context.WithTimeout(ctx, CommandTimeout)
+	defer cancel()
+	output, err := collect(ctx)
+	if err != nil {
+		output = append(output, []byte("\n[Failed: "+err.Error()+"]\n")...)
+	}
+	return b.add(name, output)
+}
+
+// Run a command (with CommandTimeout), returning it and its output (stdout and stderr), including how it failed if it did
+func commandOutput(ctx context.Context, command []string) []byte {
+	ctx, cancel := context.WithTimeout(ctx, CommandTimeout)
+	defer cancel()
+	output := bytes.NewBufferString("$ " + strings.Join(command, " ") + "\n")
+	out, err := exec.CommandContext(ctx, command[0], command[1:]...).CombinedOutput()
+	output.Write(out)
+	if err != nil {
+		output.WriteString("[Failed: " + err.Error() + "]\n")
+	}
+	output.WriteString("\n")
+	return output.Bytes()
+}
+
+// Run commands one after another, collecting all of their output into one entry
+func (b *bundle) addCommands(ctx context.Context, name string, commands ...[]string) error {
+	fmt.Println("Collecting " + name)
+	var output []byte
+	for _, command := range commands {
+		output = append(output, commandOutput(ctx, command)...)
+	}
+	return b.add(name, output)
+}
+
+// Values from the installation which must never end up in the bundle, even in unexpected places like logs
+func knownSecrets(ctx context.Context, config *configuration.Configuration) []string {
+	if config == nil {
+		return nil
+	}
+	secrets := []string{config.RegistrationToken}
+	secretCtx, cancel := context.WithTimeout(ctx, CommandTimeout)
+	defer cancel()
+	secret, err := dragonchain.GetDragonchainSecret(secretCtx, config.InternalID)
+	if err != nil {
+		// The cluster may be down; anything that looks like a key is still redacted by pattern
+		return secrets
+	}
+	var secretFields map[string]interface{}
+	if json.Unmarshal(secret, &secretFields) == nil {
+		for _, value := range secretFields {
+			if text, ok := value.(string); ok {
+				secrets = append(secrets, text)
+			}
+		}
+	}
+	return secrets
+}
+
+func (b *bundle) collectVersions(ctx context.Context, config *configuration.Configuration) error {
+	fmt.Println("Collecting versions.txt")
+	output := []byte("dc-installer " + configuration.Version + "\nos/arch " + runtime.GOOS + "/" + runtime.GOARCH + "\n\n")
+	output = append(output, commandOutput(ctx, []string{"minikube", "version"})...)
+	output = append(output, commandOutput(ctx, []string{"kubectl", "version", "--context=" + configuration.MinikubeContext})...)
+	output = append(output, commandOutput(ctx, []string{"helm", "version"})...)
+	if config != nil && config.UseVM {
+		versionCtx, cancel := context.WithTimeout(ctx, CommandTimeout)
+		defer cancel()
+		version, err := virtualbox.Version(versionCtx)
+		output = append(output, []byte("$ vboxmanage --version\n")...)
+		output = append(output, version...)
+		if err != nil {
+			output = append(output, []byte("[Failed: "+err.Error()+"]\n")...)
+		}
+	}
+	return b.add("versions.txt", output)
+}
+
+func (b *bundle) collectConfig() error {
+	fmt.Println("Collecting installation_config.json")
+	contents, err := configuration.ReadInstallationConfigFile()
+	if err != nil {
+		return b.add("installation_config.json", []byte("[Failed: "+err.Error()+"]\n"))
+	}
+	sanitized, err := sanitizeConfig(contents)
+	if err != nil {
+		return b.add("installation_config.json", []byte("[Failed to parse installation config: "+err.Error()+"]\n"))
+	}
+	return b.add("installation_config.json", sanitized)
+}
+
+func (b *bundle) collectMinikube(ctx context.Context, config *configuration.Configuration) error {
+	if err := b.addCommands(ctx, "minikube/status.txt", []string{"minikube", "status", "-p", configuration.MinikubeContext}); err != nil {
+		return err
+	}
+	if err := b.addCommands(ctx, "minikube/logs.txt", []string{"minikube", "logs", "-p", configuration.MinikubeContext, "--length", logLines}); err != nil {
+		return err
+	}
+	if config != nil && config.UseVM {
+		return b.addOutput(ctx, "virtualbox/vminfo.txt", virtualbox.VMInfo)
+	}
+	return nil
+}
+
+func (b *bundle) collectHelm(ctx context.Context, config *configuration.Configuration) error {
+	helmVersion, err := helm.GetHelmMajorVersion(ctx)
+	if err != nil {
+		return b.add("helm/list.txt", []byte("[Failed: "+err.Error()+"]\n"))
+	}
+	list := []string{"helm", "list", "--all", "--kube-context", configuration.MinikubeContext}
+	if helmVersion > 2 {
+		list = []string{"helm", "list", "--all", "--all-namespaces", "--kube-context", configuration.MinikubeContext}
+	}
+	if err := b.addCommands(ctx, "helm/list.txt", list); err != nil {
+		return err
+	}
+	releases := map[string]string{"openfaas": "openfaas", "registry": "registry"}
+	if config != nil {
+		releases["d-"+config.InternalID] = "dragonchain"
+	}
+	for release, namespace := range releases {
+		values := []string{"helm", "get", "values", release, "--kube-context", configuration.MinikubeContext}
+		if helmVersion > 2 {
+			values = []string{"helm", "get", "values", release, "-n", namespace, "--kube-context", configuration.MinikubeContext}
+		}
+		if err := b.addCommands(ctx, "helm/values-"+release+".yaml", values); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (b *bundle) collectNamespace(ctx context.Context, namespace string) error {
+	kubectl := func(args ...string) []string {
+		return append([]string{"kubectl"}, append(args, "-n", namespace, "--context="+configuration.MinikubeContext)...)
+	}
+	if err := b.addCommands(ctx, "kubectl/"+namespace+"/get-all.txt", kubectl("get", "all", "-o", "wide")); err != nil {
+		return err
+	}
+	if err := b.addCommands(ctx, "kubectl/"+namespace+"/describe-all.txt", kubectl("describe", "all")); err != nil {
+		return err
+	}
+	if err := b.addCommands(ctx, "kubectl/"+namespace+"/events.txt", kubectl("get", "events", "--sort-by=.lastTimestamp")); err != nil {
+		return err
+	}
+	podsCtx, cancel := context.WithTimeout(ctx, CommandTimeout)
+	defer cancel()
+	out, err := exec.CommandContext(podsCtx, "kubectl", kubectl("get", "pods", "-o", "jsonpath={.items[*].metadata.name}")[1:]...).Output()
+	if err != nil {
+		// Already recorded in get-all.txt
+		return nil
+	}
+	for _, pod := range strings.Fields(string(out)) {
+		if err := b.addCommands(ctx, "kubectl/"+namespace+"/logs/"+pod+".txt", kubectl("logs", pod, "--all-containers", "--tail="+logLines)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Create writes a gzipped tar of diagnostics of the installation to path: versions, the installation config, minikube's status
+// and logs, helm releases and their values, and the kubernetes resources, events and recent logs of each namespace the
+// installer uses. Secrets (i.e. HMAC keys and the registration token) are redacted. config may be nil if the installation
+// config couldn't be loaded
+func Create(ctx context.Context, config *configuration.Configuration, path string) (err error) {
+	out, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return errors.New("Error creating file " + path + ":\n" + err.Error())
+	}
+	defer out.Close()
+	// Don't leave an incomplete bundle behind
+	defer func() {
+		if err != nil {
+			out.Close()
+			os.Remove(path)
+		}
+	}()
+	compressed := gzip.NewWriter(out)
+	b := &bundle{archive: tar.NewWriter(compressed), secrets: knownSecrets(ctx, config)}
+	collectors := []func() error{
+		func() error { return b.collectVersions(ctx, config) },
+		b.collectConfig,
+		func() error { return b.collectMinikube(ctx, config) },
+		func() error { return b.collectHelm(ctx, config) },
+		func() error {
+			return b.addCommands(ctx, "kubectl/nodes.txt", []string{"kubectl", "describe", "nodes", "--context=" + configuration.MinikubeContext})
+		},
+	}
+	for _, namespace := range namespaces {
+		namespace := namespace
+		collectors = append(collectors, func() error { return b.collectNamespace(ctx, namespace) })
+	}
+	for _, collect := range collectors {
+		if err := collect(); err != nil {
+			return errors.New("Error writing support bundle:\n" + err.Error())
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+	}
+	if err := b.archive.Close(); err != nil {
+		return errors.New("Error writing support bundle:\n" + err.Error())
+	}
+	if err := compressed.Close(); err != nil {
+		return errors.New("Error writing support bundle:\n" + err.Error())
+	}
+	return nil
+}
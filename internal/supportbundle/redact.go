@@ -0,0 +1,40 @@
+package supportbundle
+
+import (
+	"bytes"
+	"encoding/json"
+	"regexp"
+)
+
+const redacted = "[REDACTED]"
+
+// Values of keys like these are secrets wherever they appear (yaml, json, ini, env vars in kubectl describe, ...)
+var secretValueRegex = regexp.MustCompile(`(?i)((?:hmac[_-]?key|auth[_-]?key|private[_-]?key|password|secret[_-]?string|registration[_-]?token|api[_-]?key)["']?\s*[:=]\s*["']?)[^\s"',]+`)
+
+// Fields of the installation config which are secret
+var secretConfigFields = []string{"RegistrationToken", "PrivateKey", "HmacID", "HmacKey"}
+
+// Replace known secret values, and the values of anything that looks like a secret, in contents
+func redact(contents []byte, secrets []string) []byte {
+	for _, secret := range secrets {
+		// Short values could match unrelated text
+		if len(secret) >= 8 {
+			contents = bytes.Replace(contents, []byte(secret), []byte(redacted), -1)
+		}
+	}
+	return secretValueRegex.ReplaceAll(contents, []byte("${1}"+redacted))
+}
+
+// Redact the secret fields of the installation config
+func sanitizeConfig(contents []byte) ([]byte, error) {
+	var config map[string]interface{}
+	if err := json.Unmarshal(contents, &config); err != nil {
+		return nil, err
+	}
+	for _, field := range secretConfigFields {
+		if value, exists := config[field]; exists && value != "" {
+			config[field] = redacted
+		}
+	}
+	return json.MarshalIndent(config, "", "  ")
+}
@@ -0,0 +1,65 @@
+package supportbundle
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestRedact(t *testing.T) {
+	secrets := []string{"", "tok", "0123456789abcdefghijklmnopqrstuvwxyzABCDEFG"}
+	cases := []struct {
+		name     string
+		contents string
+		expected string
+	}{
+		{"yaml", "hmacKey: abcdef\nname: test", "hmacKey: [REDACTED]\nname: test"},
+		{"quoted yaml", "private_key: 'abc+def/='", "private_key: '[REDACTED]'"},
+		{"json", `{"hmac-key": "abcdef", "hmac-id": "ABCDEFGHIJKL"}`, `{"hmac-key": "[REDACTED]", "hmac-id": "ABCDEFGHIJKL"}`},
+		{"env var", "HMAC_KEY=abcdef\nLEVEL=1", "HMAC_KEY=[REDACTED]\nLEVEL=1"},
+		{"kubectl describe env", "      AUTH_KEY:        abcdef\n      LEVEL:           1", "      AUTH_KEY:        [REDACTED]\n      LEVEL:           1"},
+		{"helm --set", "helm upgrade --set dragonchain.registrationToken=abcdef,secrets.registryPassword=ghijkl,level=1", "helm upgrade --set dragonchain.registrationToken=[REDACTED],secrets.registryPassword=[REDACTED],level=1"},
+		{"known secret anywhere", "Signed with 0123456789abcdefghijklmnopqrstuvwxyzABCDEFG in log", "Signed with [REDACTED] in log"},
+		{"short known secret left in other text", "Stopped token refresh", "Stopped token refresh"},
+		{"short known secret as a value", "registration_token: tok", "registration_token: [REDACTED]"},
+		{"nothing secret", "Pod d-test-webserver is running", "Pod d-test-webserver is running"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if result := string(redact([]byte(c.contents), secrets)); result != c.expected {
+				t.Errorf("Expected %q, got %q", c.expected, result)
+			}
+		})
+	}
+}
+
+func TestSanitizeConfig(t *testing.T) {
+	contents := []byte(`{"Name":"test","InternalID":"8d3f2b5c","RegistrationToken":"tok","PrivateKey":"abc+def/=","HmacID":"ABCDEFGHIJKL","HmacKey":"","Level":1}`)
+	sanitized, err := sanitizeConfig(contents)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var config map[string]interface{}
+	if err := json.Unmarshal(sanitized, &config); err != nil {
+		t.Fatal(err)
+	}
+	for _, field := range []string{"RegistrationToken", "PrivateKey", "HmacID"} {
+		if config[field] != redacted {
+			t.Errorf("Expected %s to be redacted, got %v", field, config[field])
+		}
+	}
+	// An empty value isn't a secret, and shows that it was never set
+	if config["HmacKey"] != "" {
+		t.Errorf("Expected the empty HmacKey to be kept, got %v", config["HmacKey"])
+	}
+	if config["Name"] != "test" || config["InternalID"] != "8d3f2b5c" || config["Level"] != float64(1) {
+		t.Errorf("Expected the other fields to be kept, got %v", config)
+	}
+	if strings.Contains(string(sanitized), "abc+def/=") {
+		t.Error("Expected the private key to be removed")
+	}
+
+	if _, err := sanitizeConfig([]byte("not json")); err == nil {
+		t.Error("Expected an error for an invalid config")
+	}
+}
@@ -1,6 +1,7 @@
 package upnp
 
 import (
+	"context"
 	"errors"
 	"net"
 	"time"
@@ -10,9 +11,53 @@ import (
 	"github.com/huin/goupnp/dcps/internetgateway2"
 )
 
-// AddUPNPPortMapping attempts to use UPNP in order to forward a port from the NAT to this device
-func AddUPNPPortMapping(port int) error {
-	upnp, err := discover()
+// AddUPNPPortMapping attempts to use UPNP in order to forward a port from the NAT to this device, with a lease of LeaseDuration.
+// Returns a *ConflictError if the port is already forwarded to another device
+func AddUPNPPortMapping(ctx context.Context, port int) error {
+	transport := DefaultTransport
+	return withContext(ctx, func() error {
+		return transport.addPortMapping(port)
+	})
+}
+
+// GetExternalIPAddress asks the UPNP router for its external (public) ip address
+func GetExternalIPAddress(ctx context.Context) (string, error) {
+	var ip string
+	transport := DefaultTransport
+	err := withContext(ctx, func() error {
+		upnp, err := transport.discover()
+		if err != nil {
+			return err
+		}
+		ip, err = upnp.client.GetExternalIPAddress()
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+	return ip, nil
+}
+
+// The upnp library doesn't support cancellation, so run fn in the background and stop waiting if ctx is done.
+// Results set by fn must only be read if the returned error is not from ctx, and fn must not read DefaultTransport since it may
+// still be running after this returns
+func withContext(ctx context.Context, fn func() error) error {
+	result := make(chan error, 1)
+	go func() {
+		result <- fn()
+	}()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case err := <-result:
+		return err
+	}
+}
+
+func (transport *Transport) addPortMapping(port int) error {
+	mappingLock.Lock()
+	defer mappingLock.Unlock()
+	upnp, err := transport.discover()
 	if err != nil {
 		return err
 	}
@@ -20,52 +65,73 @@ func AddUPNPPortMapping(port int) error {
 	if err != nil {
 		return err
 	}
-	// Try and delete any existing mapping before creating it to clean it up if it already exists
-	upnp.client.DeletePortMapping("", uint16(port), "TCP")
-	// Attempt to create the actual mapping
-	return upnp.client.AddPortMapping("", uint16(port), "TCP", uint16(port), ip.String(), true, "dragonchain", 0)
+	existing, err := upnp.getPortMapping(port)
+	if err != nil {
+		return err
+	}
+	if existing != nil {
+		if existing.InternalClient != ip.String() {
+			// Don't take over a port which is forwarded to another machine
+			return &ConflictError{Mapping: *existing}
+		}
+		// Delete our existing mapping before recreating it, since some routers refuse to update a mapping in place
+		upnp.client.DeletePortMapping("", uint16(port), "TCP")
+	}
+	if err := upnp.addPortMapping(port, ip); err != nil {
+		return errors.New("Router refused to create port mapping:\n" + err.Error())
+	}
+	return nil
+}
+
+func (upnp *upnp) addPortMapping(port int, ip net.IP) error {
+	lease := uint32(LeaseDuration / time.Second)
+	err := upnp.client.AddPortMapping("", uint16(port), "TCP", uint16(port), ip.String(), true, mappingDescription, lease)
+	if err != nil && isUPnPError(err) && lease != 0 {
+		// Some (mostly IGDv1) routers only support permanent mappings
+		return upnp.client.AddPortMapping("", uint16(port), "TCP", uint16(port), ip.String(), true, mappingDescription, 0)
+	}
+	return err
 }
 
 type upnp struct {
-	device *goupnp.RootDevice
-	client upnpClient
+	device    *goupnp.RootDevice
+	client    upnpClient
+	transport *Transport
 }
 
 type upnpClient interface {
 	DeletePortMapping(NewRemoteHost string, NewExternalP uint16, NewProtocol string) error
 	AddPortMapping(NewRemoteHost string, NewExternalPort uint16, NewProtocol string, NewInternalPort uint16, NewInternalClient string, NewEnabled bool, NewPortMappingDescription string, NewLeaseDuration uint32) error
 	GetNATRSIPStatus() (NewRSIPAvailable bool, NewNATEnabled bool, err error)
+	GetExternalIPAddress() (NewExternalIPAddress string, err error)
+	GetSpecificPortMappingEntry(NewRemoteHost string, NewExternalPort uint16, NewProtocol string) (NewInternalPort uint16, NewInternalClient string, NewEnabled bool, NewPortMappingDescription string, NewLeaseDuration uint32, err error)
+	GetGenericPortMappingEntry(NewPortMappingIndex uint16) (NewRemoteHost string, NewExternalPort uint16, NewProtocol string, NewInternalPort uint16, NewInternalClient string, NewEnabled bool, NewPortMappingDescription string, NewLeaseDuration uint32, err error)
 }
 
 func (upnp *upnp) internalAddress() (net.IP, error) {
-	deviceAddr, err := net.ResolveUDPAddr("udp4", upnp.device.URLBase.Host)
+	deviceAddr, err := net.ResolveUDPAddr("udp", upnp.device.URLBase.Host)
 	if err != nil {
 		return nil, err
 	}
-	ifaces, err := net.Interfaces()
+	addrs, err := upnp.transport.InterfaceAddrs()
 	if err != nil {
 		return nil, err
 	}
-	for _, iface := range ifaces {
-		addrs, err := iface.Addrs()
-		if err != nil {
-			return nil, err
-		}
-		for _, addr := range addrs {
-			if x, ok := addr.(*net.IPNet); ok && x.Contains(deviceAddr.IP) {
-				return x.IP, nil
-			}
+	for _, addr := range addrs {
+		if x, ok := addr.(*net.IPNet); ok && x.Contains(deviceAddr.IP) {
+			return x.IP, nil
 		}
 	}
 	return nil, errors.New("Could not find local address in network " + deviceAddr.String())
 }
 
-func discover() (*upnp, error) {
-	devices1, err := goupnp.DiscoverDevices(internetgateway1.URN_WANConnectionDevice_1)
+// Find a UPNP router with NAT enabled, preferring IGDv2 devices and taking the first suitable WAN connection service
+func (transport *Transport) discover() (*upnp, error) {
+	devices1, err := transport.discoverDevices(internetgateway1.URN_WANConnectionDevice_1)
 	if err != nil {
 		return nil, err
 	}
-	devices2, err := goupnp.DiscoverDevices(internetgateway2.URN_WANConnectionDevice_2)
+	devices2, err := transport.discoverDevices(internetgateway2.URN_WANConnectionDevice_2)
 	if err != nil {
 		return nil, err
 	}
@@ -85,20 +151,14 @@ func discover() (*upnp, error) {
 				if upnpClient != nil {
 					return
 				}
-				sc := goupnp.ServiceClient{
-					SOAPClient: service.NewSOAPClient(),
-					RootDevice: device.Root,
-					Location:   device.Location,
-					Service:    service,
-				}
-				sc.SOAPClient.HTTPClient.Timeout = 3 * time.Second
+				sc := transport.serviceClient(device, service)
 				if i == 0 {
 					if service := checkIGDv2(sc); service != nil {
-						upnpClient = &upnp{device.Root, service}
+						upnpClient = &upnp{device.Root, service, transport}
 					}
 				} else {
 					if service := checkIGDv1(sc); service != nil {
-						upnpClient = &upnp{device.Root, service}
+						upnpClient = &upnp{device.Root, service, transport}
 					}
 				}
 				if upnpClient == nil {
@@ -0,0 +1,148 @@
+package upnp_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/dragonchain/dragonchain-installer/internal/upnp"
+	"github.com/dragonchain/dragonchain-installer/internal/upnp/fakegateway"
+)
+
+// Start a fake gateway and make the upnp package use it until the returned function is called
+func useGateway(t *testing.T, options fakegateway.Options) (*fakegateway.Server, func()) {
+	gateway, err := fakegateway.NewServer(options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	previous := upnp.DefaultTransport
+	upnp.DefaultTransport = gateway.Transport()
+	return gateway, func() {
+		upnp.DefaultTransport = previous
+		gateway.Close()
+	}
+}
+
+func newGateway(t *testing.T, options fakegateway.Options) *fakegateway.Server {
+	gateway, err := fakegateway.NewServer(options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return gateway
+}
+
+func TestPrefersIGDv2(t *testing.T) {
+	gateway, done := useGateway(t, fakegateway.Options{Version: 1, NATEnabled: true, ExternalIP: "203.0.113.1"})
+	defer done()
+	v2 := newGateway(t, fakegateway.Options{Version: 2, NATEnabled: true, ExternalIP: "203.0.113.2"})
+	defer v2.Close()
+	gateway.AddPeer(v2)
+
+	ip, err := upnp.GetExternalIPAddress(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ip != "203.0.113.2" {
+		t.Errorf("Expected the IGDv2 gateway's address 203.0.113.2, got %s", ip)
+	}
+}
+
+func TestFallsBackToIGDv1(t *testing.T) {
+	_, done := useGateway(t, fakegateway.Options{Version: 1, NATEnabled: true, ExternalIP: "203.0.113.1"})
+	defer done()
+
+	ip, err := upnp.GetExternalIPAddress(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ip != "203.0.113.1" {
+		t.Errorf("Expected 203.0.113.1, got %s", ip)
+	}
+}
+
+func TestConnectionServices(t *testing.T) {
+	cases := []struct {
+		name    string
+		options fakegateway.Options
+	}{
+		{"IGDv1 IP", fakegateway.Options{Version: 1}},
+		{"IGDv1 PPP", fakegateway.Options{Version: 1, PPP: true}},
+		{"IGDv2 IP", fakegateway.Options{Version: 2}},
+		{"IGDv2 PPP", fakegateway.Options{Version: 2, PPP: true}},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			c.options.NATEnabled = true
+			gateway, done := useGateway(t, c.options)
+			defer done()
+
+			if err := upnp.AddUPNPPortMapping(context.Background(), 30000); err != nil {
+				t.Fatal(err)
+			}
+			mappings := gateway.Mappings()
+			if len(mappings) != 1 {
+				t.Fatalf("Expected 1 mapping, got %v", mappings)
+			}
+			if mappings[0].ExternalPort != 30000 || mappings[0].InternalPort != 30000 || mappings[0].Protocol != "TCP" || mappings[0].InternalClient != "127.0.0.1" {
+				t.Errorf("Unexpected mapping %+v", mappings[0])
+			}
+		})
+	}
+}
+
+func TestSkipsGatewayWithoutNAT(t *testing.T) {
+	gateway, done := useGateway(t, fakegateway.Options{Version: 2, NATEnabled: false, ExternalIP: "203.0.113.2"})
+	defer done()
+
+	if _, err := upnp.GetExternalIPAddress(context.Background()); err == nil {
+		t.Error("Expected no router to be found when NAT is disabled")
+	}
+
+	v1 := newGateway(t, fakegateway.Options{Version: 1, NATEnabled: true, ExternalIP: "203.0.113.1"})
+	defer v1.Close()
+	gateway.AddPeer(v1)
+	ip, err := upnp.GetExternalIPAddress(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ip != "203.0.113.1" {
+		t.Errorf("Expected the gateway with NAT enabled (203.0.113.1), got %s", ip)
+	}
+}
+
+func TestSilentGateway(t *testing.T) {
+	gateway, done := useGateway(t, fakegateway.Options{Version: 2, NATEnabled: true})
+	defer done()
+	gateway.SetSilent(true)
+
+	start := time.Now()
+	if err := upnp.AddUPNPPortMapping(context.Background(), 30000); err == nil {
+		t.Error("Expected an error when no router answers")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Discovery took %s, longer than the search timeout allows", elapsed)
+	}
+	if gateway.Searches() == 0 {
+		t.Error("Expected the gateway to have been searched for")
+	}
+	if len(gateway.Calls()) != 0 {
+		t.Errorf("Expected no SOAP calls, got %v", gateway.Calls())
+	}
+}
+
+func TestSlowGatewayTimesOut(t *testing.T) {
+	gateway, done := useGateway(t, fakegateway.Options{Version: 2, NATEnabled: true})
+	defer done()
+	gateway.SetDelay(2 * time.Second)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	err := upnp.AddUPNPPortMapping(ctx, 30000)
+	if err != context.DeadlineExceeded {
+		t.Errorf("Expected the context deadline to be exceeded, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Returned after %s instead of at the deadline", elapsed)
+	}
+}
@@ -0,0 +1,552 @@
+// Package fakegateway is an in-process UPnP internet gateway (SSDP discovery and SOAP control), for exercising the upnp
+// package's discovery and port mapping code without a real router.
+// Set upnp.DefaultTransport to Server.Transport() to use it
+package fakegateway
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dragonchain/dragonchain-installer/internal/upnp"
+)
+
+// Options describe the kind of gateway to emulate
+type Options struct {
+	// IGD version of the device (1 or 2)
+	Version int
+	// Offer a WANPPPConnection service instead of WANIPConnection
+	PPP bool
+	// Whether GetNATRSIPStatus reports NAT as enabled (gateways without NAT are ignored by discovery)
+	NATEnabled bool
+	// Address returned by GetExternalIPAddress
+	ExternalIP string
+	// Reject mappings with a finite lease, like many IGDv1 routers do
+	PermanentLeasesOnly bool
+	// Offer a WANIPv6FirewallControl service (IGDv2 only)
+	IPv6Firewall bool
+	// Reported by GetFirewallStatus
+	FirewallEnabled       bool
+	InboundPinholeAllowed bool
+}
+
+// Mapping is a port mapping on the fake gateway
+type Mapping struct {
+	ExternalPort   int
+	Protocol       string
+	InternalPort   int
+	InternalClient string
+	Enabled        bool
+	Description    string
+	LeaseDuration  int
+}
+
+// Pinhole is an ipv6 firewall pinhole on the fake gateway
+type Pinhole struct {
+	UniqueID       int
+	RemoteHost     string
+	RemotePort     int
+	InternalClient string
+	InternalPort   int
+	Protocol       int
+	LeaseTime      int
+}
+
+type upnpError struct {
+	code        int
+	description string
+}
+
+// Server is a fake UPnP gateway, listening for SSDP searches on a local udp port and SOAP requests on a local http server
+type Server struct {
+	// SSDPAddress is the host:port that searches should be sent to
+	SSDPAddress string
+	// Location is the url of the root device description
+	Location string
+
+	options      Options
+	ssdp         net.PacketConn
+	http         *httptest.Server
+	lock         sync.Mutex
+	silent       bool
+	delay        time.Duration
+	errors       map[string]upnpError
+	mappings     []Mapping
+	pinholes     []Pinhole
+	nextPinhole  int
+	calls        []string
+	searchesSeen int
+	peers        []*Server
+}
+
+// NewServer starts a fake gateway described by options
+func NewServer(options Options) (*Server, error) {
+	if options.Version != 1 && options.Version != 2 {
+		return nil, errors.New("Unsupported IGD version " + strconv.Itoa(options.Version))
+	}
+	if options.ExternalIP == "" {
+		options.ExternalIP = "203.0.113.1"
+	}
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	fake := &Server{
+		SSDPAddress: conn.LocalAddr().String(),
+		options:     options,
+		ssdp:        conn,
+		errors:      map[string]upnpError{},
+		nextPinhole: 1,
+	}
+	fake.http = httptest.NewServer(http.HandlerFunc(fake.handleHTTP))
+	fake.Location = fake.http.URL + "/rootDesc.xml"
+	go fake.serveSSDP()
+	return fake, nil
+}
+
+// Close shuts down the gateway
+func (fake *Server) Close() {
+	fake.ssdp.Close()
+	fake.http.Close()
+}
+
+// Transport returns a upnp transport which finds (only) this gateway, and sees this machine as 127.0.0.1 on the gateway's network
+func (fake *Server) Transport() *upnp.Transport {
+	return &upnp.Transport{
+		SSDPAddress:   fake.SSDPAddress,
+		SearchTimeout: 200 * time.Millisecond,
+		HTTPClient:    http.Client{Timeout: time.Second},
+		InterfaceAddrs: func() ([]net.Addr, error) {
+			return []net.Addr{&net.IPNet{IP: net.IPv4(127, 0, 0, 1), Mask: net.CIDRMask(8, 32)}}, nil
+		},
+	}
+}
+
+// SetError makes a SOAP action (i.e. "AddPortMapping") fail with a UPnP error code; code 0 makes it work again
+func (fake *Server) SetError(action string, code int, description string) {
+	fake.lock.Lock()
+	defer fake.lock.Unlock()
+	if code == 0 {
+		delete(fake.errors, action)
+	} else {
+		fake.errors[action] = upnpError{code, description}
+	}
+}
+
+// SetDelay delays every SOAP response, to exercise timeouts
+func (fake *Server) SetDelay(delay time.Duration) {
+	fake.lock.Lock()
+	defer fake.lock.Unlock()
+	fake.delay = delay
+}
+
+// SetSilent makes the gateway ignore SSDP searches, as if it weren't on the network
+func (fake *Server) SetSilent(silent bool) {
+	fake.lock.Lock()
+	defer fake.lock.Unlock()
+	fake.silent = silent
+}
+
+// AddPeer makes this gateway also answer searches for peer, as if both were on the same network (i.e. to check which one discovery picks)
+func (fake *Server) AddPeer(peer *Server) {
+	fake.lock.Lock()
+	defer fake.lock.Unlock()
+	fake.peers = append(fake.peers, peer)
+}
+
+// AddMapping adds a mapping as if another device created it (i.e. to cause a conflict)
+func (fake *Server) AddMapping(mapping Mapping) {
+	fake.lock.Lock()
+	defer fake.lock.Unlock()
+	fake.mappings = append(fake.mappings, mapping)
+}
+
+// Mappings returns the current port mappings
+func (fake *Server) Mappings() []Mapping {
+	fake.lock.Lock()
+	defer fake.lock.Unlock()
+	return append([]Mapping(nil), fake.mappings...)
+}
+
+// Pinholes returns the current ipv6 firewall pinholes
+func (fake *Server) Pinholes() []Pinhole {
+	fake.lock.Lock()
+	defer fake.lock.Unlock()
+	return append([]Pinhole(nil), fake.pinholes...)
+}
+
+// Calls returns the SOAP actions called so far, in order
+func (fake *Server) Calls() []string {
+	fake.lock.Lock()
+	defer fake.lock.Unlock()
+	return append([]string(nil), fake.calls...)
+}
+
+// Searches returns the number of SSDP searches received
+func (fake *Server) Searches() int {
+	fake.lock.Lock()
+	defer fake.lock.Unlock()
+	return fake.searchesSeen
+}
+
+func (fake *Server) deviceType(name string) string {
+	return "urn:schemas-upnp-org:device:" + name + ":" + strconv.Itoa(fake.options.Version)
+}
+
+func (fake *Server) connectionService() string {
+	if fake.options.PPP {
+		return "urn:schemas-upnp-org:service:WANPPPConnection:1"
+	}
+	return "urn:schemas-upnp-org:service:WANIPConnection:" + strconv.Itoa(fake.options.Version)
+}
+
+const firewallService = "urn:schemas-upnp-org:service:WANIPv6FirewallControl:1"
+
+func (fake *Server) hasFirewall() bool {
+	return fake.options.IPv6Firewall && fake.options.Version == 2
+}
+
+// Search targets this gateway answers
+func (fake *Server) searchTargets() []string {
+	targets := []string{"upnp:rootdevice", fake.deviceType("InternetGatewayDevice"), fake.deviceType("WANDevice"), fake.deviceType("WANConnectionDevice"), fake.connectionService()}
+	if fake.hasFirewall() {
+		targets = append(targets, firewallService)
+	}
+	return targets
+}
+
+func (fake *Server) serveSSDP() {
+	buf := make([]byte, 2048)
+	for {
+		n, addr, err := fake.ssdp.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		request, err := http.ReadRequest(bufio.NewReader(bytes.NewReader(buf[:n])))
+		if err != nil || request.Method != "M-SEARCH" {
+			continue
+		}
+		fake.lock.Lock()
+		fake.searchesSeen++
+		gateways := append([]*Server{fake}, fake.peers...)
+		fake.lock.Unlock()
+		for _, gateway := range gateways {
+			for _, response := range gateway.searchResponses(request.Header.Get("ST")) {
+				fake.ssdp.WriteTo([]byte(response), addr)
+			}
+		}
+	}
+}
+
+// Responses of this gateway to a search for searchTarget
+func (fake *Server) searchResponses(searchTarget string) []string {
+	fake.lock.Lock()
+	silent := fake.silent
+	fake.lock.Unlock()
+	if silent {
+		return nil
+	}
+	var responses []string
+	for _, target := range fake.searchTargets() {
+		if searchTarget == target || searchTarget == "ssdp:all" {
+			responses = append(responses, "HTTP/1.1 200 OK\r\nCACHE-CONTROL: max-age=120\r\nEXT:\r\nSERVER: fakegateway UPnP/1.1\r\n"+
+				"ST: "+target+"\r\nUSN: uuid:"+fake.Location+"::"+target+"\r\nLOCATION: "+fake.Location+"\r\n\r\n")
+		}
+	}
+	return responses
+}
+
+func (fake *Server) handleHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method == "GET" && r.URL.Path == "/rootDesc.xml" {
+		w.Header().Set("Content-Type", "text/xml")
+		io.WriteString(w, fake.description())
+		return
+	}
+	if r.Method == "POST" && strings.HasPrefix(r.URL.Path, "/ctl/") {
+		fake.handleSOAP(w, r)
+		return
+	}
+	http.NotFound(w, r)
+}
+
+func service(serviceType string, name string) string {
+	return "<service><serviceType>" + serviceType + "</serviceType><serviceId>urn:upnp-org:serviceId:" + name + "</serviceId>" +
+		"<controlURL>/ctl/" + name + "</controlURL><eventSubURL>/evt/" + name + "</eventSubURL><SCPDURL>/" + name + ".xml</SCPDURL></service>"
+}
+
+func (fake *Server) description() string {
+	services := service(fake.connectionService(), "WANConn1")
+	if fake.hasFirewall() {
+		services += service(firewallService, "WANIPv6Firewall1")
+	}
+	return `<?xml version="1.0"?>
+<root xmlns="urn:schemas-upnp-org:device-1-0">
+<specVersion><major>1</major><minor>` + strconv.Itoa(fake.options.Version-1) + `</minor></specVersion>
+<device>
+<deviceType>` + fake.deviceType("InternetGatewayDevice") + `</deviceType>
+<friendlyName>Fake gateway</friendlyName>
+<UDN>uuid:fakegateway-igd</UDN>
+<deviceList><device>
+<deviceType>` + fake.deviceType("WANDevice") + `</deviceType>
+<UDN>uuid:fakegateway-wan</UDN>
+<deviceList><device>
+<deviceType>` + fake.deviceType("WANConnectionDevice") + `</deviceType>
+<UDN>uuid:fakegateway-wanconn</UDN>
+<serviceList>` + services + `</serviceList>
+</device></deviceList>
+</device></deviceList>
+</device>
+</root>`
+}
+
+// Read the action name and its arguments from a SOAP request body
+func parseSOAPRequest(body io.Reader) (string, map[string]string, error) {
+	decoder := xml.NewDecoder(body)
+	action := ""
+	arguments := map[string]string{}
+	depth := 0
+	argument := ""
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", nil, err
+		}
+		switch token := token.(type) {
+		case xml.StartElement:
+			depth++
+			// Envelope > Body > Action > Argument
+			if depth == 3 {
+				action = token.Name.Local
+			} else if depth == 4 {
+				argument = token.Name.Local
+				arguments[argument] = ""
+			}
+		case xml.CharData:
+			if depth == 4 {
+				arguments[argument] += string(token)
+			}
+		case xml.EndElement:
+			depth--
+		}
+	}
+	if action == "" {
+		return "", nil, errors.New("No action in SOAP request")
+	}
+	return action, arguments, nil
+}
+
+func writeSOAPResponse(w http.ResponseWriter, serviceType string, action string, results [][2]string) {
+	var body strings.Builder
+	body.WriteString(`<?xml version="1.0"?><s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/" s:encodingStyle="http://schemas.xmlsoap.org/soap/encoding/"><s:Body>`)
+	body.WriteString(`<u:` + action + `Response xmlns:u="` + serviceType + `">`)
+	for _, result := range results {
+		body.WriteString("<" + result[0] + ">")
+		xml.EscapeText(&body, []byte(result[1]))
+		body.WriteString("</" + result[0] + ">")
+	}
+	body.WriteString(`</u:` + action + `Response></s:Body></s:Envelope>`)
+	w.Header().Set("Content-Type", `text/xml; charset="utf-8"`)
+	io.WriteString(w, body.String())
+}
+
+// UPnP errors are SOAP faults with status 500
+func writeSOAPFault(w http.ResponseWriter, upnpErr upnpError) {
+	w.Header().Set("Content-Type", `text/xml; charset="utf-8"`)
+	w.WriteHeader(500)
+	io.WriteString(w, `<?xml version="1.0"?><s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/" s:encodingStyle="http://schemas.xmlsoap.org/soap/encoding/"><s:Body>`+
+		`<s:Fault><faultcode>s:Client</faultcode><faultstring>UPnPError</faultstring><detail><UPnPError xmlns="urn:schemas-upnp-org:control-1-0">`+
+		`<errorCode>`+strconv.Itoa(upnpErr.code)+`</errorCode><errorDescription>`+upnpErr.description+`</errorDescription></UPnPError></detail></s:Fault></s:Body></s:Envelope>`)
+}
+
+func boolString(value bool) string {
+	if value {
+		return "1"
+	}
+	return "0"
+}
+
+func (fake *Server) handleSOAP(w http.ResponseWriter, r *http.Request) {
+	serviceType := fake.connectionService()
+	if r.URL.Path == "/ctl/WANIPv6Firewall1" {
+		serviceType = firewallService
+	}
+	action, arguments, err := parseSOAPRequest(r.Body)
+	if err != nil {
+		writeSOAPFault(w, upnpError{401, "Invalid Action"})
+		return
+	}
+	fake.lock.Lock()
+	delay := fake.delay
+	fake.lock.Unlock()
+	if delay > 0 {
+		time.Sleep(delay)
+	}
+	fake.lock.Lock()
+	defer fake.lock.Unlock()
+	fake.calls = append(fake.calls, action)
+	if upnpErr, ok := fake.errors[action]; ok {
+		writeSOAPFault(w, upnpErr)
+		return
+	}
+	results, upnpErr := fake.perform(serviceType, action, arguments)
+	if upnpErr != nil {
+		writeSOAPFault(w, *upnpErr)
+		return
+	}
+	writeSOAPResponse(w, serviceType, action, results)
+}
+
+func (fake *Server) findMapping(port string, protocol string) int {
+	for i, mapping := range fake.mappings {
+		if strconv.Itoa(mapping.ExternalPort) == port && mapping.Protocol == protocol {
+			return i
+		}
+	}
+	return -1
+}
+
+func mappingResults(mapping Mapping) [][2]string {
+	return [][2]string{
+		{"NewInternalPort", strconv.Itoa(mapping.InternalPort)},
+		{"NewInternalClient", mapping.InternalClient},
+		{"NewEnabled", boolString(mapping.Enabled)},
+		{"NewPortMappingDescription", mapping.Description},
+		{"NewLeaseDuration", strconv.Itoa(mapping.LeaseDuration)},
+	}
+}
+
+// Perform an action, returning its results or a UPnP error
+func (fake *Server) perform(serviceType string, action string, arguments map[string]string) ([][2]string, *upnpError) {
+	if serviceType == firewallService {
+		return fake.performFirewall(action, arguments)
+	}
+	switch action {
+	case "GetNATRSIPStatus":
+		return [][2]string{{"NewRSIPAvailable", "0"}, {"NewNATEnabled", boolString(fake.options.NATEnabled)}}, nil
+	case "GetExternalIPAddress":
+		return [][2]string{{"NewExternalIPAddress", fake.options.ExternalIP}}, nil
+	case "AddPortMapping":
+		lease, _ := strconv.Atoi(arguments["NewLeaseDuration"])
+		if lease != 0 && fake.options.PermanentLeasesOnly {
+			return nil, &upnpError{725, "OnlyPermanentLeasesSupported"}
+		}
+		externalPort, _ := strconv.Atoi(arguments["NewExternalPort"])
+		internalPort, _ := strconv.Atoi(arguments["NewInternalPort"])
+		mapping := Mapping{
+			ExternalPort:   externalPort,
+			Protocol:       arguments["NewProtocol"],
+			InternalPort:   internalPort,
+			InternalClient: arguments["NewInternalClient"],
+			Enabled:        arguments["NewEnabled"] == "1",
+			Description:    arguments["NewPortMappingDescription"],
+			LeaseDuration:  lease,
+		}
+		if i := fake.findMapping(arguments["NewExternalPort"], arguments["NewProtocol"]); i >= 0 {
+			if fake.mappings[i].InternalClient != mapping.InternalClient {
+				return nil, &upnpError{718, "ConflictInMappingEntry"}
+			}
+			fake.mappings[i] = mapping
+		} else {
+			fake.mappings = append(fake.mappings, mapping)
+		}
+		return nil, nil
+	case "DeletePortMapping":
+		i := fake.findMapping(arguments["NewExternalPort"], arguments["NewProtocol"])
+		if i < 0 {
+			return nil, &upnpError{714, "NoSuchEntryInArray"}
+		}
+		fake.mappings = append(fake.mappings[:i], fake.mappings[i+1:]...)
+		return nil, nil
+	case "GetSpecificPortMappingEntry":
+		i := fake.findMapping(arguments["NewExternalPort"], arguments["NewProtocol"])
+		if i < 0 {
+			return nil, &upnpError{714, "NoSuchEntryInArray"}
+		}
+		return mappingResults(fake.mappings[i]), nil
+	case "GetGenericPortMappingEntry":
+		i, err := strconv.Atoi(arguments["NewPortMappingIndex"])
+		if err != nil || i < 0 || i >= len(fake.mappings) {
+			return nil, &upnpError{713, "SpecifiedArrayIndexInvalid"}
+		}
+		mapping := fake.mappings[i]
+		return append([][2]string{
+			{"NewRemoteHost", ""},
+			{"NewExternalPort", strconv.Itoa(mapping.ExternalPort)},
+			{"NewProtocol", mapping.Protocol},
+		}, mappingResults(mapping)...), nil
+	}
+	return nil, &upnpError{401, "Invalid Action"}
+}
+
+func (fake *Server) performFirewall(action string, arguments map[string]string) ([][2]string, *upnpError) {
+	findPinhole := func(id string) int {
+		for i, pinhole := range fake.pinholes {
+			if strconv.Itoa(pinhole.UniqueID) == id {
+				return i
+			}
+		}
+		return -1
+	}
+	switch action {
+	case "GetFirewallStatus":
+		return [][2]string{{"FirewallEnabled", boolString(fake.options.FirewallEnabled)}, {"InboundPinholeAllowed", boolString(fake.options.InboundPinholeAllowed)}}, nil
+	case "AddPinhole":
+		if !fake.options.InboundPinholeAllowed {
+			return nil, &upnpError{606, "Action not authorized"}
+		}
+		remotePort, _ := strconv.Atoi(arguments["RemotePort"])
+		internalPort, _ := strconv.Atoi(arguments["InternalPort"])
+		protocol, _ := strconv.Atoi(arguments["Protocol"])
+		lease, _ := strconv.Atoi(arguments["LeaseTime"])
+		if lease < 1 || lease > 86400 {
+			return nil, &upnpError{402, "Invalid Args"}
+		}
+		pinhole := Pinhole{
+			RemoteHost:     arguments["RemoteHost"],
+			RemotePort:     remotePort,
+			InternalClient: arguments["InternalClient"],
+			InternalPort:   internalPort,
+			Protocol:       protocol,
+			LeaseTime:      lease,
+		}
+		// Like most routers, refresh an identical pinhole instead of adding another
+		for i, existing := range fake.pinholes {
+			if existing.RemoteHost == pinhole.RemoteHost && existing.RemotePort == pinhole.RemotePort && existing.InternalClient == pinhole.InternalClient &&
+				existing.InternalPort == pinhole.InternalPort && existing.Protocol == pinhole.Protocol {
+				fake.pinholes[i].LeaseTime = lease
+				return [][2]string{{"UniqueID", strconv.Itoa(existing.UniqueID)}}, nil
+			}
+		}
+		pinhole.UniqueID = fake.nextPinhole
+		fake.nextPinhole++
+		fake.pinholes = append(fake.pinholes, pinhole)
+		return [][2]string{{"UniqueID", strconv.Itoa(pinhole.UniqueID)}}, nil
+	case "UpdatePinhole":
+		i := findPinhole(arguments["UniqueID"])
+		if i < 0 {
+			return nil, &upnpError{704, "NoSuchEntry"}
+		}
+		fake.pinholes[i].LeaseTime, _ = strconv.Atoi(arguments["NewLeaseTime"])
+		return nil, nil
+	case "DeletePinhole":
+		i := findPinhole(arguments["UniqueID"])
+		if i < 0 {
+			return nil, &upnpError{704, "NoSuchEntry"}
+		}
+		fake.pinholes = append(fake.pinholes[:i], fake.pinholes[i+1:]...)
+		return nil, nil
+	}
+	return nil, &upnpError{401, "Invalid Action"}
+}
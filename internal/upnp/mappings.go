@@ -0,0 +1,203 @@
+package upnp
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// LeaseDuration of port mappings created by the installer (0 for a permanent mapping).
+// A mapping with a lease disappears unless it is recreated with RenewPortMapping before it expires, so the installer only
+// uses a lease while the watch command is running to renew it
+var LeaseDuration time.Duration
+
+// WatchLeaseDuration is used instead of LeaseDuration by the watch command, which renews the mapping
+var WatchLeaseDuration = 24 * time.Hour
+
+// Description given to the port mappings created by the installer, used to find them again
+const mappingDescription = "dragonchain"
+
+// Held while changing port mappings, which takes several requests (i.e. deleting and recreating a mapping). Renewing a mapping
+// and repairing it (i.e. the watchdog) can run at the same time, and one's delete mustn't remove the other's new mapping.
+// It is held by the request itself rather than its caller, so an abandoned request still finishes before the next one starts
+var mappingLock sync.Mutex
+
+// Routers report an error once the index is past the end of the list; this limits how long a misbehaving router can keep us listing
+const maxMappingEntries = 1024
+
+// Mapping is a TCP port mapping on the router
+type Mapping struct {
+	ExternalPort   int
+	InternalClient string
+	InternalPort   int
+	Enabled        bool
+	Description    string
+	// Remaining lease of the mapping (0 if permanent)
+	LeaseDuration time.Duration
+}
+
+// ConflictError is returned when the external port is already forwarded to another machine
+type ConflictError struct {
+	Mapping Mapping
+}
+
+func (err *ConflictError) Error() string {
+	return "Port " + strconv.Itoa(err.Mapping.ExternalPort) + " is already forwarded to " + err.Mapping.InternalClient + ":" + strconv.Itoa(err.Mapping.InternalPort) +
+		" (" + err.Mapping.Description + "). Use a different port for the chain, or remove that port forward from the router"
+}
+
+// Get the TCP mapping for an external port, or nil if there isn't one
+func (upnp *upnp) getPortMapping(port int) (*Mapping, error) {
+	internalPort, internalClient, enabled, description, lease, err := upnp.client.GetSpecificPortMappingEntry("", uint16(port), "TCP")
+	if err != nil {
+		if isUPnPError(err) {
+			// The router answers with an error (NoSuchEntryInArray) when there is no mapping
+			return nil, nil
+		}
+		return nil, errors.New("Could not get port mapping from router:\n" + err.Error())
+	}
+	return &Mapping{
+		ExternalPort:   port,
+		InternalClient: internalClient,
+		InternalPort:   int(internalPort),
+		Enabled:        enabled,
+		Description:    description,
+		LeaseDuration:  time.Duration(lease) * time.Second,
+	}, nil
+}
+
+// Get every TCP mapping on the router whose description is the installer's
+func (upnp *upnp) listPortMappings() ([]Mapping, error) {
+	mappings := []Mapping{}
+	for i := 0; i < maxMappingEntries; i++ {
+		_, externalPort, protocol, internalPort, internalClient, enabled, description, lease, err := upnp.client.GetGenericPortMappingEntry(uint16(i))
+		if err != nil {
+			if isUPnPError(err) {
+				// Past the end of the list (SpecifiedArrayIndexInvalid)
+				break
+			}
+			return nil, errors.New("Could not list port mappings from router:\n" + err.Error())
+		}
+		if protocol != "TCP" || description != mappingDescription {
+			continue
+		}
+		mappings = append(mappings, Mapping{
+			ExternalPort:   int(externalPort),
+			InternalClient: internalClient,
+			InternalPort:   int(internalPort),
+			Enabled:        enabled,
+			Description:    description,
+			LeaseDuration:  time.Duration(lease) * time.Second,
+		})
+	}
+	return mappings, nil
+}
+
+// ListPortMappings lists the dragonchain port mappings on the UPNP router, along with the mappings for ports (whatever their
+// description), since some routers don't support listing all mappings
+func ListPortMappings(ctx context.Context, ports []int) ([]Mapping, error) {
+	var mappings []Mapping
+	transport := DefaultTransport
+	err := withContext(ctx, func() error {
+		upnp, err := transport.discover()
+		if err != nil {
+			return err
+		}
+		listed, err := upnp.listPortMappings()
+		if err != nil {
+			return err
+		}
+		found := map[int]bool{}
+		for _, mapping := range listed {
+			found[mapping.ExternalPort] = true
+		}
+		for _, port := range ports {
+			if found[port] {
+				continue
+			}
+			mapping, err := upnp.getPortMapping(port)
+			if err != nil {
+				return err
+			}
+			if mapping != nil {
+				listed = append(listed, *mapping)
+				found[port] = true
+			}
+		}
+		mappings = listed
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return mappings, nil
+}
+
+// RemovePortMapping removes the mapping for an external port from the UPNP router.
+// Unless force is set, a mapping to another machine is not removed and a *ConflictError is returned instead
+func RemovePortMapping(ctx context.Context, port int, force bool) error {
+	transport := DefaultTransport
+	return withContext(ctx, func() error {
+		mappingLock.Lock()
+		defer mappingLock.Unlock()
+		upnp, err := transport.discover()
+		if err != nil {
+			return err
+		}
+		existing, err := upnp.getPortMapping(port)
+		if err != nil {
+			return err
+		}
+		if existing == nil {
+			return errors.New("Port " + strconv.Itoa(port) + " is not forwarded on the router")
+		}
+		if !force {
+			ip, err := upnp.internalAddress()
+			if err != nil {
+				return err
+			}
+			if existing.InternalClient != ip.String() {
+				return &ConflictError{Mapping: *existing}
+			}
+		}
+		if err := upnp.client.DeletePortMapping("", uint16(port), "TCP"); err != nil {
+			return errors.New("Router refused to remove port mapping:\n" + err.Error())
+		}
+		return nil
+	})
+}
+
+// RenewPortMapping recreates the port mapping every half lease so that it doesn't expire, until ctx is done.
+// Failures are passed to onError and retried sooner. Does nothing for permanent mappings
+func RenewPortMapping(ctx context.Context, port int, onError func(err error)) {
+	renew(ctx, LeaseDuration, func() error {
+		return AddUPNPPortMapping(ctx, port)
+	}, onError)
+}
+
+// Call add every half lease until ctx is done, retrying failures sooner
+func renew(ctx context.Context, lease time.Duration, add func() error, onError func(err error)) {
+	if lease <= 0 {
+		return
+	}
+	for {
+		wait := lease / 2
+		if err := add(); err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			onError(err)
+			wait = time.Minute
+			if wait > lease/4 {
+				wait = lease / 4
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(wait):
+		}
+	}
+}
@@ -0,0 +1,189 @@
+package upnp_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/dragonchain/dragonchain-installer/internal/upnp"
+	"github.com/dragonchain/dragonchain-installer/internal/upnp/fakegateway"
+)
+
+func withLease(lease time.Duration) func() {
+	previous := upnp.LeaseDuration
+	upnp.LeaseDuration = lease
+	return func() {
+		upnp.LeaseDuration = previous
+	}
+}
+
+func TestFiniteLease(t *testing.T) {
+	defer withLease(time.Hour)()
+	gateway, done := useGateway(t, fakegateway.Options{Version: 2, NATEnabled: true})
+	defer done()
+
+	if err := upnp.AddUPNPPortMapping(context.Background(), 30000); err != nil {
+		t.Fatal(err)
+	}
+	mappings := gateway.Mappings()
+	if len(mappings) != 1 || mappings[0].LeaseDuration != 3600 {
+		t.Errorf("Expected one mapping with a lease of 3600 seconds, got %+v", mappings)
+	}
+}
+
+func TestFallsBackToPermanentLease(t *testing.T) {
+	defer withLease(time.Hour)()
+	gateway, done := useGateway(t, fakegateway.Options{Version: 1, NATEnabled: true, PermanentLeasesOnly: true})
+	defer done()
+
+	if err := upnp.AddUPNPPortMapping(context.Background(), 30000); err != nil {
+		t.Fatal(err)
+	}
+	mappings := gateway.Mappings()
+	if len(mappings) != 1 || mappings[0].LeaseDuration != 0 {
+		t.Errorf("Expected one permanent mapping, got %+v", mappings)
+	}
+	adds := 0
+	for _, call := range gateway.Calls() {
+		if call == "AddPortMapping" {
+			adds++
+		}
+	}
+	if adds != 2 {
+		t.Errorf("Expected AddPortMapping to be retried once without a lease, got calls %v", gateway.Calls())
+	}
+}
+
+func TestPermanentLease(t *testing.T) {
+	defer withLease(0)()
+	gateway, done := useGateway(t, fakegateway.Options{Version: 1, NATEnabled: true, PermanentLeasesOnly: true})
+	defer done()
+
+	if err := upnp.AddUPNPPortMapping(context.Background(), 30000); err != nil {
+		t.Fatal(err)
+	}
+	mappings := gateway.Mappings()
+	if len(mappings) != 1 || mappings[0].LeaseDuration != 0 {
+		t.Errorf("Expected one permanent mapping, got %+v", mappings)
+	}
+}
+
+func TestRecreatesOwnMapping(t *testing.T) {
+	gateway, done := useGateway(t, fakegateway.Options{Version: 2, NATEnabled: true})
+	defer done()
+
+	for i := 0; i < 2; i++ {
+		if err := upnp.AddUPNPPortMapping(context.Background(), 30000); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if mappings := gateway.Mappings(); len(mappings) != 1 {
+		t.Errorf("Expected the mapping to be replaced, got %+v", mappings)
+	}
+}
+
+func TestConflict(t *testing.T) {
+	gateway, done := useGateway(t, fakegateway.Options{Version: 2, NATEnabled: true})
+	defer done()
+	other := fakegateway.Mapping{ExternalPort: 30000, Protocol: "TCP", InternalPort: 8080, InternalClient: "192.168.1.50", Enabled: true, Description: "game server"}
+	gateway.AddMapping(other)
+
+	err := upnp.AddUPNPPortMapping(context.Background(), 30000)
+	conflict, ok := err.(*upnp.ConflictError)
+	if !ok {
+		t.Fatalf("Expected a *ConflictError, got %v", err)
+	}
+	if conflict.Mapping.InternalClient != "192.168.1.50" || conflict.Mapping.InternalPort != 8080 || conflict.Mapping.Description != "game server" {
+		t.Errorf("Unexpected conflicting mapping %+v", conflict.Mapping)
+	}
+	if mappings := gateway.Mappings(); len(mappings) != 1 || mappings[0] != other {
+		t.Errorf("Expected the other machine's mapping to be left alone, got %+v", mappings)
+	}
+}
+
+func TestListPortMappings(t *testing.T) {
+	gateway, done := useGateway(t, fakegateway.Options{Version: 2, NATEnabled: true})
+	defer done()
+	gateway.AddMapping(fakegateway.Mapping{ExternalPort: 80, Protocol: "TCP", InternalPort: 80, InternalClient: "192.168.1.50", Enabled: true, Description: "web"})
+	gateway.AddMapping(fakegateway.Mapping{ExternalPort: 30001, Protocol: "TCP", InternalPort: 30001, InternalClient: "192.168.1.51", Enabled: true, Description: "other"})
+	gateway.AddMapping(fakegateway.Mapping{ExternalPort: 30002, Protocol: "UDP", InternalPort: 30002, InternalClient: "127.0.0.1", Enabled: true, Description: "dragonchain"})
+	if err := upnp.AddUPNPPortMapping(context.Background(), 30000); err != nil {
+		t.Fatal(err)
+	}
+
+	mappings, err := upnp.ListPortMappings(context.Background(), []int{30000, 30001, 30003})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ports := map[int]upnp.Mapping{}
+	for _, mapping := range mappings {
+		ports[mapping.ExternalPort] = mapping
+	}
+	if len(mappings) != 2 || ports[30000].InternalClient != "127.0.0.1" || ports[30001].InternalClient != "192.168.1.51" {
+		t.Errorf("Expected the dragonchain mapping of port 30000 and the requested port 30001, got %+v", mappings)
+	}
+}
+
+func TestRemovePortMapping(t *testing.T) {
+	gateway, done := useGateway(t, fakegateway.Options{Version: 2, NATEnabled: true})
+	defer done()
+	gateway.AddMapping(fakegateway.Mapping{ExternalPort: 30001, Protocol: "TCP", InternalPort: 30001, InternalClient: "192.168.1.51", Enabled: true, Description: "other"})
+	if err := upnp.AddUPNPPortMapping(context.Background(), 30000); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := upnp.RemovePortMapping(context.Background(), 30000, false); err != nil {
+		t.Fatal(err)
+	}
+	if err := upnp.RemovePortMapping(context.Background(), 30000, false); err == nil {
+		t.Error("Expected an error removing a port which isn't forwarded")
+	}
+	if _, ok := upnp.RemovePortMapping(context.Background(), 30001, false).(*upnp.ConflictError); !ok {
+		t.Error("Expected a *ConflictError removing another machine's mapping without force")
+	}
+	if len(gateway.Mappings()) != 1 {
+		t.Fatalf("Expected only the other machine's mapping to be left, got %+v", gateway.Mappings())
+	}
+	if err := upnp.RemovePortMapping(context.Background(), 30001, true); err != nil {
+		t.Fatal(err)
+	}
+	if len(gateway.Mappings()) != 0 {
+		t.Errorf("Expected no mappings to be left, got %+v", gateway.Mappings())
+	}
+}
+
+func TestConcurrentMappingChanges(t *testing.T) {
+	gateway, done := useGateway(t, fakegateway.Options{Version: 2, NATEnabled: true})
+	defer done()
+	if err := upnp.AddUPNPPortMapping(context.Background(), 30000); err != nil {
+		t.Fatal(err)
+	}
+	// Slow the router down, so that unserialized changes would interleave
+	gateway.SetDelay(20 * time.Millisecond)
+
+	errs := make(chan error, 2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			errs <- upnp.AddUPNPPortMapping(context.Background(), 30000)
+		}()
+	}
+	for i := 0; i < 2; i++ {
+		if err := <-errs; err != nil {
+			t.Fatal(err)
+		}
+	}
+	if mappings := gateway.Mappings(); len(mappings) != 1 {
+		t.Errorf("Expected the mapping to still exist, got %+v", mappings)
+	}
+	var changes []string
+	for _, call := range gateway.Calls() {
+		if call == "DeletePortMapping" || call == "AddPortMapping" {
+			changes = append(changes, call)
+		}
+	}
+	for i := 1; i < len(changes); i++ {
+		if changes[i] == changes[i-1] {
+			t.Fatalf("Expected each delete to be followed by its add, got %v", changes)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package upnp
+
+import (
+	"context"
+	"errors"
+	"net"
+	"time"
+
+	"github.com/huin/goupnp/dcps/internetgateway2"
+)
+
+// PinholeLease of ipv6 firewall pinholes created by the installer (the IGD spec allows at most a day).
+// Pinholes are recreated with RenewIPv6Pinhole before they expire
+var PinholeLease = 24 * time.Hour
+
+// IANA protocol number of TCP, as used by WANIPv6FirewallControl
+const protocolTCP = 6
+
+// Find an IGDv2 router with an ipv6 firewall. Returns nil (without error) if the router has one, but it is disabled
+func (transport *Transport) discoverFirewall() (*internetgateway2.WANIPv6FirewallControl1, error) {
+	devices, err := transport.discoverDevices(internetgateway2.URN_WANIPv6FirewallControl_1)
+	if err != nil {
+		return nil, err
+	}
+	for _, device := range devices {
+		if device.Root == nil {
+			continue
+		}
+		services := device.Root.Device.FindService(internetgateway2.URN_WANIPv6FirewallControl_1)
+		if len(services) == 0 {
+			continue
+		}
+		client := &internetgateway2.WANIPv6FirewallControl1{ServiceClient: transport.serviceClient(device, services[0])}
+		enabled, inboundAllowed, err := client.GetFirewallStatus()
+		if err != nil {
+			continue
+		}
+		if !enabled {
+			return nil, nil
+		}
+		if !inboundAllowed {
+			return nil, errors.New("The router's ipv6 firewall does not allow opening pinholes with upnp. Allow it in the router's settings, or open the port manually")
+		}
+		return client, nil
+	}
+	return nil, errors.New("Couldn't find any UPNP router with an ipv6 firewall (WANIPv6FirewallControl)")
+}
+
+func (transport *Transport) addIPv6Pinhole(port int, address string) error {
+	ip := net.ParseIP(address)
+	if ip == nil || ip.To4() != nil {
+		return errors.New(address + " is not an ipv6 address")
+	}
+	firewall, err := transport.discoverFirewall()
+	if err != nil {
+		return err
+	}
+	if firewall == nil {
+		// Nothing to open; inbound connections are already allowed
+		return nil
+	}
+	// An empty remote host and port 0 allow any remote peer. Routers update the lease of an identical existing pinhole
+	if _, err := firewall.AddPinhole("", 0, ip.String(), uint16(port), protocolTCP, uint32(PinholeLease/time.Second)); err != nil {
+		return errors.New("Router refused to open ipv6 pinhole:\n" + err.Error())
+	}
+	return nil
+}
+
+// AddIPv6Pinhole opens an inbound pinhole for a TCP port to address (this machine's ipv6 address) through the UPNP router's
+// ipv6 firewall, with a lease of PinholeLease. Does nothing if the router's ipv6 firewall is disabled
+func AddIPv6Pinhole(ctx context.Context, port int, address string) error {
+	transport := DefaultTransport
+	return withContext(ctx, func() error {
+		return transport.addIPv6Pinhole(port, address)
+	})
+}
+
+// RenewIPv6Pinhole recreates the pinhole every half lease so that it doesn't expire, until ctx is done.
+// Failures are passed to onError and retried sooner
+func RenewIPv6Pinhole(ctx context.Context, port int, address string, onError func(err error)) {
+	renew(ctx, PinholeLease, func() error {
+		return AddIPv6Pinhole(ctx, port, address)
+	}, onError)
+}
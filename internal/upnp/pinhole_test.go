@@ -0,0 +1,69 @@
+package upnp_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/dragonchain/dragonchain-installer/internal/upnp"
+	"github.com/dragonchain/dragonchain-installer/internal/upnp/fakegateway"
+)
+
+func TestAddIPv6Pinhole(t *testing.T) {
+	gateway, done := useGateway(t, fakegateway.Options{Version: 2, NATEnabled: true, IPv6Firewall: true, FirewallEnabled: true, InboundPinholeAllowed: true})
+	defer done()
+
+	for i := 0; i < 2; i++ {
+		if err := upnp.AddIPv6Pinhole(context.Background(), 30000, "2001:db8::2"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	pinholes := gateway.Pinholes()
+	if len(pinholes) != 1 {
+		t.Fatalf("Expected the pinhole to be refreshed rather than added twice, got %+v", pinholes)
+	}
+	pinhole := pinholes[0]
+	if pinhole.InternalClient != "2001:db8::2" || pinhole.InternalPort != 30000 || pinhole.Protocol != 6 || pinhole.RemoteHost != "" || pinhole.RemotePort != 0 {
+		t.Errorf("Unexpected pinhole %+v", pinhole)
+	}
+	if pinhole.LeaseTime != int(upnp.PinholeLease.Seconds()) {
+		t.Errorf("Expected a lease of %d seconds, got %d", int(upnp.PinholeLease.Seconds()), pinhole.LeaseTime)
+	}
+}
+
+func TestIPv6FirewallDisabled(t *testing.T) {
+	gateway, done := useGateway(t, fakegateway.Options{Version: 2, NATEnabled: true, IPv6Firewall: true, FirewallEnabled: false})
+	defer done()
+
+	if err := upnp.AddIPv6Pinhole(context.Background(), 30000, "2001:db8::2"); err != nil {
+		t.Fatal(err)
+	}
+	if len(gateway.Pinholes()) != 0 {
+		t.Errorf("Expected no pinhole with the firewall disabled, got %+v", gateway.Pinholes())
+	}
+}
+
+func TestIPv6PinholeRefused(t *testing.T) {
+	cases := []struct {
+		name    string
+		options fakegateway.Options
+		address string
+	}{
+		{"inbound pinholes not allowed", fakegateway.Options{Version: 2, NATEnabled: true, IPv6Firewall: true, FirewallEnabled: true}, "2001:db8::2"},
+		{"no firewall service", fakegateway.Options{Version: 2, NATEnabled: true}, "2001:db8::2"},
+		{"IGDv1", fakegateway.Options{Version: 1, NATEnabled: true, IPv6Firewall: true, FirewallEnabled: true, InboundPinholeAllowed: true}, "2001:db8::2"},
+		{"ipv4 address", fakegateway.Options{Version: 2, NATEnabled: true, IPv6Firewall: true, FirewallEnabled: true, InboundPinholeAllowed: true}, "192.168.1.2"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			gateway, done := useGateway(t, c.options)
+			defer done()
+
+			if err := upnp.AddIPv6Pinhole(context.Background(), 30000, c.address); err == nil {
+				t.Error("Expected an error")
+			}
+			if len(gateway.Pinholes()) != 0 {
+				t.Errorf("Expected no pinhole, got %+v", gateway.Pinholes())
+			}
+		})
+	}
+}
@@ -0,0 +1,137 @@
+package upnp
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/huin/goupnp"
+	"github.com/huin/goupnp/httpu"
+	"github.com/huin/goupnp/soap"
+	"golang.org/x/net/html/charset"
+)
+
+// Transport is how routers are found and talked to
+type Transport struct {
+	// Address (host:port) that SSDP searches are sent to
+	SSDPAddress string
+	// How long to wait for routers to answer a search
+	SearchTimeout time.Duration
+	// Client for fetching device descriptions and making SOAP requests (its timeout applies to each request)
+	HTTPClient http.Client
+	// Gets the addresses of this machine's network interfaces, to find the one on the router's network
+	InterfaceAddrs func() ([]net.Addr, error)
+}
+
+// DefaultTransport searches the local network with SSDP multicast, and is used by all of the functions of this package
+// (replace it to use a fake gateway)
+var DefaultTransport = &Transport{
+	SSDPAddress:    "239.255.255.250:1900",
+	SearchTimeout:  2 * time.Second,
+	HTTPClient:     http.Client{Timeout: 3 * time.Second},
+	InterfaceAddrs: net.InterfaceAddrs,
+}
+
+// Whether err is an error response from the router (as opposed to failing to talk to it). Routers send UPnP errors as SOAP
+// faults with status 500, which goupnp reports without the fault details
+func isUPnPError(err error) bool {
+	if _, fault := err.(*soap.SOAPFaultError); fault {
+		return true
+	}
+	return strings.Contains(err.Error(), "SOAP request got HTTP 500")
+}
+
+// Search for devices with searchTarget (a device or service URN) and fetch their descriptions
+func (transport *Transport) discoverDevices(searchTarget string) ([]goupnp.MaybeRootDevice, error) {
+	client, err := httpu.NewHTTPUClient()
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+	maxWait := int(transport.SearchTimeout / time.Second)
+	if maxWait < 1 {
+		maxWait = 1
+	}
+	request := &http.Request{
+		Method: "M-SEARCH",
+		Host:   transport.SSDPAddress,
+		URL:    &url.URL{Opaque: "*"},
+		Header: http.Header{
+			// Set directly so they aren't title-cased; SSDP headers are case sensitive for some devices
+			"HOST": []string{transport.SSDPAddress},
+			"MX":   []string{strconv.Itoa(maxWait)},
+			"MAN":  []string{`"ssdp:discover"`},
+			"ST":   []string{searchTarget},
+		},
+	}
+	responses, err := client.Do(request, transport.SearchTimeout+100*time.Millisecond, 3)
+	if err != nil {
+		return nil, err
+	}
+	seen := map[string]bool{}
+	var devices []goupnp.MaybeRootDevice
+	for _, response := range responses {
+		if response.StatusCode != 200 || response.Header.Get("ST") != searchTarget {
+			continue
+		}
+		location, err := response.Location()
+		if err != nil || seen[location.String()] {
+			continue
+		}
+		// Devices answer each of the repeated searches
+		seen[location.String()] = true
+		device := goupnp.MaybeRootDevice{Location: location}
+		device.Root, device.Err = transport.deviceByURL(location)
+		devices = append(devices, device)
+	}
+	return devices, nil
+}
+
+// Fetch a device description (like goupnp.DeviceByURL, but with the transport's client)
+func (transport *Transport) deviceByURL(location *url.URL) (*goupnp.RootDevice, error) {
+	resp, err := transport.HTTPClient.Get(location.String())
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return nil, errors.New("Got status " + resp.Status + " requesting device description " + location.String())
+	}
+	var body bytes.Buffer
+	if _, err := body.ReadFrom(resp.Body); err != nil {
+		return nil, err
+	}
+	root := new(goupnp.RootDevice)
+	decoder := xml.NewDecoder(&body)
+	decoder.DefaultSpace = goupnp.DeviceXMLNamespace
+	decoder.CharsetReader = charset.NewReaderLabel
+	if err := decoder.Decode(root); err != nil {
+		return nil, errors.New("Could not parse device description " + location.String() + ":\n" + err.Error())
+	}
+	urlBase := location
+	if root.URLBaseStr != "" {
+		if urlBase, err = url.Parse(root.URLBaseStr); err != nil {
+			return nil, errors.New("Could not parse device url base " + root.URLBaseStr + ":\n" + err.Error())
+		}
+	}
+	root.SetURLBase(urlBase)
+	return root, nil
+}
+
+// Create a SOAP client for a service of a discovered device, using the transport's http client
+func (transport *Transport) serviceClient(device goupnp.MaybeRootDevice, service *goupnp.Service) goupnp.ServiceClient {
+	sc := goupnp.ServiceClient{
+		SOAPClient: service.NewSOAPClient(),
+		RootDevice: device.Root,
+		Location:   device.Location,
+		Service:    service,
+	}
+	sc.SOAPClient.HTTPClient = transport.HTTPClient
+	return sc
+}
@@ -0,0 +1,215 @@
+package verify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dragonchain/dragonchain-installer/internal/chainapi"
+	"github.com/dragonchain/dragonchain-installer/internal/configuration"
+	"github.com/dragonchain/dragonchain-installer/internal/interrupt"
+	"github.com/dragonchain/dragonchain-installer/internal/wait"
+)
+
+// Stage is a completed step of verifying a chain, and how long it took
+type Stage struct {
+	Name     string
+	Duration time.Duration
+}
+
+// Options for verifying a chain
+type Options struct {
+	// How long to wait for any single stage to complete
+	StageTimeout time.Duration
+	// Also deploy and invoke a smart contract (level 1 only)
+	SmartContract bool
+	// Image (and command) of the smart contract to deploy. The command should echo its input
+	ContractImage string
+	ContractCmd   string
+}
+
+type verifier struct {
+	client   *chainapi.Client
+	options  Options
+	stages   []Stage
+	cleanups []func() error
+}
+
+// Run a stage, recording how long it took
+func (v *verifier) stage(name string, fn func() error) error {
+	fmt.Println("Verifying: " + name)
+	interrupt.SetStep("verifying chain (" + name + ")")
+	start := time.Now()
+	if err := fn(); err != nil {
+		return errors.New("Failed to " + name + ":\n" + err.Error())
+	}
+	v.stages = append(v.stages, Stage{Name: name, Duration: time.Since(start)})
+	return nil
+}
+
+func (v *verifier) waitFor(ctx context.Context, description string, condition func() (bool, error)) error {
+	err := wait.Poll(ctx, v.options.StageTimeout, configuration.PollInterval, condition)
+	if err == wait.ErrTimeout {
+		return errors.New("Timed out after " + v.options.StageTimeout.String() + " waiting for " + description)
+	}
+	return err
+}
+
+// Register an action to remove something created while verifying, which is run when finished (or interrupted)
+func (v *verifier) addCleanup(fn func() error) {
+	v.cleanups = append(v.cleanups, fn)
+}
+
+// Run cleanups in reverse order, returning the first error. Not bound to ctx so that it works after being cancelled
+func (v *verifier) runCleanups() error {
+	var firstErr error
+	for i := len(v.cleanups) - 1; i >= 0; i-- {
+		if err := v.cleanups[i](); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	v.cleanups = nil
+	return firstErr
+}
+
+// A transaction is not found until it has been processed, so treat not found as not yet ready rather than an error
+func isNotFound(err error) bool {
+	responseErr, ok := err.(*chainapi.ResponseError)
+	return ok && responseErr.StatusCode == 404
+}
+
+func (v *verifier) verifyTransactions(ctx context.Context, txnType string, payload string) error {
+	if err := v.stage("create transaction type", func() error {
+		if err := v.client.CreateTransactionType(ctx, txnType); err != nil {
+			return err
+		}
+		v.addCleanup(func() error {
+			return v.client.DeleteTransactionType(context.Background(), txnType)
+		})
+		return nil
+	}); err != nil {
+		return err
+	}
+	var txnID string
+	if err := v.stage("post transaction", func() error {
+		var err error
+		txnID, err = v.client.PostTransaction(ctx, txnType, payload, "dc-installer-verify")
+		return err
+	}); err != nil {
+		return err
+	}
+	if err := v.stage("wait for transaction to be in a block", func() error {
+		return v.waitFor(ctx, "transaction "+txnID+" to be in a block", func() (bool, error) {
+			transaction, err := v.client.GetTransaction(ctx, txnID)
+			if isNotFound(err) {
+				return false, nil
+			} else if err != nil {
+				return false, err
+			}
+			return transaction.Header.BlockID != "", nil
+		})
+	}); err != nil {
+		return err
+	}
+	if err := v.stage("wait for transaction to be indexed", func() error {
+		return v.waitFor(ctx, "transaction "+txnID+" to be indexed", func() (bool, error) {
+			transactions, err := v.client.QueryTransactions(ctx, txnType, "*")
+			if err != nil {
+				return false, err
+			}
+			for _, transaction := range transactions {
+				if transaction.Header.TxnID == txnID {
+					return true, nil
+				}
+			}
+			return false, nil
+		})
+	}); err != nil {
+		return err
+	}
+	return v.stage("query transaction", func() error {
+		transaction, err := v.client.GetTransaction(ctx, txnID)
+		if err != nil {
+			return err
+		}
+		if transaction.Header.TxnType != txnType || transaction.Payload != payload {
+			return errors.New("Transaction " + txnID + " returned by the chain does not match the one which was posted")
+		}
+		return nil
+	})
+}
+
+func (v *verifier) verifySmartContract(ctx context.Context, txnType string, payload string) error {
+	var contract *chainapi.SmartContract
+	if err := v.stage("deploy smart contract", func() error {
+		var err error
+		contract, err = v.client.CreateSmartContract(ctx, txnType, v.options.ContractImage, v.options.ContractCmd, []string{})
+		if err != nil {
+			return err
+		}
+		contractID := contract.ID
+		v.addCleanup(func() error {
+			return v.client.DeleteSmartContract(context.Background(), contractID)
+		})
+		return v.waitFor(ctx, "smart contract "+contractID+" to be built by openfaas", func() (bool, error) {
+			contract, err = v.client.GetSmartContract(ctx, contractID)
+			if err != nil {
+				return false, err
+			}
+			switch strings.ToLower(contract.Status.State) {
+			case "active":
+				return true, nil
+			case "failed":
+				return false, errors.New("Smart contract failed to deploy: " + contract.Status.Msg)
+			}
+			return false, nil
+		})
+	}); err != nil {
+		return err
+	}
+	return v.stage("invoke smart contract", func() error {
+		txnID, err := v.client.PostTransaction(ctx, txnType, payload, "dc-installer-verify")
+		if err != nil {
+			return err
+		}
+		// The contract's output is posted as a transaction whose invoker is the transaction that invoked it
+		return v.waitFor(ctx, "output of smart contract invoked by transaction "+txnID, func() (bool, error) {
+			transactions, err := v.client.QueryTransactions(ctx, txnType, "*")
+			if err != nil {
+				return false, err
+			}
+			for _, transaction := range transactions {
+				if transaction.Header.Invoker == txnID {
+					return true, nil
+				}
+			}
+			return false, nil
+		})
+	})
+}
+
+// Run verifies that a chain processes transactions end to end: creating a transaction type, posting a transaction,
+// waiting for it to be blocked and indexed, and querying it back (and optionally doing the same through a smart contract).
+// Anything created is removed again afterwards. Returns the stages which completed, even on failure
+func Run(ctx context.Context, client *chainapi.Client, options Options) ([]Stage, error) {
+	v := &verifier{client: client, options: options}
+	done := interrupt.OnInterrupt("removing transaction type and smart contract created to verify chain", func() {
+		v.runCleanups()
+	})
+	suffix := strconv.FormatInt(time.Now().Unix(), 10)
+	payload := "dc-installer verification " + suffix
+	err := v.verifyTransactions(ctx, "installer-verify-"+suffix, payload)
+	if err == nil && options.SmartContract {
+		err = v.verifySmartContract(ctx, "installer-verify-contract-"+suffix, payload)
+	}
+	done()
+	if err != nil {
+		v.runCleanups()
+		return v.stages, err
+	}
+	err = v.stage("clean up", v.runCleanups)
+	return v.stages, err
+}
@@ -1,31 +1,191 @@
 package virtualbox
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"net"
 	"os"
 	"os/exec"
+	"regexp"
 	"strconv"
+	"strings"
 
 	"github.com/dragonchain/dragonchain-installer/internal/configuration"
 )
 
-func forwardVirtualboxPort(config *configuration.Configuration) error {
-	// Delete possible existing port-forward rule before creating it (don't care about errors)
-	exec.Command(vboxManageExecutable(), "controlvm", configuration.MinikubeContext, "natpf1", "delete", config.InternalID+"-traffic").Run()
+// NATRule is a NAT port forwarding rule of a virtualbox VM
+type NATRule struct {
+	VM        string
+	Name      string
+	Protocol  string
+	HostIP    string
+	HostPort  int
+	GuestIP   string
+	GuestPort int
+}
+
+// Lines of `VBoxManage showvminfo --machinereadable` like: Forwarding(0)="name,tcp,,30000,,30000"
+var forwardingRegex = regexp.MustCompile(`^Forwarding\(\d+\)="([^"]*)"$`)
+
+// Lines of `VBoxManage list vms` like: "minikube" {uuid}
+var vmRegex = regexp.MustCompile(`^"(.*)" \{[^}]*\}$`)
+
+// RuleName is the name of the NAT rule forwarding the chain's port to the VM
+func RuleName(config *configuration.Configuration) string {
+	return config.InternalID + "-traffic"
+}
+
+func listVMs(ctx context.Context) ([]string, error) {
+	cmd := exec.CommandContext(ctx, vboxManageExecutable(), "list", "vms")
+	cmd.Stderr = os.Stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, errors.New("Couldn't list virtualbox VMs:\n" + err.Error())
+	}
+	var vms []string
+	for _, line := range strings.Split(string(out), "\n") {
+		if match := vmRegex.FindStringSubmatch(strings.TrimSpace(line)); match != nil {
+			vms = append(vms, match[1])
+		}
+	}
+	return vms, nil
+}
+
+func parseNATRules(vm string, vmInfo string) []NATRule {
+	var rules []NATRule
+	for _, line := range strings.Split(vmInfo, "\n") {
+		match := forwardingRegex.FindStringSubmatch(strings.TrimSpace(line))
+		if match == nil {
+			continue
+		}
+		fields := strings.Split(match[1], ",")
+		if len(fields) != 6 {
+			continue
+		}
+		hostPort, err := strconv.Atoi(fields[3])
+		if err != nil {
+			continue
+		}
+		guestPort, err := strconv.Atoi(fields[5])
+		if err != nil {
+			continue
+		}
+		rules = append(rules, NATRule{VM: vm, Name: fields[0], Protocol: fields[1], HostIP: fields[2], HostPort: hostPort, GuestIP: fields[4], GuestPort: guestPort})
+	}
+	return rules
+}
+
+// ListNATRules lists the NAT port forwarding rules of every virtualbox VM, including ones other programs created
+func ListNATRules(ctx context.Context) ([]NATRule, error) {
+	vms, err := listVMs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var rules []NATRule
+	for _, vm := range vms {
+		cmd := exec.CommandContext(ctx, vboxManageExecutable(), "showvminfo", vm, "--machinereadable")
+		cmd.Stderr = os.Stderr
+		out, err := cmd.Output()
+		if err != nil {
+			return nil, errors.New("Couldn't get virtualbox VM info of " + vm + ":\n" + err.Error())
+		}
+		rules = append(rules, parseNATRules(vm, string(out))...)
+	}
+	return rules, nil
+}
+
+// ConflictsWith checks whether the rule forwards the chain's TCP port for something other than the chain.
+// Rules for other protocols can share the host port, since the chain's rule only forwards TCP
+func (rule NATRule) ConflictsWith(config *configuration.Configuration) bool {
+	if rule.Protocol != "tcp" || rule.HostPort != config.Port {
+		return false
+	}
+	return rule.VM != configuration.MinikubeContext || rule.Name != RuleName(config)
+}
+
+// Describe who most likely created the rule. Every chain installed on this machine shares the dragonchain minikube VM,
+// so only rules of that VM can belong to another dragonchain
+func (rule NATRule) owner() string {
+	if rule.VM == configuration.MinikubeContext {
+		return "another dragonchain"
+	}
+	return "another program"
+}
+
+// Check whether something on this machine is already listening on a TCP port
+func hostPortInUse(port int) bool {
+	listener, err := net.Listen("tcp", ":"+strconv.Itoa(port))
+	if err != nil {
+		return true
+	}
+	listener.Close()
+	return false
+}
+
+func deleteNATRule(ctx context.Context, name string) error {
+	cmd := exec.CommandContext(ctx, vboxManageExecutable(), "controlvm", configuration.MinikubeContext, "natpf1", "delete", name)
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return errors.New("Error deleting virtualbox port forward " + name + ":\n" + err.Error())
+	}
+	return nil
+}
+
+func forwardVirtualboxPort(ctx context.Context, config *configuration.Configuration) error {
+	rules, err := ListNATRules(ctx)
+	if err != nil {
+		return err
+	}
+	name := RuleName(config)
+	for _, rule := range rules {
+		if !rule.ConflictsWith(config) {
+			continue
+		}
+		return errors.New("Port " + strconv.Itoa(config.Port) + " is already forwarded by virtualbox rule '" + rule.Name + "' of VM '" + rule.VM + "' (" + rule.owner() + "). Choose a different port, or remove that rule with:\n" +
+			vboxManageExecutable() + " controlvm " + rule.VM + " natpf1 delete " + rule.Name)
+	}
+	for _, rule := range rules {
+		if rule.VM != configuration.MinikubeContext || rule.Name != name {
+			continue
+		}
+		if rule.Protocol == "tcp" && rule.HostPort == config.Port && rule.GuestPort == config.Port {
+			// Already forwarded correctly
+			return nil
+		}
+		// The chain's port changed since the rule was created
+		fmt.Println("Removing stale virtualbox port forward of port " + strconv.Itoa(rule.HostPort))
+		if err := deleteNATRule(ctx, name); err != nil {
+			return err
+		}
+	}
+	if hostPortInUse(config.Port) {
+		return errors.New("Port " + strconv.Itoa(config.Port) + " is already in use on this machine, so virtualbox can't forward it to the VM. Stop the program using it or choose a different port")
+	}
 	portStr := strconv.Itoa(config.Port)
 	// Add host port-forwarding from VM network to host machine's network
-	cmd := exec.Command(vboxManageExecutable(), "controlvm", configuration.MinikubeContext, "natpf1", config.InternalID+"-traffic,tcp,,"+portStr+",,"+portStr)
+	cmd := exec.CommandContext(ctx, vboxManageExecutable(), "controlvm", configuration.MinikubeContext, "natpf1", name+",tcp,,"+portStr+",,"+portStr)
 	cmd.Stderr = os.Stderr
 	if err := cmd.Run(); err != nil {
-		return errors.New("Error forwarding virtualbox port (maybe this port is already in use on this machine?):\n" + err.Error())
+		return errors.New("Error forwarding virtualbox port:\n" + err.Error())
 	}
 	return nil
 }
 
 // ConfigureVirtualboxVM configures the minikube virtualbox VM as necessary for dragonchain usage
-func ConfigureVirtualboxVM(config *configuration.Configuration) error {
-	if err := forwardVirtualboxPort(config); err != nil {
+func ConfigureVirtualboxVM(ctx context.Context, config *configuration.Configuration) error {
+	if err := forwardVirtualboxPort(ctx, config); err != nil {
 		return err
 	}
 	return nil
 }
+
+// ResizeVM changes the memory (in megabytes) and cpus of the minikube VM, which must be stopped
+func ResizeVM(ctx context.Context, memoryMB int, cpus int) error {
+	cmd := exec.CommandContext(ctx, vboxManageExecutable(), "modifyvm", configuration.MinikubeContext, "--memory", strconv.Itoa(memoryMB), "--cpus", strconv.Itoa(cpus))
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return errors.New("Error resizing virtualbox VM (is it still running?):\n" + err.Error())
+	}
+	return nil
+}
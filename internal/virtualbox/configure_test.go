@@ -0,0 +1,52 @@
+package virtualbox
+
+import (
+	"testing"
+
+	"github.com/dragonchain/dragonchain-installer/internal/configuration"
+)
+
+const testVMInfo = `name="dragonchain"
+Forwarding(0)="8d3f2b5c-traffic,tcp,,30000,,30000"
+Forwarding(1)="dns,udp,,30000,,53"
+Forwarding(2)="other-traffic,tcp,,30000,,30000"
+Forwarding(3)="broken,tcp,,port,,30000"
+`
+
+func TestParseNATRules(t *testing.T) {
+	rules := parseNATRules("dragonchain", testVMInfo)
+	if len(rules) != 3 {
+		t.Fatalf("Expected 3 rules, got %+v", rules)
+	}
+	expected := NATRule{VM: "dragonchain", Name: "dns", Protocol: "udp", HostPort: 30000, GuestPort: 53}
+	if rules[1] != expected {
+		t.Errorf("Expected %+v, got %+v", expected, rules[1])
+	}
+}
+
+func TestConflictsWith(t *testing.T) {
+	config := &configuration.Configuration{InternalID: "8d3f2b5c", Port: 30000}
+	cases := []struct {
+		name      string
+		rule      NATRule
+		conflicts bool
+		owner     string
+	}{
+		{"this chain's rule", NATRule{VM: configuration.MinikubeContext, Name: "8d3f2b5c-traffic", Protocol: "tcp", HostPort: 30000}, false, ""},
+		{"udp on the same port", NATRule{VM: configuration.MinikubeContext, Name: "dns", Protocol: "udp", HostPort: 30000}, false, ""},
+		{"another port", NATRule{VM: "ubuntu", Name: "ssh", Protocol: "tcp", HostPort: 2222}, false, ""},
+		{"another chain", NATRule{VM: configuration.MinikubeContext, Name: "0a1b2c3d-traffic", Protocol: "tcp", HostPort: 30000}, true, "another dragonchain"},
+		{"this chain's rule name on another VM", NATRule{VM: "ubuntu", Name: "8d3f2b5c-traffic", Protocol: "tcp", HostPort: 30000}, true, "another program"},
+		{"another VM's traffic rule", NATRule{VM: "minikube", Name: "web-traffic", Protocol: "tcp", HostPort: 30000}, true, "another program"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if conflicts := c.rule.ConflictsWith(config); conflicts != c.conflicts {
+				t.Errorf("Expected conflict %v, got %v", c.conflicts, conflicts)
+			}
+			if c.conflicts && c.rule.owner() != c.owner {
+				t.Errorf("Expected owner %q, got %q", c.owner, c.rule.owner())
+			}
+		})
+	}
+}
@@ -1,6 +1,7 @@
 package virtualbox
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"io/ioutil"
@@ -14,43 +15,43 @@ import (
 	"github.com/dragonchain/dragonchain-installer/internal/downloader"
 )
 
-func virtualBoxIsInstalled() bool {
-	return exec.Command(vboxManageExecutable(), "--version").Run() == nil
+func virtualBoxIsInstalled(ctx context.Context) bool {
+	return exec.CommandContext(ctx, vboxManageExecutable(), "--version").Run() == nil
 }
 
 // InstallVirtualBoxIfNecessary checks if virtualbox is already installed, and installs it if necessary
-func InstallVirtualBoxIfNecessary() error {
+func InstallVirtualBoxIfNecessary(ctx context.Context) error {
 	if !configuration.AMD64 {
 		return errors.New("Cannot install virtualbox on non-amd64 architecture")
 	}
-	if virtualBoxIsInstalled() {
+	if virtualBoxIsInstalled(ctx) {
 		fmt.Println("virtualbox appears to already be installed")
 		return nil
 	}
 	fmt.Println("virtualbox is not installed. Installing now")
 	if configuration.Windows {
-		if err := installVirtualBoxWindows(); err != nil {
+		if err := installVirtualBoxWindows(ctx); err != nil {
 			return err
 		}
 	} else if configuration.Macos {
-		if err := installVirtualBoxMacos(); err != nil {
+		if err := installVirtualBoxMacos(ctx); err != nil {
 			return err
 		}
 	} else if configuration.Linux {
-		if err := installVirtualBoxLinux(); err != nil {
+		if err := installVirtualBoxLinux(ctx); err != nil {
 			return err
 		}
 	} else {
 		log.Fatal("Unsupported operating system")
 	}
 	// Should be installed at this point; if not, something is wrong
-	if !virtualBoxIsInstalled() {
+	if !virtualBoxIsInstalled(ctx) {
 		return errors.New("Virtualbox failed to install")
 	}
 	return nil
 }
 
-func installVirtualBoxLinux() error {
+func installVirtualBoxLinux(ctx context.Context) error {
 	// Create the temp dir for the download
 	tempDir, err := ioutil.TempDir("", "dcinstaller")
 	if err != nil {
@@ -60,7 +61,7 @@ func installVirtualBoxLinux() error {
 	// Download virtualbox
 	fmt.Println("Downloading virtualbox")
 	installerFile := filepath.Join(tempDir, "virtualbox.run")
-	if err := downloader.DownloadFile(installerFile, configuration.LinuxVirtualboxLink); err != nil {
+	if err := downloader.DownloadFile(ctx, installerFile, configuration.LinuxVirtualboxLink); err != nil {
 		return errors.New("Downloading virtualbox failed:\n" + err.Error())
 	}
 	// Set execution permissions
@@ -70,7 +71,7 @@ func installVirtualBoxLinux() error {
 	}
 	// Run the installer (require sudo)
 	fmt.Println("Installing Virtualbox")
-	cmd := exec.Command("sudo", installerFile)
+	cmd := exec.CommandContext(ctx, "sudo", installerFile)
 	cmd.Stderr = os.Stderr
 	cmd.Stdout = os.Stdout
 	cmd.Stdin = os.Stdin
@@ -80,7 +81,7 @@ func installVirtualBoxLinux() error {
 	return nil
 }
 
-func installVirtualBoxMacos() error {
+func installVirtualBoxMacos(ctx context.Context) error {
 	// Create the temp dir for the download
 	tempDir, err := ioutil.TempDir("", "dcinstaller")
 	if err != nil {
@@ -90,31 +91,32 @@ func installVirtualBoxMacos() error {
 	// Download virtualbox
 	fmt.Println("Downloading virtualbox")
 	installerFile := filepath.Join(tempDir, "virtualbox.dmg")
-	if err := downloader.DownloadFile(installerFile, configuration.MacosVirtualboxLink); err != nil {
+	if err := downloader.DownloadFile(ctx, installerFile, configuration.MacosVirtualboxLink); err != nil {
 		return errors.New("Downloading virtualbox failed:\n" + err.Error())
 	}
 	// Mount the dmg
 	fmt.Println("Installing Virtualbox")
-	cmd := exec.Command("hdiutil", "attach", installerFile)
+	cmd := exec.CommandContext(ctx, "hdiutil", "attach", installerFile)
 	cmd.Stderr = os.Stderr
 	if err := cmd.Run(); err != nil {
 		return errors.New("Mounting virtualbox dmg failed:\n" + err.Error())
 	}
+	// Not bound to ctx so that the dmg is still detached if the installer is interrupted
 	defer exec.Command("hdiutil", "detach", "/Volumes/VirtualBox").Run()
 	// Copy the pkg and remove its extended attributes for installation
 	virtualBoxPkg := filepath.Join(tempDir, "virtualbox.pkg")
-	cmd = exec.Command("cp", "-f", "/Volumes/VirtualBox/VirtualBox.pkg", virtualBoxPkg)
+	cmd = exec.CommandContext(ctx, "cp", "-f", "/Volumes/VirtualBox/VirtualBox.pkg", virtualBoxPkg)
 	cmd.Stderr = os.Stderr
 	if err := cmd.Run(); err != nil {
 		return errors.New("Error copying virtualbox pkg file:\n" + err.Error())
 	}
-	cmd = exec.Command("xattr", "-c", virtualBoxPkg)
+	cmd = exec.CommandContext(ctx, "xattr", "-c", virtualBoxPkg)
 	cmd.Stderr = os.Stderr
 	if err := cmd.Run(); err != nil {
 		return errors.New("Error removing extended attributes from pkg:\n" + err.Error())
 	}
 	// Install pkg (with sudo, prompting for password if necessary)
-	cmd = exec.Command("sudo", "installer", "-package", virtualBoxPkg, "-target", "/")
+	cmd = exec.CommandContext(ctx, "sudo", "installer", "-package", virtualBoxPkg, "-target", "/")
 	cmd.Stderr = os.Stderr
 	cmd.Stdout = os.Stdout
 	cmd.Stdin = os.Stdin
@@ -124,7 +126,7 @@ func installVirtualBoxMacos() error {
 	return nil
 }
 
-func installVirtualBoxWindows() error {
+func installVirtualBoxWindows(ctx context.Context) error {
 	tempRoot, exists := os.LookupEnv("TEMP")
 	if !exists {
 		return errors.New("Environment variable 'TEMP' could not be found")
@@ -139,12 +141,12 @@ func installVirtualBoxWindows() error {
 	// Download the installer
 	fmt.Println("Downloading virtualbox")
 	exeFile := filepath.Join(tempDir, "virtualbox.exe")
-	if err := downloader.DownloadFile(exeFile, configuration.WindowsVirtualboxLink); err != nil {
+	if err := downloader.DownloadFile(ctx, exeFile, configuration.WindowsVirtualboxLink); err != nil {
 		return errors.New("Downloading virtualbox failed:\n" + err.Error())
 	}
 	// Extract the msi installer
 	fmt.Println("Installing Virtualbox")
-	cmd := exec.Command(exeFile, "-extract", "-silent")
+	cmd := exec.CommandContext(ctx, exeFile, "-extract", "-silent")
 	cmd.Stderr = os.Stderr
 	if err := cmd.Run(); err != nil {
 		return errors.New("Extracting msi installer from virtualbox exe failed:\n" + err.Error())
@@ -165,7 +167,7 @@ func installVirtualBoxWindows() error {
 		return errors.New("Couldn't find extracted msi to install virtualbox")
 	}
 	// Install the extracted msi
-	cmd = exec.Command("msiexec", "/i", filepath.Join(vboxTemp, msiToUse), "/quiet", "/qn", "/norestart", "/log", filepath.Join(tempDir, "vbox_install.log"))
+	cmd = exec.CommandContext(ctx, "msiexec", "/i", filepath.Join(vboxTemp, msiToUse), "/quiet", "/qn", "/norestart", "/log", filepath.Join(tempDir, "vbox_install.log"))
 	cmd.Stderr = os.Stderr
 	if err := cmd.Run(); err != nil {
 		return errors.New("Running msiexec on extracted virtualbox installer failed (are you running as administrator?):\n" + err.Error())
@@ -1,7 +1,9 @@
 package virtualbox
 
 import (
+	"context"
 	"os"
+	"os/exec"
 	"path/filepath"
 
 	"github.com/dragonchain/dragonchain-installer/internal/configuration"
@@ -18,3 +20,18 @@ func vboxManageExecutable() string {
 	}
 	return "vboxmanage"
 }
+
+// Run VBoxManage, returning its combined output (for diagnostics)
+func vboxManageOutput(ctx context.Context, args ...string) ([]byte, error) {
+	return exec.CommandContext(ctx, vboxManageExecutable(), args...).CombinedOutput()
+}
+
+// Version gets the output of VBoxManage --version
+func Version(ctx context.Context) ([]byte, error) {
+	return vboxManageOutput(ctx, "--version")
+}
+
+// VMInfo gets the machine readable details of the minikube VM, including its size and NAT rules
+func VMInfo(ctx context.Context) ([]byte, error) {
+	return vboxManageOutput(ctx, "showvminfo", configuration.MinikubeContext, "--machinereadable")
+}
@@ -0,0 +1,35 @@
+package wait
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrTimeout is returned when a condition does not become true before its timeout
+var ErrTimeout = errors.New("Timed out waiting for condition")
+
+// Poll checks condition every interval until it reports done, returns an error, or timeout passes
+func Poll(ctx context.Context, timeout time.Duration, interval time.Duration, condition func() (bool, error)) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		done, err := condition()
+		if err != nil {
+			return err
+		}
+		if done {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			if ctx.Err() == context.DeadlineExceeded {
+				return ErrTimeout
+			}
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package watchdog
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"os/exec"
+	"runtime"
+	"time"
+
+	"github.com/dragonchain/dragonchain-installer/internal/dragonnet"
+)
+
+// Options for watching a chain's dragon net configuration
+type Options struct {
+	PubID       string
+	Port        int
+	EndpointURL string
+	// How often to check the chain
+	Interval time.Duration
+	// Number of consecutive failed checks before alerting
+	FailureThreshold int
+	// Shell command to run when alerting (with the alert in the DRAGONCHAIN_WATCH_MESSAGE environment variable), if not empty
+	AlertCommand string
+	// Checks the chain's dragon net configuration (i.e. dragonnet.CheckDragonNetConfiguration)
+	Check func(ctx context.Context, pubID string) error
+	// Recreates the router port forward when the chain is registered but not reachable (i.e. with portmap.Forward)
+	ForwardPort dragonnet.PortForwarder
+	// Gets the current public ip (i.e. configuration.GetPublicIP)
+	PublicIP func(ctx context.Context) (string, error)
+}
+
+type watchdog struct {
+	options  Options
+	publicIP string
+	failures int
+}
+
+func logf(format string, args ...interface{}) {
+	fmt.Println(time.Now().Format(time.RFC3339) + " " + fmt.Sprintf(format, args...))
+}
+
+// Run the alert command (if any) with message
+func (w *watchdog) alert(ctx context.Context, message string) {
+	logf("ALERT: %s", message)
+	if w.options.AlertCommand == "" {
+		return
+	}
+	cmd := exec.CommandContext(ctx, "sh", "-c", w.options.AlertCommand)
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd", "/C", w.options.AlertCommand)
+	}
+	cmd.Env = append(os.Environ(), "DRAGONCHAIN_WATCH_MESSAGE="+message)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		logf("Alert command failed: %s", err.Error())
+	}
+}
+
+// Check for public ip changes, which break an endpoint that uses the ip directly
+func (w *watchdog) checkPublicIP(ctx context.Context) {
+	ip, err := w.options.PublicIP(ctx)
+	if err != nil {
+		logf("Could not get public ip: %s", err.Error())
+		return
+	}
+	if w.publicIP != "" && ip != w.publicIP {
+		logf("Public ip changed from %s to %s", w.publicIP, ip)
+		if endpoint, err := url.Parse(w.options.EndpointURL); err == nil && net.ParseIP(endpoint.Hostname()) != nil && !net.ParseIP(endpoint.Hostname()).Equal(net.ParseIP(ip)) {
+			w.alert(ctx, "Public ip changed to "+ip+", but the chain's endpoint is still "+w.options.EndpointURL+". Update the chain's endpoint so dragon net can reach it")
+		}
+	}
+	w.publicIP = ip
+}
+
+// Check the chain once, trying to recreate the port forward if it isn't reachable
+func (w *watchdog) check(ctx context.Context) {
+	w.checkPublicIP(ctx)
+	err := w.options.Check(ctx, w.options.PubID)
+	if _, notReachable := err.(*dragonnet.NotReachableError); notReachable {
+		logf("Chain is not reachable by dragon net, recreating port forward")
+		if forwardErr := w.options.ForwardPort(ctx, w.options.Port); forwardErr != nil {
+			logf("Could not recreate port forward: %s", forwardErr.Error())
+		} else {
+			err = w.options.Check(ctx, w.options.PubID)
+		}
+	}
+	if ctx.Err() != nil {
+		// Stopping; the check didn't really fail
+		return
+	}
+	if err != nil {
+		w.failures++
+		logf("Dragon net check failed (%d in a row): %s", w.failures, err.Error())
+		if w.failures == w.options.FailureThreshold {
+			w.alert(ctx, fmt.Sprintf("Chain %s has failed its dragon net check %d times in a row: %s", w.options.PubID, w.failures, err.Error()))
+		}
+		return
+	}
+	if w.failures >= w.options.FailureThreshold {
+		w.alert(ctx, "Chain "+w.options.PubID+" is working with dragon net again")
+	} else if w.failures > 0 {
+		logf("Chain is working with dragon net again")
+	} else {
+		logf("Chain is working with dragon net")
+	}
+	w.failures = 0
+}
+
+// Run checks the chain's dragon net configuration every interval until ctx is done, re-creating the port forward when the
+// chain isn't reachable, and alerting when checks keep failing or the public ip changes out from under the endpoint
+func Run(ctx context.Context, options Options) error {
+	w := &watchdog{options: options}
+	ticker := time.NewTicker(options.Interval)
+	defer ticker.Stop()
+	for {
+		w.check(ctx)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}